@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skantay/lru-api/internal/api"
+	"github.com/skantay/lru-api/internal/cache"
+	"github.com/skantay/lru-api/internal/cache/mocks"
+	"github.com/skantay/lru-api/pkg/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestCLI(t *testing.T, output string) (*cli, *bytes.Buffer) {
+	c, err := cache.New(10, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	server := httptest.NewServer(api.New(c, slog.New(slog.NewTextHandler(io.Discard, nil))))
+	t.Cleanup(server.Close)
+
+	lruClient, err := client.New(server.URL)
+	assert.NoError(t, err)
+
+	stdout := &bytes.Buffer{}
+
+	return &cli{client: lruClient, output: output, stdin: strings.NewReader(""), stdout: stdout}, stdout
+}
+
+func TestCommands(t *testing.T) {
+	c, stdout := newTestCLI(t, outputJSON)
+	ctx := context.Background()
+
+	assert.NoError(t, c.execute(ctx, []string{"put", "user:1", `{"name":"a"}`, "--ttl", "1h"}))
+	assert.NoError(t, c.execute(ctx, []string{"put", "user:2", "hello"}))
+	assert.NoError(t, c.execute(ctx, []string{"put", "other", "2"}))
+
+	stdout.Reset()
+	assert.NoError(t, c.execute(ctx, []string{"list", "--prefix", "user:"}))
+	assert.JSONEq(t, `[{"key":"user:1","value":{"name":"a"}},{"key":"user:2","value":"hello"}]`, stdout.String())
+
+	stdout.Reset()
+	assert.NoError(t, c.execute(ctx, []string{"export"}))
+	exported := stdout.String()
+	assert.Equal(t, 3, strings.Count(exported, "\n"))
+
+	assert.ErrorIs(t, c.execute(ctx, []string{"flush"}), errUsage)
+	assert.NoError(t, c.execute(ctx, []string{"flush", "--yes"}))
+
+	_, _, err := c.client.Get(ctx, "other")
+	assert.ErrorIs(t, err, client.ErrKeyDoesNotExist)
+
+	c.stdin = strings.NewReader(exported)
+	stdout.Reset()
+	assert.NoError(t, c.execute(ctx, []string{"import"}))
+	assert.JSONEq(t, `{"status":"imported 3"}`, stdout.String())
+
+	value, _, err := c.client.Get(ctx, "other")
+	assert.NoError(t, err)
+	assert.Equal(t, float64(2), value)
+
+	// The expiration time is kept
+	_, expiresAt, err := c.client.Get(ctx, "user:1")
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, 2*time.Second)
+
+	c.stdin = strings.NewReader(`{"key":"ok","value":1}` + "\n" + `{"key":""}`)
+	stdout.Reset()
+	assert.EqualError(t, c.execute(ctx, []string{"import"}), "line 2: empty_key")
+	assert.JSONEq(t, `{"status":"imported 1"}`, stdout.String())
+
+	stdout.Reset()
+	assert.NoError(t, c.execute(ctx, []string{"del", "other", "missing"}))
+	assert.JSONEq(t, `{"status":"deleted 1"}`, stdout.String())
+
+	assert.ErrorIs(t, c.execute(ctx, []string{"get", "other"}), client.ErrKeyDoesNotExist)
+	assert.ErrorIs(t, c.execute(ctx, []string{"unknown"}), errUsage)
+}
+
+func TestTableOutput(t *testing.T) {
+	c, stdout := newTestCLI(t, outputTable)
+	ctx := context.Background()
+
+	assert.NoError(t, c.execute(ctx, []string{"put", "key", "value"}))
+
+	stdout.Reset()
+	assert.NoError(t, c.execute(ctx, []string{"list"}))
+	assert.Equal(t, "KEY  VALUE\nkey  \"value\"\n", stdout.String())
+
+	stdout.Reset()
+	assert.NoError(t, c.execute(ctx, []string{"stats"}))
+	assert.Equal(t, "ENTRIES  BYTES\n1        10\n", stdout.String())
+}
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{line: "get key", want: []string{"get", "key"}},
+		{line: "  put  'a key'   \"a value\" ", want: []string{"put", "a key", "a value"}},
+		{line: `put key it\'s`, want: []string{"put", "key", "it's"}},
+		{line: `put key ''`, want: []string{"put", "key", ""}},
+		{line: `put key '{"a": 1}'`, want: []string{"put", "key", `{"a": 1}`}},
+	}
+
+	for _, test := range tests {
+		got, err := splitArgs(test.line)
+		assert.NoError(t, err, test.line)
+		assert.Equal(t, test.want, got, test.line)
+
+		for _, arg := range got {
+			quoted, err := splitArgs(quoteArg(arg))
+			assert.NoError(t, err)
+			assert.Equal(t, []string{arg}, quoted)
+		}
+	}
+
+	_, err := splitArgs(`get "key`)
+	assert.Error(t, err)
+}
+
+func TestComplete(t *testing.T) {
+	c, _ := newTestCLI(t, outputTable)
+	ctx := context.Background()
+
+	assert.NoError(t, c.client.Put(ctx, "user:1", 1, 0))
+	assert.NoError(t, c.client.Put(ctx, "user:2", 2, 0))
+	assert.NoError(t, c.client.Put(ctx, "a key", 3, 0))
+
+	completer := &completer{cli: c}
+
+	tests := []struct {
+		line     string
+		wantLine string
+		wantOK   bool
+	}{
+		{line: "ge", wantLine: "get ", wantOK: true},
+		{line: "get us", wantLine: "get user:", wantOK: true},
+		{line: "get user:", wantOK: false},
+		{line: "get user:1", wantLine: "get user:1 ", wantOK: true},
+		{line: "del a", wantLine: "del 'a key' ", wantOK: true},
+		{line: "get x", wantOK: false},
+	}
+
+	for _, test := range tests {
+		line, pos, ok := completer.complete(test.line, len(test.line), '\t')
+		assert.Equal(t, test.wantOK, ok, test.line)
+
+		if test.wantOK {
+			assert.Equal(t, test.wantLine, line, test.line)
+			assert.Equal(t, len(test.wantLine), pos, test.line)
+		}
+	}
+}
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/skantay/lru-api/pkg/client"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// errUsage is returned for wrong arguments of a command
+var errUsage = errors.New("usage")
+
+type cli struct {
+	client *client.Client
+	output string
+
+	stdin  io.Reader
+	stdout io.Writer
+}
+
+type entry struct {
+	Key       string      `json:"key"`
+	Value     interface{} `json:"value"`
+	ExpiresAt string      `json:"expires_at,omitempty"`
+}
+
+// commands in the order they are listed by help and completed by the shell
+var commands = []string{"get", "put", "del", "flush", "list", "stats", "watch", "export", "import", "help"}
+
+// execute runs a command, args[0] is its name
+func (c *cli) execute(ctx context.Context, args []string) error {
+	name, args := args[0], args[1:]
+
+	switch name {
+	case "get":
+		return c.get(ctx, args)
+	case "put":
+		return c.put(ctx, args)
+	case "del":
+		return c.del(ctx, args)
+	case "flush":
+		return c.flush(ctx, args)
+	case "list":
+		return c.list(ctx, args)
+	case "stats":
+		return c.stats(ctx, args)
+	case "watch":
+		return c.watch(ctx, args)
+	case "export":
+		return c.export(ctx, args)
+	case "import":
+		return c.importEntries(ctx, args)
+	case "help":
+		fmt.Fprint(c.stdout, usage)
+
+		return nil
+	}
+
+	return fmt.Errorf("%w: unknown command %q", errUsage, name)
+}
+
+func (c *cli) get(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: get <key>", errUsage)
+	}
+
+	value, expiresAt, err := c.client.Get(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	if c.output == outputJSON {
+		return c.printJSON(entry{
+			Key:       args[0],
+			Value:     value,
+			ExpiresAt: expiresAt.Format(time.RFC3339),
+		})
+	}
+
+	return c.printTable([]string{"KEY", "VALUE", "EXPIRES"}, [][]string{
+		{args[0], formatValue(value), formatExpiry(expiresAt)},
+	})
+}
+
+func (c *cli) put(ctx context.Context, args []string) error {
+	flags := newFlagSet("put")
+	ttl := flags.Duration("ttl", 0, "time to live, the server default is used if 0")
+
+	args, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if len(args) != 2 {
+		return fmt.Errorf("%w: put [--ttl 30s] <key> <value>", errUsage)
+	}
+
+	if err := c.client.Put(ctx, args[0], parseValue(args[1]), *ttl); err != nil {
+		return err
+	}
+
+	return c.printStatus("OK")
+}
+
+func (c *cli) del(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: del <key>...", errUsage)
+	}
+
+	deleted := 0
+
+	for _, key := range args {
+		_, err := c.client.Evict(ctx, key)
+		if errors.Is(err, client.ErrKeyDoesNotExist) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		deleted++
+	}
+
+	return c.printStatus(fmt.Sprintf("deleted %d", deleted))
+}
+
+func (c *cli) flush(ctx context.Context, args []string) error {
+	flags := newFlagSet("flush")
+	yes := flags.Bool("yes", false, "confirm deleting every key")
+
+	if _, err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	if !*yes {
+		return fmt.Errorf("%w: flush deletes every key, run flush --yes to confirm", errUsage)
+	}
+
+	if err := c.client.EvictAll(ctx); err != nil {
+		return err
+	}
+
+	return c.printStatus("OK")
+}
+
+func (c *cli) list(ctx context.Context, args []string) error {
+	flags := newFlagSet("list")
+	prefix := flags.String("prefix", "", "list only keys with the prefix")
+
+	if _, err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	entries, err := c.entries(ctx, *prefix)
+	if err != nil {
+		return err
+	}
+
+	if c.output == outputJSON {
+		return c.printJSON(entries)
+	}
+
+	rows := make([][]string, 0, len(entries))
+	for _, e := range entries {
+		rows = append(rows, []string{e.Key, formatValue(e.Value)})
+	}
+
+	return c.printTable([]string{"KEY", "VALUE"}, rows)
+}
+
+func (c *cli) stats(ctx context.Context, _ []string) error {
+	entries, err := c.entries(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	// Size of values as the API encodes them, it approximates the memory used by the cache
+	var size int
+
+	for _, e := range entries {
+		data, err := json.Marshal(e.Value)
+		if err != nil {
+			return err
+		}
+
+		size += len(e.Key) + len(data)
+	}
+
+	if c.output == outputJSON {
+		return c.printJSON(map[string]int{
+			"entries": len(entries),
+			"bytes":   size,
+		})
+	}
+
+	return c.printTable([]string{"ENTRIES", "BYTES"}, [][]string{
+		{fmt.Sprint(len(entries)), fmt.Sprint(size)},
+	})
+}
+
+func (c *cli) watch(ctx context.Context, args []string) error {
+	flags := newFlagSet("watch")
+	key := flags.String("key", "", "watch only the key")
+	prefix := flags.String("prefix", "", "watch only keys with the prefix")
+	lastEventID := flags.Uint64("last-event-id", 0, "replay events after this ID")
+
+	if _, err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	if *key != "" && *prefix != "" {
+		return fmt.Errorf("%w: --key and --prefix are mutually exclusive", errUsage)
+	}
+
+	opts := client.WatchOptions{
+		Key:         *key,
+		Prefix:      *prefix,
+		LastEventID: *lastEventID,
+	}
+
+	err := c.client.Watch(ctx, opts, func(event client.Event) error {
+		if c.output == outputJSON {
+			payload := map[string]interface{}{
+				"id":    event.ID,
+				"type":  event.Type,
+				"key":   event.Key,
+				"value": event.Value,
+				"time":  event.Time.Format(time.RFC3339),
+			}
+
+			if !event.ExpiresAt.IsZero() {
+				payload["expires_at"] = event.ExpiresAt.Format(time.RFC3339)
+			}
+
+			return json.NewEncoder(c.stdout).Encode(payload)
+		}
+
+		_, err := fmt.Fprintf(c.stdout, "%s\t%d\t%s\t%s\t%s\n",
+			event.Time.Format(time.TimeOnly), event.ID, event.Type, event.Key, formatValue(event.Value))
+
+		return err
+	})
+
+	// Interrupting a watch is the normal way to end it
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+
+	return err
+}
+
+// export writes entries as NDJSON, one {"key","value"} object per line
+// export writes entries as NDJSON, from the least to the most recently used
+func (c *cli) export(ctx context.Context, args []string) error {
+	flags := newFlagSet("export")
+	file := flags.String("file", "", "write to the file instead of stdout")
+
+	if _, err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	out := c.stdout
+
+	if *file != "" {
+		f, err := os.Create(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		out = f
+	}
+
+	w := bufio.NewWriter(out)
+
+	exported, err := c.client.Export(ctx, w)
+	if err != nil {
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if *file != "" {
+		return c.printStatus(fmt.Sprintf("exported %d", exported))
+	}
+
+	return nil
+}
+
+// importEntries loads entries written by export, entries keep their expiration time.
+// --ttl applies to entries without expires_at and ttl_seconds.
+func (c *cli) importEntries(ctx context.Context, args []string) error {
+	flags := newFlagSet("import")
+	file := flags.String("file", "", "read from the file instead of stdin")
+	ttl := flags.Duration("ttl", 0, "time to live of entries without expires_at and ttl_seconds, the server default is used if 0")
+
+	if _, err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	in := c.stdin
+
+	if *file != "" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		in = f
+	}
+
+	result, err := c.client.Import(ctx, in, *ttl)
+	if err != nil {
+		return err
+	}
+
+	if err := c.printStatus(fmt.Sprintf("imported %d", result.Imported)); err != nil {
+		return err
+	}
+
+	// Rejected lines do not stop the import, they are reported at the end
+	rejected := make([]error, 0, len(result.Errors))
+
+	for _, e := range result.Errors {
+		if e.Detail == "" {
+			rejected = append(rejected, fmt.Errorf("line %d: %s", e.Line, e.Code))
+		} else {
+			rejected = append(rejected, fmt.Errorf("line %d: %s: %s", e.Line, e.Code, e.Detail))
+		}
+	}
+
+	return errors.Join(rejected...)
+}
+
+// entries returns entries sorted by key
+func (c *cli) entries(ctx context.Context, prefix string) ([]entry, error) {
+	keys, values, err := c.client.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]entry, 0, len(keys))
+
+	for i, key := range keys {
+		if strings.HasPrefix(key, prefix) {
+			entries = append(entries, entry{Key: key, Value: values[i]})
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+
+	return entries, nil
+}
+
+func (c *cli) printJSON(v interface{}) error {
+	encoder := json.NewEncoder(c.stdout)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(v)
+}
+
+func (c *cli) printTable(header []string, rows [][]string) error {
+	w := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+
+	return w.Flush()
+}
+
+// printStatus reports the result of a command that returns no data
+func (c *cli) printStatus(status string) error {
+	if c.output == outputJSON {
+		return c.printJSON(map[string]string{"status": status})
+	}
+
+	_, err := fmt.Fprintln(c.stdout, status)
+
+	return err
+}
+
+// parseValue takes a valid JSON document as is and anything else as a string,
+// so `put key 1` stores a number and `put key hello` a string
+func parseValue(s string) interface{} {
+	var value interface{}
+
+	if err := json.Unmarshal([]byte(s), &value); err != nil {
+		return s
+	}
+
+	return value
+}
+
+// formatValue prints values as JSON, so strings are told apart from numbers
+func formatValue(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+
+	return string(data)
+}
+
+func formatExpiry(expiresAt time.Time) string {
+	return fmt.Sprintf("%s (in %s)", expiresAt.Format(time.RFC3339), time.Until(expiresAt).Round(time.Second))
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+
+	return flags
+}
+
+// parseFlags allows flags after positional arguments, e.g. put key value --ttl 30s
+func parseFlags(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", errUsage, flags.Name(), err)
+		}
+
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
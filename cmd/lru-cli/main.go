@@ -0,0 +1,123 @@
+// Command lru-cli is a command-line client of lru-api.
+// Without a command it starts an interactive shell.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/skantay/lru-api/pkg/client"
+)
+
+const usage = `Usage: lru-cli [flags] [command [args]]
+
+Commands:
+  get <key>                          print a value
+  put [--ttl 30s] <key> <value>      insert or update a value, value is parsed as JSON or taken as a string
+  del <key>...                       delete keys
+  flush --yes                        delete every key
+  list [--prefix p]                  print keys and values
+  stats                              print number of entries and size of values
+  watch [--key k | --prefix p]       print change events until interrupted
+  export [--file f]                  write entries as NDJSON
+  import [--ttl 30s] [--file f]      read entries written by export
+  shell                              start an interactive shell, the default without a command
+
+Flags:
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin *os.File, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("lru-cli", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+
+	addr := flags.String("addr", envOr("LRU_ADDR", "http://localhost:8080"), "address of lru-api, env LRU_ADDR")
+	apiKey := flags.String("api-key", os.Getenv("LRU_API_KEY"), "API key sent in X-API-Key, env LRU_API_KEY")
+	token := flags.String("token", os.Getenv("LRU_TOKEN"), "bearer token, env LRU_TOKEN")
+	output := flags.String("o", envOr("LRU_OUTPUT", outputTable), "output format, table or json, env LRU_OUTPUT")
+	timeout := flags.Duration("timeout", 10*time.Second, "timeout of a single request")
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+
+		return 2
+	}
+
+	if *output != outputTable && *output != outputJSON {
+		fmt.Fprintf(stderr, "lru-cli: unknown output format %q\n", *output)
+
+		return 2
+	}
+
+	opts := []client.Option{client.WithTimeout(*timeout)}
+
+	switch {
+	case *token != "":
+		opts = append(opts, client.WithBearerToken(*token))
+	case *apiKey != "":
+		opts = append(opts, client.WithAPIKey(*apiKey))
+	}
+
+	c, err := client.New(*addr, opts...)
+	if err != nil {
+		fmt.Fprintf(stderr, "lru-cli: %v\n", err)
+
+		return 2
+	}
+
+	cli := &cli{
+		client: c,
+		output: *output,
+		stdin:  stdin,
+		stdout: stdout,
+	}
+
+	if flags.NArg() == 0 || flags.Arg(0) == "shell" {
+		if err := cli.shell(stdin, stdout); err != nil {
+			fmt.Fprintf(stderr, "lru-cli: %v\n", err)
+
+			return 1
+		}
+
+		return 0
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := cli.execute(ctx, flags.Args()); err != nil {
+		fmt.Fprintf(stderr, "lru-cli: %v\n", err)
+
+		if errors.Is(err, errUsage) {
+			return 2
+		}
+
+		return 1
+	}
+
+	return 0
+}
+
+func envOr(name, fallback string) string {
+	if value, ok := os.LookupEnv(name); ok {
+		return value
+	}
+
+	return fallback
+}
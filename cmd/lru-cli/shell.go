@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	prompt = "lru> "
+	// Lines kept in the history file
+	historySize = 1000
+	// How long keys fetched for completion are reused
+	completionTTL = 5 * time.Second
+)
+
+// shell reads commands line by line. On a terminal the line can be edited,
+// previous lines are recalled with the arrow keys and keys are completed with Tab.
+func (c *cli) shell(stdin *os.File, stdout io.Writer) error {
+	fd := int(stdin.Fd())
+
+	if !term.IsTerminal(fd) {
+		return c.script(stdin)
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, state)
+
+	terminal := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{stdin, stdout}, prompt)
+
+	if width, height, err := term.GetSize(fd); err == nil {
+		terminal.SetSize(width, height)
+	}
+
+	history := loadHistory()
+	for _, line := range history.lines {
+		terminal.History.Add(line)
+	}
+
+	completer := &completer{cli: c}
+	terminal.AutoCompleteCallback = completer.complete
+
+	// Output is written through the terminal, it translates newlines in raw mode
+	c.stdout = terminal
+
+	for {
+		line, err := terminal.ReadLine()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		history.add(line)
+
+		if line == "exit" || line == "quit" {
+			return nil
+		}
+
+		// Commands run in cooked mode, so Ctrl+C interrupts them instead of the shell
+		term.Restore(fd, state)
+
+		err = c.run(line)
+		completer.reset()
+
+		if _, rawErr := term.MakeRaw(fd); rawErr != nil {
+			return rawErr
+		}
+
+		if err != nil {
+			fmt.Fprintf(terminal, "error: %v\n", err)
+		}
+	}
+}
+
+// script runs commands read from a pipe or a file, it stops at the first failing command
+func (c *cli) script(stdin io.Reader) error {
+	scanner := bufio.NewScanner(stdin)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if line == "exit" || line == "quit" {
+			return nil
+		}
+
+		if err := c.run(line); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// run executes a line of the shell until it completes or is interrupted
+func (c *cli) run(line string) error {
+	args, err := splitArgs(line)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	return c.execute(ctx, args)
+}
+
+// splitArgs splits a line into words like a shell, quotes group words and a backslash escapes a character
+func splitArgs(line string) ([]string, error) {
+	var (
+		args    []string
+		word    strings.Builder
+		inWord  bool
+		quote   rune
+		escaped bool
+	)
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t':
+			if inWord {
+				args = append(args, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 || escaped {
+		return nil, errors.New("unterminated quote or escape")
+	}
+
+	if inWord {
+		args = append(args, word.String())
+	}
+
+	return args, nil
+}
+
+// quoteArg quotes a word, so splitArgs returns it unchanged
+func quoteArg(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\"'\\") {
+		return s
+	}
+
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// completer completes command names and keys, keys are fetched from the list endpoint
+type completer struct {
+	cli *cli
+
+	mu        sync.Mutex
+	keys      []string
+	fetchedAt time.Time
+}
+
+// complete is a term.Terminal AutoCompleteCallback
+func (c *completer) complete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+
+	start := strings.LastIndexAny(line[:pos], " \t") + 1
+	word := line[start:pos]
+
+	var candidates []string
+
+	if strings.TrimSpace(line[:start]) == "" {
+		candidates = commands
+	} else if strings.HasPrefix(word, "-") {
+		return "", 0, false
+	} else {
+		candidates = c.completeKeys()
+	}
+
+	var matches []string
+
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, word) {
+			matches = append(matches, candidate)
+		}
+	}
+
+	if len(matches) == 0 {
+		return "", 0, false
+	}
+
+	completion := matches[0]
+
+	if len(matches) == 1 {
+		completion = quoteArg(completion) + " "
+	} else {
+		for _, match := range matches[1:] {
+			completion = commonPrefix(completion, match)
+		}
+
+		if len(completion) == len(word) {
+			return "", 0, false
+		}
+	}
+
+	newLine := line[:start] + completion + line[pos:]
+
+	return newLine, start + len(completion), true
+}
+
+func (c *completer) completeKeys() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if time.Since(c.fetchedAt) < completionTTL {
+		return c.keys
+	}
+
+	// Completion blocks the terminal, a slow server must not freeze it
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	keys, _, err := c.cli.client.GetAll(ctx)
+	if err != nil {
+		return c.keys
+	}
+
+	sort.Strings(keys)
+
+	c.keys = keys
+	c.fetchedAt = time.Now()
+
+	return c.keys
+}
+
+// reset forgets fetched keys, a command may have changed them
+func (c *completer) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.fetchedAt = time.Time{}
+}
+
+func commonPrefix(a, b string) string {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+
+	return a[:i]
+}
+
+// history persists lines of the shell in ~/.lru_cli_history
+type history struct {
+	path  string
+	lines []string
+}
+
+func loadHistory() *history {
+	h := &history{}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return h
+	}
+
+	h.path = filepath.Join(home, ".lru_cli_history")
+
+	data, err := os.ReadFile(h.path)
+	if err != nil {
+		return h
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			h.lines = append(h.lines, line)
+		}
+	}
+
+	// The file is only appended to while the shell runs, it is trimmed on the next start
+	if len(h.lines) > historySize {
+		h.lines = h.lines[len(h.lines)-historySize:]
+
+		os.WriteFile(h.path, []byte(strings.Join(h.lines, "\n")+"\n"), 0o600)
+	}
+
+	return h
+}
+
+// add appends a line to the history file, failures are ignored since history is a convenience
+func (h *history) add(line string) {
+	if h.path == "" {
+		return
+	}
+
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	fmt.Fprintln(f, line)
+}
@@ -4,15 +4,34 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"slices"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/skantay/lru-api/internal/api"
+	"github.com/skantay/lru-api/internal/audit"
+	"github.com/skantay/lru-api/internal/auth"
+	"github.com/skantay/lru-api/internal/authz"
 	"github.com/skantay/lru-api/internal/cache"
+	"github.com/skantay/lru-api/internal/grpcapi"
+	"github.com/skantay/lru-api/internal/health"
+	"github.com/skantay/lru-api/internal/httpcache"
+	"github.com/skantay/lru-api/internal/listener"
+	"github.com/skantay/lru-api/internal/logging"
+	"github.com/skantay/lru-api/internal/memcache"
+	"github.com/skantay/lru-api/internal/metrics"
+	"github.com/skantay/lru-api/internal/proxy"
+	"github.com/skantay/lru-api/internal/quota"
+	"github.com/skantay/lru-api/internal/ratelimit"
+	"github.com/skantay/lru-api/internal/resp"
+	"github.com/skantay/lru-api/internal/tlsconfig"
+	"github.com/skantay/lru-api/internal/tracing"
 	"github.com/skantay/lru-api/pkg/config"
 )
 
@@ -37,19 +56,67 @@ func main() {
 		level = slog.LevelError
 	}
 
-	log := slog.New(
-		slog.NewTextHandler(
-			os.Stdout,
-			&slog.HandlerOptions{
-				Level: level,
-			},
-		),
-	)
+	log, err := logging.New(os.Stdout, cfg.LogFormat, level)
+	if err != nil {
+		slog.Error(err.Error())
+
+		os.Exit(1)
+	}
+
+	accessLevel, err := logging.ParseLevel(cfg.AccessLogLevel)
+	if err != nil {
+		log.Error(err.Error())
+
+		os.Exit(1)
+	}
+
+	tracerProvider, err := tracing.New(context.Background(), tracing.Config{
+		Exporter:    cfg.TracingExporter,
+		Endpoint:    cfg.TracingEndpoint,
+		Insecure:    cfg.TracingInsecure,
+		SampleRatio: cfg.TracingSampleRatio,
+		ServiceName: "lru-api",
+	})
+	if err != nil {
+		log.Error(err.Error())
+
+		os.Exit(1)
+	}
+
+	metrics := metrics.New()
+
+	cacheOpts := []cache.Option{
+		cache.WithObserver(metrics.ObserveCacheOperation),
+		cache.WithTracerProvider(tracerProvider),
+		cache.WithCompression(cfg.CacheCompressionThreshold),
+	}
+
+	var apiOpts []api.Option
+
+	// Writes of the listeners are reported by the cache to the audit log, requests to the HTTP API are audited by the API
+	if cfg.AuditLogFile != "" {
+		auditLog, err := audit.Open(audit.Config{
+			Path:     cfg.AuditLogFile,
+			MaxSize:  cfg.AuditLogMaxSize,
+			MaxFiles: cfg.AuditLogMaxFiles,
+		})
+		if err != nil {
+			log.Error(err.Error())
+
+			os.Exit(1)
+		}
+
+		defer auditLog.Close()
+
+		cacheOpts = append(cacheOpts, cache.WithRecorder(auditLog))
+		apiOpts = append(apiOpts, api.WithAudit(auditLog))
+	}
 
 	cache, err := cache.New(
 		cfg.CacheSize,
 		time.Duration(cfg.DefaultCacheTTL)*time.Second,
 		log,
+		cacheOpts...,
 	)
 	if err != nil {
 		log.Error(err.Error())
@@ -57,7 +124,152 @@ func main() {
 		os.Exit(1)
 	}
 
-	handler := api.New(cache, log)
+	metrics.RegisterCache(cache)
+
+	checker := health.New()
+
+	// Stats takes the cache lock, a deadlocked cache does not answer in time
+	checker.AddLivenessCheck("cache", func(context.Context) error {
+		cache.Stats()
+
+		return nil
+	})
+
+	apiOpts = append(apiOpts,
+		api.WithMetrics(metrics),
+		api.WithTracerProvider(tracerProvider),
+		api.WithHealth(checker),
+		api.WithCompression(cfg.HTTPCompressionMinSize),
+		api.WithMaxBodySize(cfg.HTTPMaxBodySize),
+		api.WithAccessLog(accessLevel, cfg.AccessLogSampleRatio),
+	)
+
+	authenticator, err := auth.New(auth.Config{
+		APIKeys:      cfg.AuthAPIKeys,
+		APIKeysFile:  cfg.AuthAPIKeysFile,
+		HS256KeyFile: cfg.AuthJWTHS256KeyFile,
+		RS256KeyFile: cfg.AuthJWTRS256KeyFile,
+		JWKSFile:     cfg.AuthJWKSFile,
+		Issuer:       cfg.AuthJWTIssuer,
+		Audience:     cfg.AuthJWTAudience,
+		ClientCerts:  cfg.TLSClientCAFile != "",
+	})
+	if err != nil {
+		log.Error(err.Error())
+
+		os.Exit(1)
+	}
+
+	if authenticator != nil {
+		apiOpts = append(apiOpts, api.WithAuthenticator(authenticator))
+	} else {
+		log.Warn("Authentication is disabled, anyone who can reach the HTTP port can modify the cache")
+	}
+
+	// Credentials are only checked over HTTP, the other listeners let anyone in
+	listeners := unauthenticatedListeners(cfg)
+
+	if authenticator != nil && len(listeners) > 0 {
+		if !cfg.AllowUnauthenticatedListeners {
+			log.Error("Listeners without authentication cannot be started while authentication is configured, "+
+				"disable them or set ALLOW_UNAUTHENTICATED_LISTENERS", "listeners", listeners)
+
+			os.Exit(1)
+		}
+
+		log.Warn("UNAUTHENTICATED LISTENERS: anyone who can reach these ports can read, modify and flush the cache "+
+			"without credentials", "listeners", listeners)
+	}
+
+	if cfg.AuthzPolicyFile != "" {
+		if authenticator == nil {
+			log.Error("Authorization policy requires authentication to be configured")
+
+			os.Exit(1)
+		}
+
+		// The policy would only hold over HTTP, even ALLOW_UNAUTHENTICATED_LISTENERS does not lift this
+		if len(listeners) > 0 {
+			log.Error("Authorization policy cannot be enforced on the gRPC, RESP and memcached listeners, disable them",
+				"listeners", listeners)
+
+			os.Exit(1)
+		}
+
+		policy, err := authz.Load(cfg.AuthzPolicyFile)
+		if err != nil {
+			log.Error(err.Error())
+
+			os.Exit(1)
+		}
+
+		apiOpts = append(apiOpts, api.WithAuthorizer(policy))
+	}
+
+	var readLimiter, writeLimiter api.IRateLimiter
+
+	if cfg.RateLimitRead > 0 {
+		readLimiter = ratelimit.New(ratelimit.Limit{Rate: cfg.RateLimitRead, Burst: cfg.RateLimitReadBurst})
+	}
+
+	if cfg.RateLimitWrite > 0 {
+		writeLimiter = ratelimit.New(ratelimit.Limit{Rate: cfg.RateLimitWrite, Burst: cfg.RateLimitWriteBurst})
+	}
+
+	if readLimiter != nil || writeLimiter != nil {
+		apiOpts = append(apiOpts, api.WithRateLimits(readLimiter, writeLimiter))
+	}
+
+	// Long-lived requests (watch streams) are bound to this context,
+	// it is cancelled on shutdown so they do not hold the server open
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+	defer cancelBase()
+
+	if cfg.QuotaMaxEntries > 0 || cfg.QuotaMaxBytes > 0 || len(cfg.QuotaTenants) > 0 {
+		tenants, err := quota.ParseTenants(cfg.QuotaTenants)
+		if err != nil {
+			log.Error(err.Error())
+
+			os.Exit(1)
+		}
+
+		tracker := quota.New(quota.Config{
+			Default: quota.Limits{Entries: cfg.QuotaMaxEntries, Bytes: cfg.QuotaMaxBytes},
+			Tenants: tenants,
+		})
+
+		go tracker.Follow(baseCtx, cache, log)
+
+		// Writes of the other listeners and the proxy are not charged to anyone
+		uncharged := slices.Clone(listeners)
+		if cfg.ProxyPort != "" {
+			uncharged = append(uncharged, "proxy")
+		}
+
+		if len(uncharged) > 0 {
+			log.Warn("Quotas only cover writes over HTTP, these listeners are not limited", "listeners", uncharged)
+		}
+
+		apiOpts = append(apiOpts, api.WithQuota(tracker))
+	}
+
+	cacheControl, err := httpcache.ParseRule(cfg.HTTPCacheControl)
+	if err != nil {
+		log.Error(err.Error())
+
+		os.Exit(1)
+	}
+
+	cacheRules, err := httpcache.ParseRules(cfg.HTTPCacheRules)
+	if err != nil {
+		log.Error(err.Error())
+
+		os.Exit(1)
+	}
+
+	apiOpts = append(apiOpts, api.WithCachePolicy(httpcache.New(cacheControl, cacheRules)))
+
+	handler := api.New(cache, log, apiOpts...)
 
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%v", cfg.HTTPPort),
@@ -65,13 +277,66 @@ func main() {
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  30 * time.Second,
+		BaseContext: func(net.Listener) context.Context {
+			return baseCtx
+		},
+	}
+	server.RegisterOnShutdown(cancelBase)
+
+	serveHTTP := server.Serve
+
+	if cfg.TLSCertFile != "" {
+		certs, err := tlsconfig.New(tlsconfig.Config{
+			CertFile:     cfg.TLSCertFile,
+			KeyFile:      cfg.TLSKeyFile,
+			MinVersion:   cfg.TLSMinVersion,
+			CipherSuites: cfg.TLSCipherSuites,
+			ClientCAFile: cfg.TLSClientCAFile,
+			ClientAuth:   cfg.TLSClientAuth,
+		})
+		if err != nil {
+			log.Error(err.Error())
+
+			os.Exit(1)
+		}
+
+		server.TLSConfig = certs.TLSConfig()
+
+		serveHTTP = func(l net.Listener) error {
+			return server.ServeTLS(l, "", "")
+		}
+
+		go certs.Watch(baseCtx, time.Duration(cfg.TLSReloadInterval)*time.Second, log)
+
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+
+		go func() {
+			for range hup {
+				if err := certs.Reload(); err != nil {
+					log.Error("failed to reload TLS files", "error", err.Error())
+
+					continue
+				}
+
+				log.Info("TLS files reloaded")
+			}
+		}()
+	} else if cfg.TLSClientCAFile != "" {
+		log.Error("Mutual TLS requires TLS_CERT_FILE")
+
+		os.Exit(1)
 	}
 
 	done := make(chan os.Signal, 1)
 	signal.Notify(done, syscall.SIGTERM, syscall.SIGINT)
 
+	var httpReady health.Flag
+
+	checker.AddReadinessCheck("http", httpReady.Check)
+
 	go func() {
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := serve(server.Addr, &httpReady, serveHTTP); err != nil && err != http.ErrServerClosed {
 			log.Error("ListenAndServe: " + err.Error())
 
 			select {
@@ -81,17 +346,205 @@ func main() {
 		}
 	}()
 
-	log.Info("Starting server", "port", cfg.HTTPPort)
+	var grpcServer *grpcapi.Server
+
+	if cfg.GRPCPort != "" {
+		grpcServer = grpcapi.New(cache, log)
+
+		var grpcReady health.Flag
+
+		checker.AddReadinessCheck("grpc", grpcReady.Check)
+
+		go func() {
+			if err := serve(fmt.Sprintf(":%v", cfg.GRPCPort), &grpcReady, grpcServer.Serve); err != nil {
+				log.Error("gRPC ListenAndServe: " + err.Error())
+
+				select {
+				case done <- syscall.SIGTERM:
+				default:
+				}
+			}
+		}()
+
+		log.Info("Starting gRPC server", "port", cfg.GRPCPort)
+	}
+
+	var respServer *resp.Server
+
+	if cfg.RESPPort != "" {
+		respServer = resp.New(cache, log, resp.Config{
+			Addr:        fmt.Sprintf(":%v", cfg.RESPPort),
+			MaxConns:    cfg.RESPMaxConnections,
+			IdleTimeout: time.Duration(cfg.RESPIdleTimeout) * time.Second,
+		})
+
+		var respReady health.Flag
+
+		checker.AddReadinessCheck("resp", respReady.Check)
+
+		go func() {
+			if err := serve(fmt.Sprintf(":%v", cfg.RESPPort), &respReady, respServer.Serve); err != nil && err != listener.ErrServerClosed {
+				log.Error("RESP ListenAndServe: " + err.Error())
+
+				select {
+				case done <- syscall.SIGTERM:
+				default:
+				}
+			}
+		}()
+
+		log.Info("Starting RESP listener", "port", cfg.RESPPort)
+	}
+
+	var memcacheServer *memcache.Server
+
+	if cfg.MemcachePort != "" {
+		memcacheServer = memcache.New(cache, log, memcache.Config{
+			Addr:        fmt.Sprintf(":%v", cfg.MemcachePort),
+			MaxConns:    cfg.MemcacheMaxConnections,
+			IdleTimeout: time.Duration(cfg.MemcacheIdleTimeout) * time.Second,
+		})
+
+		var memcacheReady health.Flag
+
+		checker.AddReadinessCheck("memcache", memcacheReady.Check)
+
+		go func() {
+			if err := serve(fmt.Sprintf(":%v", cfg.MemcachePort), &memcacheReady, memcacheServer.Serve); err != nil && err != listener.ErrServerClosed {
+				log.Error("Memcached ListenAndServe: " + err.Error())
+
+				select {
+				case done <- syscall.SIGTERM:
+				default:
+				}
+			}
+		}()
+
+		log.Info("Starting memcached listener", "port", cfg.MemcachePort)
+	}
+
+	var proxyServer *http.Server
+
+	if cfg.ProxyPort != "" {
+		upstream, err := url.Parse(cfg.ProxyUpstream)
+		if err != nil || upstream.Scheme == "" || upstream.Host == "" {
+			log.Error("Caching proxy requires an absolute PROXY_UPSTREAM URL", "upstream", cfg.ProxyUpstream)
+
+			os.Exit(1)
+		}
+
+		proxyServer = &http.Server{
+			Addr: fmt.Sprintf(":%v", cfg.ProxyPort),
+			Handler: proxy.New(cache, log, proxy.Config{
+				Upstream:    upstream,
+				KeyPrefix:   cfg.ProxyKeyPrefix,
+				MaxBodySize: cfg.ProxyMaxBodySize,
+				Timeout:     time.Duration(cfg.ProxyTimeout) * time.Second,
+			}),
+			// Responses take as long as the upstream, its requests time out instead
+			ReadHeaderTimeout: 10 * time.Second,
+			IdleTimeout:       30 * time.Second,
+		}
+
+		var proxyReady health.Flag
+
+		checker.AddReadinessCheck("proxy", proxyReady.Check)
+
+		go func() {
+			if err := serve(proxyServer.Addr, &proxyReady, proxyServer.Serve); err != nil && err != http.ErrServerClosed {
+				log.Error("Proxy ListenAndServe: " + err.Error())
+
+				select {
+				case done <- syscall.SIGTERM:
+				default:
+				}
+			}
+		}()
+
+		log.Info("Starting caching proxy", "port", cfg.ProxyPort, "upstream", upstream.String())
+	}
+
+	log.Info("Starting server", "port", cfg.HTTPPort, "tls", cfg.TLSCertFile != "")
 	now := time.Now()
-	log.Info("Signal is captured", "signal", <-done)
+	sig := <-done
+	log.Info("Signal is captured", "signal", sig)
+
+	// Readiness fails from now on, load balancers get time to notice it before the servers stop.
+	// An interactive interrupt does not wait.
+	checker.Drain()
+
+	if sig == syscall.SIGTERM && cfg.DrainDelay > 0 {
+		log.Info("Draining...", "delay", time.Duration(cfg.DrainDelay)*time.Second)
+		time.Sleep(time.Duration(cfg.DrainDelay) * time.Second)
+	}
+
 	log.Info("Server is shutting down...")
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	if grpcServer != nil {
+		if err := grpcServer.Shutdown(ctx); err != nil {
+			log.Error("gRPC server Shutdown Failed", "error", err.Error())
+		}
+	}
+
+	if respServer != nil {
+		if err := respServer.Shutdown(ctx); err != nil {
+			log.Error("RESP listener Shutdown Failed", "error", err.Error())
+		}
+	}
+
+	if memcacheServer != nil {
+		if err := memcacheServer.Shutdown(ctx); err != nil {
+			log.Error("Memcached listener Shutdown Failed", "error", err.Error())
+		}
+	}
+
+	if proxyServer != nil {
+		if err := proxyServer.Shutdown(ctx); err != nil {
+			log.Error("Proxy Shutdown Failed", "error", err.Error())
+		}
+	}
+
 	if err := server.Shutdown(ctx); err != nil {
 		log.Error("Server Shutdown Failed", "error", err.Error())
+	} else if err := handler.Shutdown(ctx); err != nil {
+		log.Error("WebSocket connections Shutdown Failed", "error", err.Error())
 	} else {
 		log.Info("Server exited properly", "shutdown duration", time.Since(now))
 	}
+
+	// Spans of the last requests are flushed after the servers stopped
+	if err := tracerProvider.Shutdown(ctx); err != nil {
+		log.Error("Tracer provider Shutdown Failed", "error", err.Error())
+	}
+}
+
+// unauthenticatedListeners returns the names of the enabled listeners that do not authenticate clients
+func unauthenticatedListeners(cfg *config.Config) []string {
+	var names []string
+
+	for name, port := range map[string]string{"grpc": cfg.GRPCPort, "resp": cfg.RESPPort, "memcache": cfg.MemcachePort} {
+		if port != "" {
+			names = append(names, name)
+		}
+	}
+
+	slices.Sort(names)
+
+	return names
+}
+
+// serve binds addr and serves on it, ready is set while the listener is up
+func serve(addr string, ready *health.Flag, run func(net.Listener) error) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	ready.Set()
+	defer ready.Unset()
+
+	return run(l)
 }
@@ -4,17 +4,25 @@ package api
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
 	"time"
 
+	"github.com/skantay/lru-api/internal/authz"
 	"github.com/skantay/lru-api/internal/cache"
+	"github.com/skantay/lru-api/internal/httpcache"
+	"github.com/skantay/lru-api/internal/logging"
+	"github.com/skantay/lru-api/internal/tracing"
 
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // ILRUCache интерфейс LRU-кэша. Поддерживает только строковые ключи. Поддерживает только простые типы данных в значениях.
@@ -31,33 +39,235 @@ type ILRUCache interface {
 	EvictAll(ctx context.Context) error
 }
 
+// IMetrics records handled requests and serves collected metrics
+type IMetrics interface {
+	// ObserveRequest учет обработанного запроса, route - шаблон маршрута chi
+	ObserveRequest(method, route string, status int, duration time.Duration)
+	// ServeHTTP выдача метрик в формате Prometheus
+	ServeHTTP(w http.ResponseWriter, r *http.Request)
+}
+
+// IHealth serves the probes of the process
+type IHealth interface {
+	// Healthz процесс запущен и обслуживает HTTP
+	Healthz(w http.ResponseWriter, r *http.Request)
+	// Livez результаты проверок живости
+	Livez(w http.ResponseWriter, r *http.Request)
+	// Readyz результаты проверок готовности принимать трафик
+	Readyz(w http.ResponseWriter, r *http.Request)
+}
+
 type api struct {
-	cache ILRUCache
+	cache   ILRUCache
+	watcher IWatcher
+	updater IUpdater
+	bulk    IBulk
+	entries IEntryGetter
+	metrics IMetrics
+	health  IHealth
+
+	authenticator IAuthenticator
+	authorizer    IAuthorizer
+
+	readLimiter  IRateLimiter
+	writeLimiter IRateLimiter
+	quota        IQuota
+
+	auditor IAuditor
+
+	cachePolicy *httpcache.Policy
+
+	// compressMinSize is the smallest response compressed, 0 disables compression
+	compressMinSize int
+	// maxBodySize bounds the decoded body of a create, 0 is unlimited
+	maxBodySize int64
+
+	// Handled requests are logged at accessLevel, accessSampleRatio of them
+	accessLevel       slog.Level
+	accessSampleRatio float64
+
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+
+	// closing is closed on Shutdown, conns tracks hijacked (WebSocket) connections
+	closing chan struct{}
+	conns   sync.WaitGroup
 
 	log *slog.Logger
 }
 
+// Handler is the HTTP handler of the API.
+type Handler struct {
+	http.Handler
+
+	api  *api
+	once sync.Once
+}
+
+// Shutdown closes WebSocket connections and waits for them to finish.
+// http.Server.Shutdown does not track hijacked connections, so it must be called in addition to it.
+func (h *Handler) Shutdown(ctx context.Context) error {
+	h.once.Do(func() {
+		close(h.api.closing)
+	})
+
+	done := make(chan struct{})
+
+	go func() {
+		h.api.conns.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Option configures optional features of the API
+type Option func(*api)
+
+// WithMetrics records every request and serves metrics on /metrics
+func WithMetrics(metrics IMetrics) Option {
+	return func(a *api) {
+		a.metrics = metrics
+	}
+}
+
+// WithHealth serves the probes on /healthz, /livez and /readyz
+func WithHealth(health IHealth) Option {
+	return func(a *api) {
+		a.health = health
+	}
+}
+
 // New creates a new API server with the provided LRU cache and logger.
-func New(ILRUCache ILRUCache, log *slog.Logger) http.Handler {
+func New(ILRUCache ILRUCache, log *slog.Logger, opts ...Option) *Handler {
 	api := &api{
-		cache: ILRUCache,
-		log:   log,
+		cache:      ILRUCache,
+		closing:    make(chan struct{}),
+		tracer:     defaultTracer(),
+		propagator: tracing.Propagator(),
+
+		accessLevel:       slog.LevelDebug,
+		accessSampleRatio: 1,
+
+		maxBodySize: defaultMaxBodySize,
+
+		log: slog.New(logging.NewHandler(log.Handler())),
+	}
+
+	for _, opt := range opts {
+		opt(api)
+	}
+
+	if watcher, ok := ILRUCache.(IWatcher); ok {
+		api.watcher = watcher
+	}
+
+	if updater, ok := ILRUCache.(IUpdater); ok {
+		api.updater = updater
+	}
+
+	if bulk, ok := ILRUCache.(IBulk); ok {
+		api.bulk = bulk
+	}
+
+	if entries, ok := ILRUCache.(IEntryGetter); ok {
+		api.entries = entries
+	}
+
+	if api.cachePolicy == nil {
+		api.cachePolicy = httpcache.New(httpcache.Rule{Mode: httpcache.ModePrivate}, nil)
 	}
 
 	router := chi.NewMux()
 
+	router.NotFound(api.notFound)
+	router.MethodNotAllowed(api.methodNotAllowed)
+
+	router.Use(api.requestID)
+	router.Use(api.tracing)
 	router.Use(api.logger)
+
+	if api.compressMinSize > 0 {
+		router.Use(api.compress)
+	}
+
 	router.Use(middleware.Recoverer)
 
+	if api.metrics != nil {
+		router.Get("/metrics", api.metrics.ServeHTTP)
+	}
+
+	if api.health != nil {
+		router.Get("/healthz", api.health.Healthz)
+		router.Get("/livez", api.health.Livez)
+		router.Get("/readyz", api.health.Readyz)
+	}
+
 	router.Route("/api", func(r chi.Router) {
-		r.Get("/lru/{key}", api.get)
-		r.Get("/lru", api.getAll)
-		r.Post("/lru", api.create)
-		r.Delete("/lru/{key}", api.delete)
-		r.Delete("/lru", api.flush)
+		// The description of the API is public like the probes
+		r.Get("/openapi.json", api.openAPI)
+		r.Get("/docs", api.docs)
+
+		r.Group(func(r chi.Router) {
+			if api.auditor != nil {
+				r.Use(api.audit)
+			}
+
+			if api.authenticator != nil {
+				r.Use(api.identify)
+			}
+
+			// Limited before failed authentications are rejected, so credentials cannot be guessed at full speed
+			if api.readLimiter != nil || api.writeLimiter != nil {
+				r.Use(api.rateLimit)
+			}
+
+			if api.authenticator != nil {
+				r.Use(api.authenticate)
+			}
+
+			r.Get("/lru/{key}", api.get)
+			r.Get("/lru", api.getAll)
+			r.With(api.decompress(api.maxBodySize)).Post("/lru", api.create)
+			r.Delete("/lru/{key}", api.delete)
+			r.Delete("/lru", api.flush)
+
+			if api.watcher != nil {
+				r.Get("/watch", api.watch)
+			}
+
+			r.Get("/ws", api.ws)
+
+			if api.bulk != nil {
+				r.Get("/export", api.export)
+				r.With(api.decompress(0)).Post("/import", api.importEntries)
+			}
+
+			if api.auditor != nil {
+				r.Get("/admin/audit", api.auditRecords)
+				r.Get("/admin/audit/verify", api.auditVerify)
+			}
+
+			if api.updater != nil {
+				r.Route("/v2", func(r chi.Router) {
+					r.With(api.decompress(api.maxBodySize)).Put("/keys/{key}", api.putV2)
+					r.Get("/keys/{key}", api.getV2)
+					r.Head("/keys/{key}", api.getV2)
+					r.Delete("/keys/{key}", api.deleteV2)
+				})
+			}
+		})
 	})
 
-	return router
+	return &Handler{
+		Handler: router,
+		api:     api,
+	}
 }
 
 type createRequest struct {
@@ -70,36 +280,81 @@ type createRequest struct {
 func (a *api) create(w http.ResponseWriter, r *http.Request) {
 	data, err := io.ReadAll(r.Body)
 	if err != nil {
+		a.log.DebugContext(r.Context(), "bad request", "error", err.Error())
+
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			a.problem(w, r, errBodyTooLarge, "body is larger than "+strconv.FormatInt(maxBytesErr.Limit, 10)+" bytes")
+
+			return
+		}
+
+		a.problem(w, r, errUnreadableBody, err.Error())
+
 		return
 	}
 
+	// Bodies of other media types are JSON, as they were before bodies were negotiated
+	c := requestCodec(r.Header.Get("Content-Type"))
+	if c == nil {
+		c = jsonCodec
+	}
+
 	var request createRequest
 
-	if err := json.Unmarshal(data, &request); err != nil {
-		a.log.Debug("bad request", "error", err.Error())
+	if err := a.unmarshal(r.Context(), c, data, &request); err != nil {
+		a.log.DebugContext(r.Context(), "bad request", "error", err.Error())
+
+		a.problem(w, r, c.malformed, err.Error())
+
+		return
+	}
 
-		w.WriteHeader(http.StatusBadRequest)
+	if request.Key == "" {
+		a.log.DebugContext(r.Context(), "bad request", "key", request.Key)
+
+		a.problem(w, r, errEmptyKey, "")
 
 		return
 	}
 
-	if request.Key == "" || request.Value == nil {
-		a.log.Debug("bad request", "key", request.Key, "value", request.Value)
+	setAuditKey(r.Context(), request.Key)
+
+	if request.Value == nil {
+		a.log.DebugContext(r.Context(), "bad request", "key", request.Key, "value", request.Value)
 
-		w.WriteHeader(http.StatusBadRequest)
+		a.problem(w, r, errNullValue, "")
 
 		return
 	}
 
+	if !a.authorize(w, r, authz.OpPut, request.Key) {
+		return
+	}
+
+	rollback := func() {}
+
+	if a.quota != nil {
+		var err error
+
+		if rollback, err = a.quota.Reserve(principalOrIP(r), request.Key, cache.SizeOf(request.Key, request.Value)); err != nil {
+			a.log.InfoContext(r.Context(), "quota exceeded", "client", principalOrIP(r), "key", request.Key, "error", err.Error())
+
+			a.problem(w, r, errQuotaExceeded, err.Error())
+
+			return
+		}
+	}
+
 	if err := a.cache.Put(
 		r.Context(),
 		request.Key,
 		request.Value,
 		time.Duration(request.TTLSeconds)*time.Second,
 	); err != nil {
-		a.log.Error(err.Error())
+		rollback()
 
-		w.WriteHeader(http.StatusInternalServerError)
+		a.cacheError(w, r, err)
 
 		return
 	}
@@ -113,42 +368,27 @@ type getResponse struct {
 	ExpiresAt int64       `json:"expires_at"`
 }
 
-// get handles a retrieval of a node/item from cache
+// get handles a retrieval of a node/item from cache, conditional requests are answered with 304
 func (a *api) get(w http.ResponseWriter, r *http.Request) {
-	key := chi.URLParam(r, "key")
-	a.log.Debug(key)
-
-	value, expiresAt, err := a.cache.Get(r.Context(), key)
-	if err != nil {
-		if errors.Is(err, cache.ErrKeyDoesNotExist) {
-			w.WriteHeader(http.StatusNotFound)
-
-			return
-		}
-
-		w.WriteHeader(http.StatusInternalServerError)
+	key := keyParam(r)
+	a.log.DebugContext(r.Context(), key)
 
+	if !a.authorize(w, r, authz.OpGet, key) {
 		return
 	}
 
-	response := getResponse{
-		Key:       key,
-		Value:     value,
-		ExpiresAt: expiresAt.Unix(),
-	}
-
-	data, err := json.Marshal(response)
+	entry, err := a.getEntry(r.Context(), key)
 	if err != nil {
-		a.log.Error(err.Error())
-
-		w.WriteHeader(http.StatusInternalServerError)
+		a.cacheError(w, r, err)
 
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(data)
+	a.respondCacheable(w, r, entry, getResponse{
+		Key:       key,
+		Value:     entry.Value,
+		ExpiresAt: entry.ExpiresAt.Unix(),
+	})
 }
 
 type getAllResponse struct {
@@ -160,49 +400,57 @@ type getAllResponse struct {
 func (a *api) getAll(w http.ResponseWriter, r *http.Request) {
 	keys, values, err := a.cache.GetAll(r.Context())
 	if err != nil {
-		a.log.Error(err.Error())
-
-		w.WriteHeader(http.StatusInternalServerError)
+		a.cacheError(w, r, err)
 
 		return
 	}
 
+	keys, values = a.filterPermitted(r.Context(), keys, values)
+
 	if len(keys) == 0 && len(values) == 0 {
 		w.WriteHeader(http.StatusNoContent)
 
 		return
 	}
 
-	response := getAllResponse{
+	a.respond(w, r, http.StatusOK, getAllResponse{
 		Keys:   keys,
 		Values: values,
-	}
+	})
+}
 
-	data, err := json.Marshal(response)
-	if err != nil {
-		a.log.Error(err.Error())
+// filterPermitted drops nodes/items the principal may not get
+func (a *api) filterPermitted(ctx context.Context, keys []string, values []interface{}) ([]string, []interface{}) {
+	if a.authorizer == nil {
+		return keys, values
+	}
 
-		w.WriteHeader(http.StatusInternalServerError)
+	filteredKeys := keys[:0]
+	filteredValues := values[:0]
 
-		return
+	for i, key := range keys {
+		if a.permitted(ctx, authz.OpGet, key) {
+			filteredKeys = append(filteredKeys, key)
+			filteredValues = append(filteredValues, values[i])
+		}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(data)
+	return filteredKeys, filteredValues
 }
 
 // delete handles a deletion of a node/item in cache
 func (a *api) delete(w http.ResponseWriter, r *http.Request) {
-	key := chi.URLParam(r, "key")
-	a.log.Debug(key)
+	key := keyParam(r)
+	a.log.DebugContext(r.Context(), key)
+
+	if !a.authorize(w, r, authz.OpEvict, key) {
+		return
+	}
 
 	if _, err := a.cache.Evict(r.Context(), key); err != nil {
-		if errors.Is(err, cache.ErrKeyDoesNotExist) {
-			w.WriteHeader(http.StatusNotFound)
+		a.cacheError(w, r, err)
 
-			return
-		}
+		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
@@ -210,11 +458,30 @@ func (a *api) delete(w http.ResponseWriter, r *http.Request) {
 
 // flush handles a deletion of all nodes/items in cache
 func (a *api) flush(w http.ResponseWriter, r *http.Request) {
+	if !a.authorize(w, r, authz.OpFlush, "") {
+		return
+	}
+
 	if err := a.cache.EvictAll(r.Context()); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		a.cacheError(w, r, err)
 
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// keyParam returns the unescaped key URL parameter.
+// chi routes on the escaped path when it is present, so keys containing e.g. '/' arrive escaped.
+func keyParam(r *http.Request) string {
+	key := chi.URLParam(r, "key")
+	if r.URL.RawPath == "" {
+		return key
+	}
+
+	if unescaped, err := url.PathUnescape(key); err == nil {
+		return unescaped
+	}
+
+	return key
+}
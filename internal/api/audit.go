@@ -0,0 +1,240 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/skantay/lru-api/internal/audit"
+	"github.com/skantay/lru-api/internal/authz"
+	"github.com/skantay/lru-api/internal/logging"
+
+	"github.com/go-chi/chi/middleware"
+)
+
+var errInvalidAuditFilter = &apiError{http.StatusBadRequest, "invalid_audit_filter", "Audit filter is invalid"}
+
+// Records returned by a query unless limit is set, and at most
+const (
+	auditDefaultLimit = 100
+	auditMaxLimit     = 1000
+)
+
+// IAuditor keeps records of operations changing the cache
+type IAuditor interface {
+	// Append запись операции в журнал аудита
+	Append(record audit.Record) (audit.Record, error)
+	// Query записи журнала, подходящие под фильтр, от старых к новым
+	Query(filter audit.Filter) ([]audit.Record, error)
+	// Verify проверка цепочки хэшей записей, возвращает число проверенных записей
+	Verify() (int, error)
+}
+
+// WithAudit records creates, deletes, flushes, imports and admin actions, including denied ones,
+// and serves the records on /api/admin/audit
+func WithAudit(auditor IAuditor) Option {
+	return func(a *api) {
+		a.auditor = auditor
+	}
+}
+
+// auditActions maps audited routes to their actions, other routes are not recorded
+var auditActions = map[string]string{
+	http.MethodPost + " /api/lru":               audit.ActionCreate,
+	http.MethodDelete + " /api/lru/{key}":       audit.ActionDelete,
+	http.MethodDelete + " /api/lru":             audit.ActionFlush,
+	http.MethodPut + " /api/v2/keys/{key}":      audit.ActionCreate,
+	http.MethodDelete + " /api/v2/keys/{key}":   audit.ActionDelete,
+	http.MethodPost + " /api/import":            audit.ActionImport,
+	http.MethodGet + " /api/admin/audit":        audit.ActionAuditQuery,
+	http.MethodGet + " /api/admin/audit/verify": audit.ActionAuditVerify,
+}
+
+// auditInfo collects details of an audited request set by handlers
+type auditInfo struct {
+	key    string
+	detail string
+}
+
+type auditInfoKey struct{}
+
+func auditInfoFromContext(ctx context.Context) *auditInfo {
+	info, _ := ctx.Value(auditInfoKey{}).(*auditInfo)
+
+	return info
+}
+
+// setAuditKey records the key of a request that does not have it in its path
+func setAuditKey(ctx context.Context, key string) {
+	if info := auditInfoFromContext(ctx); info != nil {
+		info.key = key
+	}
+}
+
+// setAuditDetail records what the request did, the code of the problem is recorded otherwise
+func setAuditDetail(ctx context.Context, detail string) {
+	if info := auditInfoFromContext(ctx); info != nil {
+		info.detail = detail
+	}
+}
+
+// audit records audited routes once they are handled.
+// It runs before authentication, so requests without valid credentials are recorded as denied.
+func (a *api) audit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := &auditInfo{}
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), auditInfoKey{}, info)))
+
+		// Routes of subrouters are matched inside next
+		action, ok := auditActions[r.Method+" "+routePattern(r)]
+		if !ok {
+			return
+		}
+
+		key := info.key
+		if key == "" {
+			key = keyParam(r)
+		}
+
+		status := status(ww, r)
+
+		record := audit.Record{
+			Action:    action,
+			ClientIP:  clientIP(r),
+			RequestID: logging.RequestID(r.Context()),
+			Key:       key,
+			Outcome:   auditOutcome(status),
+			Status:    status,
+			Detail:    info.detail,
+		}
+
+		if info := requestInfoFromContext(r.Context()); info != nil {
+			record.Principal = info.principal
+		}
+
+		a.appendAudit(r.Context(), record)
+	})
+}
+
+// auditOutcome tells the outcome of a request by its status
+func auditOutcome(status int) string {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return audit.OutcomeDenied
+	case status >= http.StatusBadRequest:
+		return audit.OutcomeFailure
+	}
+
+	return audit.OutcomeSuccess
+}
+
+// appendAudit writes the record, the operation has already been done, so failures are only logged
+func (a *api) appendAudit(ctx context.Context, record audit.Record) {
+	if _, err := a.auditor.Append(record); err != nil {
+		a.log.ErrorContext(ctx, "audit record not written", "action", record.Action, "key", record.Key, "error", err.Error())
+	}
+}
+
+type auditResponse struct {
+	Records []audit.Record `json:"records"`
+}
+
+// auditRecords serves the records matching the query, from the oldest
+func (a *api) auditRecords(w http.ResponseWriter, r *http.Request) {
+	if !a.authorize(w, r, authz.OpAdmin, "") {
+		return
+	}
+
+	filter, err := parseAuditFilter(r.URL.Query())
+	if err != nil {
+		a.problem(w, r, errInvalidAuditFilter, err.Error())
+
+		return
+	}
+
+	records, err := a.auditor.Query(filter)
+	if err != nil {
+		a.log.ErrorContext(r.Context(), err.Error())
+
+		a.problem(w, r, errInternal, "")
+
+		return
+	}
+
+	setAuditDetail(r.Context(), strconv.Itoa(len(records))+" records")
+
+	a.respond(w, r, http.StatusOK, auditResponse{Records: records})
+}
+
+// parseAuditFilter parses key, action, principal, listener, since, until, after and limit
+func parseAuditFilter(query url.Values) (audit.Filter, error) {
+	filter := audit.Filter{
+		Key:       query.Get("key"),
+		Action:    query.Get("action"),
+		Principal: query.Get("principal"),
+		Listener:  query.Get("listener"),
+		Limit:     auditDefaultLimit,
+	}
+
+	for name, bound := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := query.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return audit.Filter{}, errors.New(name + " is not an RFC 3339 time")
+			}
+
+			*bound = t
+		}
+	}
+
+	if value := query.Get("after"); value != "" {
+		after, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return audit.Filter{}, errors.New("after is not a sequence number")
+		}
+
+		filter.After = after
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > auditMaxLimit {
+			return audit.Filter{}, errors.New("limit is not between 1 and " + strconv.Itoa(auditMaxLimit))
+		}
+
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
+
+type auditVerification struct {
+	Records int    `json:"records"`
+	Valid   bool   `json:"valid"`
+	Error   string `json:"error,omitempty"`
+}
+
+// auditVerify checks the hash chain of the records kept
+func (a *api) auditVerify(w http.ResponseWriter, r *http.Request) {
+	if !a.authorize(w, r, authz.OpAdmin, "") {
+		return
+	}
+
+	count, err := a.auditor.Verify()
+
+	response := auditVerification{Records: count, Valid: err == nil}
+	if err != nil {
+		a.log.ErrorContext(r.Context(), "audit chain broken", "error", err.Error())
+
+		response.Error = err.Error()
+	}
+
+	setAuditDetail(r.Context(), strconv.FormatBool(response.Valid))
+
+	a.respond(w, r, http.StatusOK, response)
+}
@@ -0,0 +1,164 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skantay/lru-api/internal/audit"
+	"github.com/skantay/lru-api/internal/auth"
+	"github.com/skantay/lru-api/internal/authz"
+	"github.com/skantay/lru-api/internal/cache"
+	"github.com/skantay/lru-api/internal/cache/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+// userHeader authenticates the principal named by X-User
+type userHeader struct{}
+
+func (userHeader) Authenticate(r *http.Request) (auth.Principal, error) {
+	if user := r.Header.Get("X-User"); user != "" {
+		return auth.Principal{Subject: user}, nil
+	}
+
+	return auth.Principal{}, auth.ErrMissingCredentials
+}
+
+// adminOnly lets admin run every operation and the others everything but flush and admin
+type adminOnly struct{}
+
+func (adminOnly) Allowed(principal auth.Principal, op authz.Operation, key string) bool {
+	return principal.Subject == "admin" || (op != authz.OpFlush && op != authz.OpAdmin)
+}
+
+func TestAudit(t *testing.T) {
+	c, err := cache.New(10, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	auditLog, err := audit.Open(audit.Config{Path: filepath.Join(t.TempDir(), "audit.log")})
+	assert.NoError(t, err)
+
+	defer auditLog.Close()
+
+	handler := New(c, slog.New(slog.NewTextHandler(io.Discard, nil)),
+		WithAuthenticator(userHeader{}), WithAuthorizer(adminOnly{}), WithAudit(auditLog))
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		user   string
+		record *audit.Record
+	}{
+		{name: "create", method: http.MethodPost, target: "/api/lru", body: `{"key":"a","value":1}`, user: "alice",
+			record: &audit.Record{Action: audit.ActionCreate, Principal: "alice", Key: "a", Outcome: audit.OutcomeSuccess, Status: http.StatusCreated}},
+		{name: "get is not audited", method: http.MethodGet, target: "/api/lru/a", user: "alice"},
+		{name: "put", method: http.MethodPut, target: "/api/v2/keys/b", body: `{"value":1}`, user: "alice",
+			record: &audit.Record{Action: audit.ActionCreate, Principal: "alice", Key: "b", Outcome: audit.OutcomeSuccess, Status: http.StatusCreated}},
+		{name: "delete", method: http.MethodDelete, target: "/api/lru/a", user: "alice",
+			record: &audit.Record{Action: audit.ActionDelete, Principal: "alice", Key: "a", Outcome: audit.OutcomeSuccess, Status: http.StatusNoContent}},
+		{name: "delete missing", method: http.MethodDelete, target: "/api/v2/keys/a", user: "alice",
+			record: &audit.Record{Action: audit.ActionDelete, Principal: "alice", Key: "a", Outcome: audit.OutcomeFailure, Status: http.StatusNotFound, Detail: "key_not_found"}},
+		{name: "flush forbidden", method: http.MethodDelete, target: "/api/lru", user: "alice",
+			record: &audit.Record{Action: audit.ActionFlush, Principal: "alice", Outcome: audit.OutcomeDenied, Status: http.StatusForbidden, Detail: "forbidden"}},
+		{name: "flush unauthenticated", method: http.MethodDelete, target: "/api/lru",
+			record: &audit.Record{Action: audit.ActionFlush, Outcome: audit.OutcomeDenied, Status: http.StatusUnauthorized, Detail: "unauthenticated"}},
+		{name: "flush", method: http.MethodDelete, target: "/api/lru", user: "admin",
+			record: &audit.Record{Action: audit.ActionFlush, Principal: "admin", Outcome: audit.OutcomeSuccess, Status: http.StatusNoContent}},
+		{name: "import", method: http.MethodPost, target: "/api/import", body: `{"key":"c","value":1}` + "\n" + `{"key":""}` + "\n", user: "admin",
+			record: &audit.Record{Action: audit.ActionImport, Principal: "admin", Outcome: audit.OutcomeSuccess, Status: http.StatusOK, Detail: "1 imported, 1 rejected"}},
+		{name: "query forbidden", method: http.MethodGet, target: "/api/admin/audit", user: "alice",
+			record: &audit.Record{Action: audit.ActionAuditQuery, Principal: "alice", Outcome: audit.OutcomeDenied, Status: http.StatusForbidden, Detail: "forbidden"}},
+	}
+
+	for _, test := range tests {
+		id := "req-" + strings.ReplaceAll(test.name, " ", "-")
+
+		r := httptest.NewRequest(test.method, test.target, strings.NewReader(test.body))
+		r.Header.Set("X-Request-ID", id)
+		if test.user != "" {
+			r.Header.Set("X-User", test.user)
+		}
+
+		before, err := auditLog.Query(audit.Filter{})
+		assert.NoError(t, err, test.name)
+
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+
+		records, err := auditLog.Query(audit.Filter{After: uint64(len(before))})
+		assert.NoError(t, err, test.name)
+
+		if test.record == nil {
+			assert.Empty(t, records, test.name)
+
+			continue
+		}
+
+		if !assert.Len(t, records, 1, test.name) {
+			continue
+		}
+
+		record := records[0]
+		assert.Equal(t, "192.0.2.1", record.ClientIP, test.name)
+		assert.Equal(t, id, record.RequestID, test.name)
+		assert.False(t, record.Time.IsZero(), test.name)
+
+		test.record.Seq, test.record.Time, test.record.ClientIP, test.record.RequestID = record.Seq, record.Time, record.ClientIP, record.RequestID
+		test.record.PrevHash, test.record.Hash = record.PrevHash, record.Hash
+
+		assert.Equal(t, *test.record, record, test.name)
+	}
+
+	// Records are served to admins
+	query := func(target string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.Header.Set("X-User", "admin")
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		return w
+	}
+
+	w := query("/api/admin/audit?key=a&action=delete")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response auditResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+	if assert.Len(t, response.Records, 2) {
+		assert.Equal(t, audit.OutcomeSuccess, response.Records[0].Outcome)
+		assert.Equal(t, audit.OutcomeFailure, response.Records[1].Outcome)
+	}
+
+	w = query("/api/admin/audit?since=" + time.Now().Add(time.Hour).Format(time.RFC3339))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"records":[]}`, w.Body.String())
+
+	for _, target := range []string{"/api/admin/audit?limit=0", "/api/admin/audit?since=yesterday", "/api/admin/audit?after=x"} {
+		w = query(target)
+		assert.Equal(t, http.StatusBadRequest, w.Code, target)
+		assert.Contains(t, w.Body.String(), `"code":"invalid_audit_filter"`, target)
+	}
+
+	w = query("/api/admin/audit/verify")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var verification auditVerification
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &verification))
+	assert.True(t, verification.Valid)
+	assert.Greater(t, verification.Records, len(tests))
+
+	// Queries are audited as well
+	records, err := auditLog.Query(audit.Filter{Action: audit.ActionAuditQuery, Principal: "admin"})
+	assert.NoError(t, err)
+	assert.Len(t, records, 5)
+	assert.Equal(t, "2 records", records[0].Detail)
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/skantay/lru-api/internal/auth"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// IAuthenticator authenticates requests to /api
+type IAuthenticator interface {
+	// Authenticate проверка учетных данных запроса, возвращает аутентифицированного пользователя
+	Authenticate(r *http.Request) (auth.Principal, error)
+}
+
+// WithAuthenticator rejects requests to /api without valid credentials.
+// The principal is available to handlers through auth.FromContext.
+func WithAuthenticator(authenticator IAuthenticator) Option {
+	return func(a *api) {
+		a.authenticator = authenticator
+	}
+}
+
+type authErrorKey struct{}
+
+// identify authenticates the request and adds its principal to the context.
+// A request failing authentication is passed on with the error and rejected by authenticate,
+// so the rate limit mounted in between also applies to it, by client IP.
+func (a *api) identify(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, err := a.authenticator.Authenticate(r)
+		if err != nil {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), authErrorKey{}, err)))
+
+			return
+		}
+
+		if info := requestInfoFromContext(r.Context()); info != nil {
+			info.principal = principal.Subject
+		}
+
+		trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("enduser.id", principal.Subject))
+
+		next.ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), principal)))
+	})
+}
+
+// authenticate rejects requests identify failed to authenticate
+func (a *api) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err, ok := r.Context().Value(authErrorKey{}).(error); ok {
+			a.log.InfoContext(r.Context(), "authentication failed", "path", r.URL.Path, "remote", r.RemoteAddr, "error", err.Error())
+
+			a.unauthorized(w, r, err)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *api) unauthorized(w http.ResponseWriter, r *http.Request, err error) {
+	challenge := `Bearer realm="lru-api"`
+	if errors.Is(err, auth.ErrInvalidToken) {
+		challenge += `, error="invalid_token"`
+	}
+
+	w.Header().Set("WWW-Authenticate", challenge)
+	a.problem(w, r, errUnauthenticated, err.Error())
+}
+
+// principalOrIP identifies the caller for rate limits and quotas: the principal if authenticated, the client IP otherwise
+func principalOrIP(r *http.Request) string {
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		return principal.Subject
+	}
+
+	return clientIP(r)
+}
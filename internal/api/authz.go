@@ -0,0 +1,56 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/skantay/lru-api/internal/auth"
+	"github.com/skantay/lru-api/internal/authz"
+)
+
+// IAuthorizer decides which operations a principal may run on which keys
+type IAuthorizer interface {
+	// Allowed разрешена ли операция над ключом пользователю
+	Allowed(principal auth.Principal, op authz.Operation, key string) bool
+}
+
+// WithAuthorizer enforces the authorizer in handlers.
+// It requires WithAuthenticator, requests without a principal are denied.
+func WithAuthorizer(authorizer IAuthorizer) Option {
+	return func(a *api) {
+		a.authorizer = authorizer
+	}
+}
+
+// permitted reports whether the principal of ctx may run op on key, everything is permitted without an authorizer
+func (a *api) permitted(ctx context.Context, op authz.Operation, key string) bool {
+	if a.authorizer == nil {
+		return true
+	}
+
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return false
+	}
+
+	return a.authorizer.Allowed(principal, op, key)
+}
+
+// authorize replies 403 and logs the denial if the principal of r may not run op on key
+func (a *api) authorize(w http.ResponseWriter, r *http.Request, op authz.Operation, key string) bool {
+	if a.permitted(r.Context(), op, key) {
+		return true
+	}
+
+	a.logDenied(r.Context(), op, key)
+
+	a.problem(w, r, errForbidden, "operation "+string(op)+" is not permitted")
+
+	return false
+}
+
+func (a *api) logDenied(ctx context.Context, op authz.Operation, key string) {
+	principal, _ := auth.FromContext(ctx)
+
+	a.log.WarnContext(ctx, "authorization denied", "principal", principal.Subject, "operation", string(op), "key", key)
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skantay/lru-api/internal/auth"
+	"github.com/skantay/lru-api/internal/authz"
+	"github.com/skantay/lru-api/internal/cache"
+	"github.com/skantay/lru-api/internal/cache/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthorization(t *testing.T) {
+	c, err := cache.New(10, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	assert.NoError(t, c.Put(t.Context(), "team-a:1", "a", 0))
+	assert.NoError(t, c.Put(t.Context(), "team-b:1", "b", 0))
+
+	authenticator, err := auth.New(auth.Config{APIKeys: []string{
+		"deploy:" + auth.HashAPIKey("key-deploy"),
+		"alice:" + auth.HashAPIKey("key-alice"),
+	}})
+	assert.NoError(t, err)
+
+	policy, err := authz.Parse([]byte(`{
+		"roles": {
+			"team-a": [{"operations": ["get", "put", "evict"], "keys": ["team-a:*"]}],
+			"admin":  [{"operations": ["get", "put", "evict", "flush"], "keys": ["*"]}]
+		},
+		"principals": {"apikey:deploy": ["team-a"], "apikey:alice": ["admin"]}
+	}`))
+	assert.NoError(t, err)
+
+	handler := New(c, slog.New(slog.NewTextHandler(io.Discard, nil)),
+		WithAuthenticator(authenticator),
+		WithAuthorizer(policy),
+	)
+
+	tests := []struct {
+		name   string
+		apiKey string
+		method string
+		target string
+		body   string
+		code   int
+		resp   string
+	}{
+		{name: "get permitted", apiKey: "key-deploy", method: http.MethodGet, target: "/api/lru/team-a:1", code: http.StatusOK},
+		{name: "get forbidden", apiKey: "key-deploy", method: http.MethodGet, target: "/api/lru/team-b:1", code: http.StatusForbidden,
+			resp: `{"type":"urn:lru-api:problem:forbidden","title":"Operation not permitted","status":403,
+				"detail":"operation get is not permitted","instance":"/api/lru/team-b:1","code":"forbidden","request_id":"req-1"}`},
+		{name: "put forbidden", apiKey: "key-deploy", method: http.MethodPost, target: "/api/lru", body: `{"key":"team-b:2","value":1}`, code: http.StatusForbidden},
+		{name: "get all filtered", apiKey: "key-deploy", method: http.MethodGet, target: "/api/lru", code: http.StatusOK,
+			resp: `{"keys":["team-a:1"],"values":["a"]}`},
+		{name: "put permitted", apiKey: "key-deploy", method: http.MethodPost, target: "/api/lru", body: `{"key":"team-a:2","value":1}`, code: http.StatusCreated},
+		{name: "evict forbidden", apiKey: "key-deploy", method: http.MethodDelete, target: "/api/lru/team-b:1", code: http.StatusForbidden},
+		{name: "flush forbidden", apiKey: "key-deploy", method: http.MethodDelete, target: "/api/lru", code: http.StatusForbidden},
+		{name: "watch forbidden", apiKey: "key-deploy", method: http.MethodGet, target: "/api/watch?key=team-b:1", code: http.StatusForbidden},
+		{name: "flush permitted", apiKey: "key-alice", method: http.MethodDelete, target: "/api/lru", code: http.StatusNoContent},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(test.method, test.target, strings.NewReader(test.body))
+			r.Header.Set("X-API-Key", test.apiKey)
+			r.Header.Set("X-Request-Id", "req-1")
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			assert.Equal(t, test.code, w.Code)
+
+			if test.resp != "" {
+				assert.JSONEq(t, test.resp, w.Body.String())
+			}
+		})
+	}
+}
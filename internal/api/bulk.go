@@ -0,0 +1,288 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/skantay/lru-api/internal/authz"
+	"github.com/skantay/lru-api/internal/cache"
+)
+
+// IBulk is implemented by caches that scan and load nodes/items in batches.
+// If the cache passed to New implements it, /api/export and /api/import are enabled.
+type IBulk interface {
+	// Scan обход живых данных пачками, кэш блокируется только на время сбора пачки
+	Scan(ctx context.Context, batchSize int, fn func(entries []cache.Entry) error) error
+	// Load запись пачки данных с абсолютным временем истечения
+	Load(ctx context.Context, entries []cache.Entry) error
+}
+
+const ndjsonContentType = "application/x-ndjson"
+
+const (
+	// Entries exported per lock of the cache and imported per Load
+	bulkBatchSize = 500
+	// Maximum length of an imported line
+	bulkMaxLine = 16 << 20
+)
+
+var (
+	errInvalidExpiresAt = &apiError{http.StatusBadRequest, "invalid_expires_at", "expires_at is not an RFC 3339 time"}
+	errExpired          = &apiError{http.StatusBadRequest, "expired", "Entry already expired"}
+)
+
+// bulkEntry is a line of export and import.
+// It has no tags, as the cache does not tag nodes/items; tags of imported lines are ignored like other unknown fields.
+type bulkEntry struct {
+	Key       string      `json:"key"`
+	Value     interface{} `json:"value"`
+	ExpiresAt string      `json:"expires_at,omitempty"`
+	// ContentType is set for binary values, Value is then the base64 of their bytes
+	ContentType string `json:"content_type,omitempty"`
+	// TTLSeconds is only read by import, it applies if ExpiresAt is empty
+	TTLSeconds uint `json:"ttl_seconds,omitempty"`
+}
+
+type importError struct {
+	Line   int    `json:"line"`
+	Key    string `json:"key,omitempty"`
+	Code   string `json:"code"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type importResponse struct {
+	Imported int           `json:"imported"`
+	Errors   []importError `json:"errors"`
+}
+
+// export streams live nodes/items as NDJSON, from the least to the most recently used,
+// so importing them restores the LRU order. Nodes/items the principal may not get are left out.
+func (a *api) export(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+
+	// Server WriteTimeout is meant for regular requests, an export takes as long as the cache is large
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		a.log.DebugContext(r.Context(), "write deadline is not supported", "error", err.Error())
+	}
+
+	w.Header().Set("Content-Type", ndjsonContentType)
+	w.WriteHeader(http.StatusOK)
+
+	exported := 0
+
+	err := a.bulk.Scan(r.Context(), bulkBatchSize, func(entries []cache.Entry) error {
+		for _, entry := range entries {
+			if !a.permitted(r.Context(), authz.OpGet, entry.Key) {
+				continue
+			}
+
+			data, err := json.Marshal(newBulkEntry(entry))
+			if err != nil {
+				a.log.WarnContext(r.Context(), "entry not exported", "key", entry.Key, "error", err.Error())
+
+				continue
+			}
+
+			if _, err := w.Write(append(data, '\n')); err != nil {
+				return err
+			}
+
+			exported++
+		}
+
+		return rc.Flush()
+	})
+	if err != nil {
+		// The status is sent already, the client sees a truncated stream
+		a.log.WarnContext(r.Context(), "export interrupted", "exported", exported, "error", err.Error())
+	}
+}
+
+func newBulkEntry(entry cache.Entry) bulkEntry {
+	e := bulkEntry{
+		Key:       entry.Key,
+		Value:     entry.Value,
+		ExpiresAt: formatTimeV2(entry.ExpiresAt),
+	}
+
+	if blob, ok := entry.Value.(cache.Blob); ok {
+		e.Value = blob.Data
+		e.ContentType = blob.ContentType
+	}
+
+	return e
+}
+
+// importEntries loads NDJSON written by export in batches, keeping absolute expiration times.
+// Invalid lines are reported and skipped, the others are imported.
+// Lines without expires_at expire after ttl_seconds, the ttl query parameter or the default TTL.
+func (a *api) importEntries(w http.ResponseWriter, r *http.Request) {
+	ttl, err := parseTTL(r.URL.Query().Get("ttl"))
+	if err != nil {
+		a.problem(w, r, errInvalidTTL, err.Error())
+
+		return
+	}
+
+	if ttl == cache.KeepTTL {
+		a.problem(w, r, errInvalidTTL, "keep is not supported by import")
+
+		return
+	}
+
+	rc := http.NewResponseController(w)
+
+	// Server ReadTimeout and WriteTimeout are meant for regular requests,
+	// an import reads as long as the client sends and only answers after that
+	if err := rc.SetReadDeadline(time.Time{}); err != nil {
+		a.log.DebugContext(r.Context(), "read deadline is not supported", "error", err.Error())
+	}
+
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		a.log.DebugContext(r.Context(), "write deadline is not supported", "error", err.Error())
+	}
+
+	response := importResponse{Errors: []importError{}}
+	batch := make([]cache.Entry, 0, bulkBatchSize)
+	// rollbacks undo the quota reservations of the batch if it fails to be loaded
+	rollbacks := make([]func(), 0, bulkBatchSize)
+
+	load := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		if err := a.bulk.Load(r.Context(), batch); err != nil {
+			for _, rollback := range rollbacks {
+				rollback()
+			}
+
+			return err
+		}
+
+		response.Imported += len(batch)
+		batch = batch[:0]
+		rollbacks = rollbacks[:0]
+
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), bulkMaxLine)
+
+	line := 1
+
+	for ; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		entry, e, detail := a.importEntry(r, data, ttl)
+		if e != nil {
+			response.Errors = append(response.Errors, importError{Line: line, Key: entry.Key, Code: e.code, Detail: detail})
+
+			continue
+		}
+
+		if a.quota != nil {
+			rollback, err := a.quota.Reserve(principalOrIP(r), entry.Key, cache.SizeOf(entry.Key, entry.Value))
+			if err != nil {
+				response.Errors = append(response.Errors, importError{Line: line, Key: entry.Key, Code: errQuotaExceeded.code, Detail: err.Error()})
+
+				continue
+			}
+
+			rollbacks = append(rollbacks, rollback)
+		}
+
+		batch = append(batch, entry)
+
+		if len(batch) == bulkBatchSize {
+			if err := load(); err != nil {
+				a.cacheError(w, r, err)
+
+				return
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		response.Errors = append(response.Errors, importError{Line: line, Code: errUnreadableBody.code, Detail: err.Error()})
+	}
+
+	if err := load(); err != nil {
+		a.cacheError(w, r, err)
+
+		return
+	}
+
+	setAuditDetail(r.Context(), fmt.Sprintf("%d imported, %d rejected", response.Imported, len(response.Errors)))
+
+	a.respond(w, r, http.StatusOK, response)
+}
+
+// importEntry decodes a line and checks it may be stored.
+// If the line is rejected, the error and its detail are returned.
+func (a *api) importEntry(r *http.Request, data []byte, ttl time.Duration) (cache.Entry, *apiError, string) {
+	var line bulkEntry
+
+	if err := jsonCodec.unmarshal(data, &line); err != nil {
+		return cache.Entry{}, errMalformedJSON, err.Error()
+	}
+
+	entry := cache.Entry{Key: line.Key, Value: line.Value}
+
+	if line.Key == "" {
+		return entry, errEmptyKey, ""
+	}
+
+	if line.Value == nil {
+		return entry, errNullValue, ""
+	}
+
+	if line.ContentType != "" {
+		encoded, ok := line.Value.(string)
+
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if !ok || err != nil {
+			return entry, errMalformedJSON, "value of a binary entry is not base64"
+		}
+
+		entry.Value = cache.Blob{ContentType: line.ContentType, Data: decoded}
+	}
+
+	now := time.Now()
+
+	switch {
+	case line.ExpiresAt != "":
+		expiresAt, err := time.Parse(time.RFC3339Nano, line.ExpiresAt)
+		if err != nil {
+			return entry, errInvalidExpiresAt, err.Error()
+		}
+
+		if !expiresAt.After(now) {
+			return entry, errExpired, fmt.Sprintf("expired at %s", line.ExpiresAt)
+		}
+
+		entry.ExpiresAt = expiresAt
+	case line.TTLSeconds > 0:
+		entry.ExpiresAt = now.Add(time.Duration(line.TTLSeconds) * time.Second)
+	case ttl > 0:
+		entry.ExpiresAt = now.Add(ttl)
+	}
+
+	if !a.permitted(r.Context(), authz.OpPut, entry.Key) {
+		a.logDenied(r.Context(), authz.OpPut, entry.Key)
+
+		return entry, errForbidden, "operation " + string(authz.OpPut) + " is not permitted"
+	}
+
+	return entry, nil, ""
+}
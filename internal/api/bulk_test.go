@@ -0,0 +1,144 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skantay/lru-api/internal/cache"
+	"github.com/skantay/lru-api/internal/cache/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportImport(t *testing.T) {
+	source, err := cache.New(10, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+
+	assert.NoError(t, source.Put(ctx, "int", int64(1), time.Hour))
+	assert.NoError(t, source.Put(ctx, "map", map[string]interface{}{"f": 1.5}, 0))
+	assert.NoError(t, source.Put(ctx, "blob", cache.Blob{ContentType: "image/png", Data: []byte{0x89, 'P', 0x00}}, 0))
+
+	handler := New(source, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/export", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, ndjsonContentType, w.Header().Get("Content-Type"))
+
+	exported := w.Body.String()
+
+	var keys []string
+
+	scanner := bufio.NewScanner(strings.NewReader(exported))
+	for scanner.Scan() {
+		var entry bulkEntry
+
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		assert.NotEmpty(t, entry.ExpiresAt)
+
+		keys = append(keys, entry.Key)
+	}
+
+	// From the least to the most recently used
+	assert.Equal(t, []string{"int", "map", "blob"}, keys)
+
+	target, err := cache.New(10, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	handler = New(target, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	body := exported + strings.Join([]string{
+		``,
+		`not json`,
+		`{"key":"","value":1}`,
+		`{"key":"null","value":null}`,
+		`{"key":"old","value":1,"expires_at":"2000-01-01T00:00:00Z"}`,
+		`{"key":"bad time","value":1,"expires_at":"tomorrow"}`,
+		`{"key":"bad blob","value":1,"content_type":"text/plain"}`,
+		`{"key":"ttl","value":"v","ttl_seconds":90}`,
+		`{"key":"default","value":"v"}`,
+	}, "\n")
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/import?ttl=2h", strings.NewReader(body)))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"imported":5,"errors":[
+		{"line":5,"code":"malformed_json","detail":"invalid character 'o' in literal null (expecting 'u')"},
+		{"line":6,"code":"empty_key"},
+		{"line":7,"key":"null","code":"null_value"},
+		{"line":8,"key":"old","code":"expired","detail":"expired at 2000-01-01T00:00:00Z"},
+		{"line":9,"key":"bad time","code":"invalid_expires_at","detail":"parsing time \"tomorrow\" as \"2006-01-02T15:04:05.999999999Z07:00\": cannot parse \"tomorrow\" as \"2006\""},
+		{"line":10,"key":"bad blob","code":"malformed_json","detail":"value of a binary entry is not base64"}
+	]}`, w.Body.String())
+
+	for _, key := range []string{"int", "map", "blob"} {
+		sourceValue, sourceExpiresAt, err := source.Get(ctx, key)
+		assert.NoError(t, err)
+
+		value, expiresAt, err := target.Get(ctx, key)
+		assert.NoError(t, err)
+
+		assert.Equal(t, sourceValue, value, key)
+		assert.WithinDuration(t, sourceExpiresAt, expiresAt, time.Millisecond, key)
+	}
+
+	_, expiresAt, err := target.Get(ctx, "ttl")
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(90*time.Second), expiresAt, time.Second)
+
+	_, expiresAt, err = target.Get(ctx, "default")
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(2*time.Hour), expiresAt, time.Second)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/import?ttl=keep", strings.NewReader("")))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestImportOutlastsServerTimeouts(t *testing.T) {
+	c, err := cache.New(10, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	server := httptest.NewUnstartedServer(New(c, slog.New(slog.NewTextHandler(io.Discard, nil))))
+	server.Config.ReadTimeout = 100 * time.Millisecond
+	server.Config.WriteTimeout = 100 * time.Millisecond
+	server.Start()
+
+	defer server.Close()
+
+	// The client sends for longer than both timeouts
+	body, writer := io.Pipe()
+
+	go func() {
+		for _, key := range []string{"a", "b", "c"} {
+			time.Sleep(100 * time.Millisecond)
+			io.WriteString(writer, `{"key":"`+key+`","value":1}`+"\n")
+		}
+
+		writer.Close()
+	}()
+
+	response, err := http.Post(server.URL+"/api/import", ndjsonContentType, body)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer response.Body.Close()
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	var result importResponse
+	assert.NoError(t, json.NewDecoder(response.Body).Decode(&result))
+	assert.Equal(t, 3, result.Imported)
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"context"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skantay/lru-api/internal/cache"
+	"github.com/skantay/lru-api/internal/httpcache"
+)
+
+// IEntryGetter is implemented by caches that track when nodes/items were written.
+// If the cache passed to New implements it, responses carry Last-Modified and If-Modified-Since is honored.
+type IEntryGetter interface {
+	// GetEntry получение данных по ключу вместе со временем их последней записи
+	GetEntry(ctx context.Context, key string) (cache.Entry, error)
+}
+
+// WithCachePolicy decides Cache-Control of nodes/items by their namespace.
+// Without it responses are private and cached for the remaining TTL.
+func WithCachePolicy(policy *httpcache.Policy) Option {
+	return func(a *api) {
+		a.cachePolicy = policy
+	}
+}
+
+// getEntry gets a node/item, ModifiedAt is zero if the cache does not track it
+func (a *api) getEntry(ctx context.Context, key string) (cache.Entry, error) {
+	if a.entries != nil {
+		return a.entries.GetEntry(ctx, key)
+	}
+
+	value, expiresAt, err := a.cache.Get(ctx, key)
+
+	return cache.Entry{Key: key, Value: value, ExpiresAt: expiresAt}, err
+}
+
+// setCacheHeaders sets ETag, Cache-Control, Expires and Last-Modified of a node/item
+func (a *api) setCacheHeaders(header http.Header, entry cache.Entry, etag string) {
+	header.Set("ETag", etag)
+
+	a.cachePolicy.Rule(entry.Key).SetHeaders(header, time.Now(), entry.ExpiresAt)
+
+	if !entry.ModifiedAt.IsZero() {
+		header.Set("Last-Modified", entry.ModifiedAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+// respondCacheable is respond for a node/item, it answers 304 if the client has the response already
+func (a *api) respondCacheable(w http.ResponseWriter, r *http.Request, entry cache.Entry, response interface{}) {
+	c := responseCodec(r)
+
+	data, err := a.marshal(r.Context(), c, response)
+	if err != nil {
+		a.log.ErrorContext(r.Context(), err.Error())
+
+		a.problem(w, r, errInternal, "")
+
+		return
+	}
+
+	// The tag is of the encoded body, so responses negotiated into other codecs get other tags
+	etag := strongETag(data)
+
+	w.Header().Add("Vary", "Accept")
+	a.setCacheHeaders(w.Header(), entry, etag)
+
+	if notModified(r, etag, entry.ModifiedAt) {
+		w.WriteHeader(http.StatusNotModified)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", c.contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+func strongETag(parts ...[]byte) string {
+	h := fnv.New64a()
+
+	for _, part := range parts {
+		h.Write(part)
+	}
+
+	return `"` + strconv.FormatUint(h.Sum64(), 16) + `"`
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since if there is none, as RFC 9110 orders them
+func notModified(r *http.Request, etag string, modifiedAt time.Time) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		for _, tag := range strings.Split(ifNoneMatch, ",") {
+			tag = strings.TrimSpace(tag)
+
+			// Weak comparison, the tags of GET are compared regardless of W/
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	ifModifiedSince := r.Header.Get("If-Modified-Since")
+	if ifModifiedSince == "" || modifiedAt.IsZero() {
+		return false
+	}
+
+	since, err := http.ParseTime(ifModifiedSince)
+	if err != nil {
+		return false
+	}
+
+	// HTTP dates have a precision of seconds
+	return !modifiedAt.Truncate(time.Second).After(since)
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skantay/lru-api/internal/cache"
+	"github.com/skantay/lru-api/internal/cache/mocks"
+	"github.com/skantay/lru-api/internal/httpcache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCaching(t *testing.T) {
+	c, err := cache.New(10, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	policy := httpcache.New(httpcache.Rule{Mode: httpcache.ModePrivate}, []httpcache.Rule{
+		{Prefix: "user:", Mode: httpcache.ModePublic, MaxAge: 30 * time.Second},
+		{Prefix: "session:", Mode: httpcache.ModeNoStore},
+	})
+
+	handler := New(c, slog.New(slog.NewTextHandler(io.Discard, nil)), WithCachePolicy(policy))
+
+	ctx := t.Context()
+
+	assert.NoError(t, c.Put(ctx, "a", "value", time.Hour))
+	assert.NoError(t, c.Put(ctx, "user:1", "value", time.Hour))
+	assert.NoError(t, c.Put(ctx, "session:1", "value", time.Hour))
+
+	get := func(target string, header map[string]string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		for name, value := range header {
+			r.Header.Set(name, value)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		return w
+	}
+
+	for _, target := range []string{"/api/lru/a", "/api/v2/keys/a"} {
+		w := get(target, nil)
+		assert.Equal(t, http.StatusOK, w.Code, target)
+
+		etag := w.Header().Get("ETag")
+		lastModified := w.Header().Get("Last-Modified")
+
+		assert.NotEmpty(t, etag, target)
+		assert.NotEmpty(t, lastModified, target)
+		assert.Regexp(t, `^private, max-age=(3599|3600)$`, w.Header().Get("Cache-Control"), target)
+
+		expires, err := http.ParseTime(w.Header().Get("Expires"))
+		assert.NoError(t, err, target)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), expires, 2*time.Second, target)
+
+		tests := []struct {
+			name   string
+			header map[string]string
+			status int
+		}{
+			{name: "etag", header: map[string]string{"If-None-Match": etag}, status: http.StatusNotModified},
+			{name: "weak etag in a list", header: map[string]string{"If-None-Match": `"other", W/` + etag}, status: http.StatusNotModified},
+			{name: "any", header: map[string]string{"If-None-Match": "*"}, status: http.StatusNotModified},
+			{name: "other etag", header: map[string]string{"If-None-Match": `"other"`}, status: http.StatusOK},
+			{name: "not modified since", header: map[string]string{"If-Modified-Since": lastModified}, status: http.StatusNotModified},
+			{name: "modified since", header: map[string]string{"If-Modified-Since": time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)}, status: http.StatusOK},
+			// If-None-Match takes precedence over If-Modified-Since
+			{name: "other etag not modified since", header: map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": lastModified}, status: http.StatusOK},
+			// Responses in other media types are other representations
+			{name: "other codec", header: map[string]string{"If-None-Match": etag, "Accept": "application/cbor"}, status: http.StatusOK},
+		}
+
+		for _, test := range tests {
+			w := get(target, test.header)
+
+			assert.Equal(t, test.status, w.Code, target+" "+test.name)
+			assert.Equal(t, etag != w.Header().Get("ETag"), test.name == "other codec", target+" "+test.name)
+
+			if test.status == http.StatusNotModified {
+				assert.Empty(t, w.Body.String(), target+" "+test.name)
+			}
+		}
+	}
+
+	// A write changes the tag
+	w := get("/api/lru/a", nil)
+	etag := w.Header().Get("ETag")
+
+	assert.NoError(t, c.Put(ctx, "a", "other value", time.Hour))
+
+	w = get("/api/lru/a", map[string]string{"If-None-Match": etag})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+
+	w = get("/api/lru/user:1", nil)
+	assert.Equal(t, "public, max-age=30", w.Header().Get("Cache-Control"))
+
+	w = get("/api/lru/session:1", nil)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	assert.Empty(t, w.Header().Get("Expires"))
+
+	// Conditional requests still work without storing responses
+	w = get("/api/lru/session:1", map[string]string{"If-None-Match": w.Header().Get("ETag")})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+}
+
+func TestCachingBlob(t *testing.T) {
+	c, err := cache.New(10, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	server := httptest.NewServer(New(c, slog.New(slog.NewTextHandler(io.Discard, nil))))
+	defer server.Close()
+
+	r, err := http.NewRequest(http.MethodPut, server.URL+"/api/v2/keys/blob", strings.NewReader("0123456789"))
+	assert.NoError(t, err)
+	r.Header.Set("Content-Type", "text/plain")
+
+	resp, err := http.DefaultClient.Do(r)
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	resp, err = http.Get(server.URL + "/api/v2/keys/blob")
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	etag := resp.Header.Get("ETag")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NotEmpty(t, etag)
+	assert.NotEmpty(t, resp.Header.Get("Last-Modified"))
+	assert.Regexp(t, `^private, max-age=(59|60)$`, resp.Header.Get("Cache-Control"))
+
+	for name, value := range map[string]string{
+		"If-None-Match":     etag,
+		"If-Modified-Since": resp.Header.Get("Last-Modified"),
+	} {
+		r, err := http.NewRequest(http.MethodGet, server.URL+"/api/v2/keys/blob", nil)
+		assert.NoError(t, err)
+		r.Header.Set(name, value)
+
+		resp, err := http.DefaultClient.Do(r)
+		assert.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusNotModified, resp.StatusCode, name)
+	}
+
+	// Ranges are served while the tag is current
+	r, err = http.NewRequest(http.MethodGet, server.URL+"/api/v2/keys/blob", nil)
+	assert.NoError(t, err)
+	r.Header.Set("Range", "bytes=0-3")
+	r.Header.Set("If-Range", etag)
+
+	resp, err = http.DefaultClient.Do(r)
+	assert.NoError(t, err)
+
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, "0123", string(body))
+	assert.Equal(t, strconv.Itoa(4), resp.Header.Get("Content-Length"))
+}
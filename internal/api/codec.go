@@ -0,0 +1,206 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// codec encodes and decodes bodies of one media type.
+// Values keep their types between codecs: integers stay integers, floats stay floats and bytes stay bytes.
+type codec struct {
+	name        string
+	contentType string
+	marshal     func(v interface{}) ([]byte, error)
+	unmarshal   func(data []byte, v interface{}) error
+	// malformed is answered when a request body can not be decoded
+	malformed *apiError
+}
+
+var (
+	jsonCodec    = &codec{"json", "application/json", json.Marshal, unmarshalJSON, errMalformedJSON}
+	msgpackCodec = &codec{"msgpack", "application/msgpack", marshalMsgpack, unmarshalMsgpack, errMalformedBody}
+	cborCodec    = &codec{"cbor", "application/cbor", cborEncMode.Marshal, cborDecMode.Unmarshal, errMalformedBody}
+)
+
+// codecs by media type, including the unregistered names of MessagePack clients still send
+var codecs = map[string]*codec{
+	"application/json":        jsonCodec,
+	"application/msgpack":     msgpackCodec,
+	"application/x-msgpack":   msgpackCodec,
+	"application/vnd.msgpack": msgpackCodec,
+	"application/cbor":        cborCodec,
+}
+
+var (
+	cborEncMode = must(cbor.EncOptions{}.EncMode())
+	// Maps are decoded with string keys as JSON and MessagePack do, so values can be encoded by every codec
+	cborDecMode = must(cbor.DecOptions{DefaultMapType: reflect.TypeOf(map[string]interface{}(nil))}.DecMode())
+)
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+
+	return v
+}
+
+// requestCodec returns the codec of a request body, JSON if there is no content type and nil for other media types
+func requestCodec(contentType string) *codec {
+	if contentType == "" {
+		return jsonCodec
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil
+	}
+
+	if strings.HasSuffix(mediaType, "+json") {
+		return jsonCodec
+	}
+
+	return codecs[mediaType]
+}
+
+// responseCodec returns the codec of the media type preferred by Accept, JSON if none is supported
+func responseCodec(r *http.Request) *codec {
+	best, bestQ := jsonCodec, 0.0
+
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil {
+				continue
+			}
+
+			q := 1.0
+			if s, ok := params["q"]; ok {
+				if q, err = strconv.ParseFloat(s, 64); err != nil {
+					continue
+				}
+			}
+
+			c, ok := codecs[mediaType]
+
+			switch mediaType {
+			case "*/*", "application/*":
+				c, ok = jsonCodec, true
+			}
+
+			if ok && q > bestQ {
+				best, bestQ = c, q
+			}
+		}
+	}
+
+	return best
+}
+
+// respond encodes the response in the media type negotiated by Accept
+func (a *api) respond(w http.ResponseWriter, r *http.Request, status int, response interface{}) {
+	c := responseCodec(r)
+
+	data, err := a.marshal(r.Context(), c, response)
+	if err != nil {
+		a.log.ErrorContext(r.Context(), err.Error())
+
+		a.problem(w, r, errInternal, "")
+
+		return
+	}
+
+	w.Header().Set("Content-Type", c.contentType)
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// unmarshalJSON decodes numbers of values into int64 or uint64 if they are integers and float64 otherwise,
+// so integers are not encoded as floats by the other codecs
+func unmarshalJSON(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return errors.New("invalid data after top-level value")
+	}
+
+	switch v := v.(type) {
+	case *interface{}:
+		*v = fromJSONNumbers(*v)
+	case *createRequest:
+		v.Value = fromJSONNumbers(v.Value)
+	case *bulkEntry:
+		v.Value = fromJSONNumbers(v.Value)
+	}
+
+	return nil
+}
+
+func fromJSONNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+
+		if n, err := strconv.ParseUint(v.String(), 10, 64); err == nil {
+			return n
+		}
+
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+
+		// Out of the range of float64, JSON encodes it verbatim
+		return v
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = fromJSONNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = fromJSONNumbers(item)
+		}
+	}
+
+	return value
+}
+
+// Structs are encoded with their JSON field names
+func marshalMsgpack(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+
+	encoder := msgpack.NewEncoder(&buf)
+	encoder.SetCustomStructTag("json")
+
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Integers are decoded into int64 or uint64 rather than the smallest type holding them
+func unmarshalMsgpack(data []byte, v interface{}) error {
+	decoder := msgpack.NewDecoder(bytes.NewReader(data))
+	decoder.SetCustomStructTag("json")
+	decoder.UseLooseInterfaceDecoding(true)
+
+	return decoder.Decode(v)
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/skantay/lru-api/internal/cache"
+	"github.com/skantay/lru-api/internal/cache/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseCodec(t *testing.T) {
+	tests := []struct {
+		accept string
+		codec  *codec
+	}{
+		{accept: "", codec: jsonCodec},
+		{accept: "application/msgpack", codec: msgpackCodec},
+		{accept: "application/x-msgpack", codec: msgpackCodec},
+		{accept: "application/cbor", codec: cborCodec},
+		{accept: "text/html, application/cbor;q=0.9", codec: cborCodec},
+		{accept: "application/cbor;q=0.5, application/msgpack", codec: msgpackCodec},
+		{accept: "application/cbor;q=0.5, */*", codec: jsonCodec},
+		{accept: "application/cbor;q=0", codec: jsonCodec},
+		{accept: "text/html", codec: jsonCodec},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if test.accept != "" {
+			r.Header.Set("Accept", test.accept)
+		}
+
+		assert.Equal(t, test.codec.name, responseCodec(r).name, test.accept)
+	}
+}
+
+func TestRequestCodec(t *testing.T) {
+	assert.Equal(t, jsonCodec, requestCodec(""))
+	assert.Equal(t, jsonCodec, requestCodec("application/json; charset=utf-8"))
+	assert.Equal(t, jsonCodec, requestCodec("application/merge-patch+json"))
+	assert.Equal(t, msgpackCodec, requestCodec("application/vnd.msgpack"))
+	assert.Equal(t, cborCodec, requestCodec("application/cbor"))
+	assert.Nil(t, requestCodec("application/octet-stream"))
+	assert.Nil(t, requestCodec("not a media type"))
+}
+
+func TestCodecsKeepTypes(t *testing.T) {
+	value := map[string]interface{}{
+		"int":    int64(-3),
+		"uint":   uint64(18446744073709551615),
+		"float":  1.5,
+		"whole":  2.0,
+		"string": "s",
+		"bool":   true,
+		"list":   []interface{}{int64(-1), "a"},
+	}
+
+	for _, c := range []*codec{jsonCodec, msgpackCodec, cborCodec} {
+		data, err := c.marshal(value)
+		assert.NoError(t, err, c.name)
+
+		var decoded interface{}
+
+		assert.NoError(t, c.unmarshal(data, &decoded), c.name)
+
+		m, ok := decoded.(map[string]interface{})
+		if !assert.True(t, ok, c.name) {
+			continue
+		}
+
+		assert.Equal(t, int64(-3), m["int"], c.name)
+		assert.Equal(t, uint64(18446744073709551615), m["uint"], c.name)
+		assert.Equal(t, 1.5, m["float"], c.name)
+		assert.Equal(t, "s", m["string"], c.name)
+		assert.Equal(t, true, m["bool"], c.name)
+		assert.Equal(t, []interface{}{int64(-1), "a"}, m["list"], c.name)
+
+		// JSON does not tell 2.0 from 2
+		if c != jsonCodec {
+			assert.Equal(t, 2.0, m["whole"], c.name)
+		}
+	}
+
+	var decoded interface{}
+
+	assert.Error(t, jsonCodec.unmarshal([]byte(`1 2`), &decoded))
+}
+
+func TestNegotiation(t *testing.T) {
+	c, err := cache.New(10, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	handler := New(c, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	body, err := msgpackCodec.marshal(createRequest{Key: "a", Value: map[string]interface{}{"n": 1, "f": 1.0}})
+	assert.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodPost, "/api/lru", bytes.NewReader(body))
+	r.Header.Set("Content-Type", "application/msgpack")
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	r = httptest.NewRequest(http.MethodGet, "/api/lru/a", nil)
+	r.Header.Set("Accept", "application/cbor")
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/cbor", w.Header().Get("Content-Type"))
+	assert.Equal(t, "Accept", w.Header().Get("Vary"))
+
+	var response getResponse
+
+	assert.NoError(t, cborCodec.unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "a", response.Key)
+	assert.Equal(t, map[string]interface{}{"n": uint64(1), "f": 1.0}, response.Value)
+
+	r = httptest.NewRequest(http.MethodPost, "/api/lru", bytes.NewReader([]byte{0xc1}))
+	r.Header.Set("Content-Type", "application/msgpack")
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"malformed_body"`)
+}
@@ -0,0 +1,353 @@
+package api
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var errUnsupportedEncoding = &apiError{http.StatusUnsupportedMediaType, "unsupported_encoding", "Content-Encoding is not supported"}
+
+const (
+	// defaultMaxBodySize bounds created values unless WithMaxBodySize is given
+	defaultMaxBodySize = 1 << 20
+
+	// zstd frames may ask for windows up to 3.75 TB, 8 MB is what the RFC recommends decoders to support
+	zstdMaxWindow = 8 << 20
+	zstdMaxMemory = 64 << 20
+)
+
+// WithCompression compresses responses of at least minSize bytes with gzip or zstd, as negotiated by Accept-Encoding
+func WithCompression(minSize int) Option {
+	return func(a *api) {
+		a.compressMinSize = minSize
+	}
+}
+
+// WithMaxBodySize rejects bodies of POST /api/lru larger than size bytes once decompressed, 0 is unlimited.
+// Imports are read line by line and are not bounded.
+func WithMaxBodySize(size int64) Option {
+	return func(a *api) {
+		a.maxBodySize = size
+	}
+}
+
+// encoder is a compressing writer that can be reused for another response
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+var encoderPools = map[string]*sync.Pool{
+	"gzip": {New: func() interface{} {
+		return gzip.NewWriter(nil)
+	}},
+	"zstd": {New: func() interface{} {
+		// One goroutine per response, responses are compressed concurrently already
+		return must(zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1)))
+	}},
+}
+
+// acceptedEncoding returns the encoding preferred by Accept-Encoding, zstd on ties, empty if neither is accepted
+func acceptedEncoding(r *http.Request) string {
+	q := map[string]float64{}
+
+	for _, accept := range r.Header.Values("Accept-Encoding") {
+		for _, part := range strings.Split(accept, ",") {
+			coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+
+			weight := 1.0
+
+			if name, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(name) == "q" {
+				parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+				if err != nil {
+					continue
+				}
+
+				weight = parsed
+			}
+
+			q[strings.ToLower(strings.TrimSpace(coding))] = weight
+		}
+	}
+
+	// * stands for the codings not listed
+	if weight, ok := q["*"]; ok {
+		for _, coding := range []string{"zstd", "gzip"} {
+			if _, listed := q[coding]; !listed {
+				q[coding] = weight
+			}
+		}
+	}
+
+	best := ""
+
+	for _, coding := range []string{"zstd", "gzip"} {
+		if q[coding] > 0 && (best == "" || q[coding] > q[best]) {
+			best = coding
+		}
+	}
+
+	return best
+}
+
+// compressible tells whether a response is worth compressing: text and structured data that is not encoded already
+func compressible(header http.Header, status int) bool {
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified {
+		return false
+	}
+
+	// Ranges are of the identity encoding
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" {
+		return false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "json"),
+		strings.HasSuffix(mediaType, "xml"),
+		mediaType == ndjsonContentType,
+		mediaType == "application/javascript",
+		mediaType == msgpackCodec.contentType,
+		mediaType == cborCodec.contentType:
+		return true
+	}
+
+	return false
+}
+
+// compress encodes responses with the encoding accepted by the client.
+// Bodies are buffered until they reach the minimum size, smaller ones are sent as they are.
+// Streams are compressed from their first flush on.
+func (a *api) compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := acceptedEncoding(r)
+
+		// Hijacked connections write no response
+		if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding, minSize: a.compressMinSize}
+		defer cw.close()
+
+		next.ServeHTTP(cw, r)
+	})
+}
+
+type compressWriter struct {
+	http.ResponseWriter
+
+	encoding string
+	minSize  int
+
+	status int
+	// committed is set once the status is sent, enc is nil if the body is not compressed
+	committed bool
+	buf       []byte
+	enc       encoder
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.committed || cw.status != 0 {
+		return
+	}
+
+	// Informational responses precede the final one
+	if status < http.StatusOK {
+		cw.ResponseWriter.WriteHeader(status)
+
+		return
+	}
+
+	cw.status = status
+
+	if !compressible(cw.Header(), status) {
+		cw.commit(false)
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+
+	if cw.committed {
+		if cw.enc != nil {
+			return cw.enc.Write(p)
+		}
+
+		return cw.ResponseWriter.Write(p)
+	}
+
+	cw.buf = append(cw.buf, p...)
+
+	if len(cw.buf) >= cw.minSize {
+		if err := cw.commit(true); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+func (cw *compressWriter) Flush() {
+	cw.FlushError()
+}
+
+// FlushError is called by http.ResponseController, a flushed response is a stream and compressed
+func (cw *compressWriter) FlushError() error {
+	if cw.status != 0 && !cw.committed {
+		if err := cw.commit(true); err != nil {
+			return err
+		}
+	}
+
+	if cw.enc != nil {
+		if err := cw.enc.Flush(); err != nil {
+			return err
+		}
+	}
+
+	return http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the deadlines of the connection
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// commit sends the status and the buffered body, compressed if compress is set
+func (cw *compressWriter) commit(compress bool) error {
+	cw.committed = true
+
+	header := cw.Header()
+
+	if compressible(header, cw.status) {
+		header.Add("Vary", "Accept-Encoding")
+
+		if compress {
+			header.Set("Content-Encoding", cw.encoding)
+			header.Del("Content-Length")
+
+			// The compressed body is another representation, a strong tag would claim identical bytes
+			if etag := header.Get("ETag"); strings.HasPrefix(etag, `"`) {
+				header.Set("ETag", "W/"+etag)
+			}
+
+			cw.enc = encoderPools[cw.encoding].Get().(encoder)
+			cw.enc.Reset(cw.ResponseWriter)
+		}
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	buf := cw.buf
+	cw.buf = nil
+
+	if len(buf) == 0 {
+		return nil
+	}
+
+	var err error
+
+	if cw.enc != nil {
+		_, err = cw.enc.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+
+	return err
+}
+
+// close sends what is buffered and finishes the compressed stream
+func (cw *compressWriter) close() {
+	if cw.status != 0 && !cw.committed {
+		cw.commit(false)
+	}
+
+	if cw.enc != nil {
+		cw.enc.Close()
+		encoderPools[cw.encoding].Put(cw.enc)
+		cw.enc = nil
+	}
+}
+
+// decompress decodes request bodies with Content-Encoding gzip or zstd.
+// Bodies larger than maxSize bytes once decoded fail to be read with *http.MaxBytesError, 0 is unlimited,
+// so a small compressed body cannot expand without bound.
+func (a *api) decompress(maxSize int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			a.serveDecompressed(w, r, maxSize, next)
+		})
+	}
+}
+
+func (a *api) serveDecompressed(w http.ResponseWriter, r *http.Request, maxSize int64, next http.Handler) {
+	var body io.ReadCloser
+
+	switch encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))); encoding {
+	case "", "identity":
+		if maxSize > 0 {
+			r.Body = http.MaxBytesReader(w, r.Body, maxSize)
+		}
+
+		next.ServeHTTP(w, r)
+
+		return
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			a.problem(w, r, errUnreadableBody, err.Error())
+
+			return
+		}
+
+		body = gz
+	case "zstd":
+		zr, err := zstd.NewReader(r.Body,
+			zstd.WithDecoderConcurrency(1),
+			zstd.WithDecoderMaxWindow(zstdMaxWindow),
+			zstd.WithDecoderMaxMemory(zstdMaxMemory),
+		)
+		if err != nil {
+			a.problem(w, r, errUnreadableBody, err.Error())
+
+			return
+		}
+
+		body = zr.IOReadCloser()
+	default:
+		a.problem(w, r, errUnsupportedEncoding, "encoding "+encoding+" is not gzip or zstd")
+
+		return
+	}
+
+	defer body.Close()
+
+	if maxSize > 0 {
+		body = http.MaxBytesReader(w, body, maxSize)
+	}
+
+	r.Body = body
+	r.ContentLength = -1
+	r.Header.Del("Content-Encoding")
+	r.Header.Del("Content-Length")
+
+	next.ServeHTTP(w, r)
+}
@@ -0,0 +1,240 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/skantay/lru-api/internal/cache"
+	"github.com/skantay/lru-api/internal/cache/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcceptedEncoding(t *testing.T) {
+	tests := []struct {
+		accept   string
+		encoding string
+	}{
+		{accept: "", encoding: ""},
+		{accept: "identity", encoding: ""},
+		{accept: "gzip", encoding: "gzip"},
+		{accept: "gzip, deflate, br, zstd", encoding: "zstd"},
+		{accept: "zstd;q=0.5, gzip", encoding: "gzip"},
+		{accept: "GZIP;q=0.8", encoding: "gzip"},
+		{accept: "gzip;q=0", encoding: ""},
+		{accept: "*", encoding: "zstd"},
+		{accept: "*;q=0.5, gzip", encoding: "gzip"},
+		{accept: "*, zstd;q=0", encoding: "gzip"},
+		{accept: "gzip;q=x", encoding: ""},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if test.accept != "" {
+			r.Header.Set("Accept-Encoding", test.accept)
+		}
+
+		assert.Equal(t, test.encoding, acceptedEncoding(r), test.accept)
+	}
+}
+
+func decode(t *testing.T, encoding string, body []byte) string {
+	var reader io.Reader
+
+	switch encoding {
+	case "gzip":
+		gz, err := gzip.NewReader(bytes.NewReader(body))
+		assert.NoError(t, err)
+
+		reader = gz
+	case "zstd":
+		zr, err := zstd.NewReader(bytes.NewReader(body))
+		assert.NoError(t, err)
+		defer zr.Close()
+
+		reader = zr
+	default:
+		reader = bytes.NewReader(body)
+	}
+
+	data, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+
+	return string(data)
+}
+
+func encode(t *testing.T, encoding, body string) []byte {
+	var buf bytes.Buffer
+
+	switch encoding {
+	case "gzip":
+		gz := gzip.NewWriter(&buf)
+		_, err := gz.Write([]byte(body))
+		assert.NoError(t, err)
+		assert.NoError(t, gz.Close())
+	case "zstd":
+		zw, err := zstd.NewWriter(&buf)
+		assert.NoError(t, err)
+		_, err = zw.Write([]byte(body))
+		assert.NoError(t, err)
+		assert.NoError(t, zw.Close())
+	}
+
+	return buf.Bytes()
+}
+
+func TestCompression(t *testing.T) {
+	c, err := cache.New(100, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+
+	assert.NoError(t, c.Put(ctx, "small", "value", 0))
+
+	for i := range 20 {
+		assert.NoError(t, c.Put(ctx, fmt.Sprintf("key %d", i), strings.Repeat("value ", 20), 0))
+	}
+
+	assert.NoError(t, c.Put(ctx, "blob", cache.Blob{ContentType: "image/png", Data: bytes.Repeat([]byte{1}, 2048)}, 0))
+
+	handler := New(c, slog.New(slog.NewTextHandler(io.Discard, nil)), WithCompression(1024))
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		accept     string
+		compressed bool
+	}{
+		{name: "gzip", method: http.MethodGet, target: "/api/lru", accept: "gzip", compressed: true},
+		{name: "zstd", method: http.MethodGet, target: "/api/lru", accept: "gzip, zstd", compressed: true},
+		{name: "not accepted", method: http.MethodGet, target: "/api/lru"},
+		{name: "below the minimum size", method: http.MethodGet, target: "/api/lru/small", accept: "gzip"},
+		{name: "binary", method: http.MethodGet, target: "/api/v2/keys/blob", accept: "gzip"},
+		{name: "stream", method: http.MethodGet, target: "/api/export", accept: "zstd", compressed: true},
+		{name: "head", method: http.MethodHead, target: "/api/v2/keys/small", accept: "gzip"},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, test.target, nil)
+		if test.accept != "" {
+			r.Header.Set("Accept-Encoding", test.accept)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code, test.name)
+
+		encoding := w.Header().Get("Content-Encoding")
+
+		if !test.compressed {
+			assert.Empty(t, encoding, test.name)
+
+			continue
+		}
+
+		assert.Equal(t, acceptedEncoding(r), encoding, test.name)
+		assert.Contains(t, w.Header().Values("Vary"), "Accept-Encoding", test.name)
+		assert.Empty(t, w.Header().Get("Content-Length"), test.name)
+
+		// The body decodes to the one sent without compression
+		plain := httptest.NewRecorder()
+		handler.ServeHTTP(plain, httptest.NewRequest(test.method, test.target, nil))
+
+		decoded := decode(t, encoding, w.Body.Bytes())
+
+		assert.Less(t, w.Body.Len(), plain.Body.Len(), test.name)
+		assert.Len(t, decoded, plain.Body.Len(), test.name)
+
+		// GetAll is not ordered
+		if test.target == "/api/lru" {
+			var compressed, uncompressed getAllResponse
+
+			assert.NoError(t, json.Unmarshal([]byte(decoded), &compressed), test.name)
+			assert.NoError(t, json.Unmarshal(plain.Body.Bytes(), &uncompressed), test.name)
+			assert.ElementsMatch(t, uncompressed.Keys, compressed.Keys, test.name)
+		} else {
+			assert.Equal(t, plain.Body.String(), decoded, test.name)
+		}
+	}
+
+	// Compressed responses have weak tags, which still match conditional requests
+	r := httptest.NewRequest(http.MethodGet, "/api/lru/key%201", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	r.Header.Set("Accept", "application/json")
+
+	w := httptest.NewRecorder()
+	New(c, slog.New(slog.NewTextHandler(io.Discard, nil)), WithCompression(16)).ServeHTTP(w, r)
+
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.True(t, strings.HasPrefix(w.Header().Get("ETag"), `W/"`))
+
+	r.Header.Set("If-None-Match", w.Header().Get("ETag"))
+
+	w = httptest.NewRecorder()
+	New(c, slog.New(slog.NewTextHandler(io.Discard, nil)), WithCompression(16)).ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+}
+
+func TestDecompression(t *testing.T) {
+	c, err := cache.New(10, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	handler := New(c, slog.New(slog.NewTextHandler(io.Discard, nil)), WithMaxBodySize(1<<20))
+
+	// Compresses to a few kilobytes
+	bomb := `{"key":"bomb","value":"` + strings.Repeat("a", 10<<20) + `"}`
+
+	tests := []struct {
+		name     string
+		target   string
+		encoding string
+		body     []byte
+		status   int
+		code     string
+	}{
+		{name: "gzip", target: "/api/lru", encoding: "gzip", body: encode(t, "gzip", `{"key":"gzip","value":1}`), status: http.StatusCreated},
+		{name: "zstd", target: "/api/lru", encoding: "zstd", body: encode(t, "zstd", `{"key":"zstd","value":1}`), status: http.StatusCreated},
+		{name: "identity", target: "/api/lru", encoding: "identity", body: []byte(`{"key":"identity","value":1}`), status: http.StatusCreated},
+		{name: "import", target: "/api/import", encoding: "gzip", body: encode(t, "gzip", `{"key":"import","value":1}`+"\n"), status: http.StatusOK},
+		{name: "not gzip", target: "/api/lru", encoding: "gzip", body: []byte(`{"key":"a","value":1}`), status: http.StatusBadRequest, code: "unreadable_body"},
+		{name: "truncated", target: "/api/lru", encoding: "zstd", body: encode(t, "zstd", `{"key":"a","value":1}`)[:10], status: http.StatusBadRequest, code: "unreadable_body"},
+		{name: "unsupported", target: "/api/lru", encoding: "br", body: []byte(`{"key":"a","value":1}`), status: http.StatusUnsupportedMediaType, code: "unsupported_encoding"},
+		{name: "gzip bomb", target: "/api/lru", encoding: "gzip", body: encode(t, "gzip", bomb), status: http.StatusRequestEntityTooLarge, code: "body_too_large"},
+		{name: "zstd bomb", target: "/api/lru", encoding: "zstd", body: encode(t, "zstd", bomb), status: http.StatusRequestEntityTooLarge, code: "body_too_large"},
+		{name: "too large", target: "/api/lru", body: []byte(bomb), status: http.StatusRequestEntityTooLarge, code: "body_too_large"},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPost, test.target, bytes.NewReader(test.body))
+		r.Header.Set("Content-Encoding", test.encoding)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		assert.Equal(t, test.status, w.Code, test.name)
+
+		if test.code != "" {
+			assert.Contains(t, w.Body.String(), `"code":"`+test.code+`"`, test.name)
+		}
+	}
+
+	for _, key := range []string{"gzip", "zstd", "identity", "import"} {
+		value, _, err := c.Get(context.Background(), key)
+		assert.NoError(t, err, key)
+		assert.Equal(t, int64(1), value, key)
+	}
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/skantay/lru-api/internal/cache"
+	"github.com/skantay/lru-api/internal/logging"
+)
+
+// Errors are answered with RFC 7807 problem details.
+// Codes are stable identifiers clients may rely on, titles and details may change.
+const problemContentType = "application/problem+json"
+
+// Type URIs of problems are this prefix followed by the code
+const problemTypePrefix = "urn:lru-api:problem:"
+
+// Status of requests cancelled by the client, as nginx logs them
+const statusClientClosedRequest = 499
+
+// apiError is a kind of error the API answers with
+type apiError struct {
+	status int
+	code   string
+	title  string
+}
+
+var (
+	errUnreadableBody     = &apiError{http.StatusBadRequest, "unreadable_body", "Request body could not be read"}
+	errBodyTooLarge       = &apiError{http.StatusRequestEntityTooLarge, "body_too_large", "Request body is too large"}
+	errMalformedJSON      = &apiError{http.StatusBadRequest, "malformed_json", "Request body is not valid JSON"}
+	errMalformedBody      = &apiError{http.StatusBadRequest, "malformed_body", "Request body could not be decoded"}
+	errEmptyKey           = &apiError{http.StatusBadRequest, "empty_key", "Key is empty"}
+	errNullValue          = &apiError{http.StatusBadRequest, "null_value", "Value is missing or null"}
+	errInvalidFilter      = &apiError{http.StatusBadRequest, "invalid_filter", "Key and prefix are mutually exclusive"}
+	errInvalidLastEventID = &apiError{http.StatusBadRequest, "invalid_last_event_id", "Last-Event-ID is not an event ID"}
+	errUnauthenticated    = &apiError{http.StatusUnauthorized, "unauthenticated", "Authentication required"}
+	errForbidden          = &apiError{http.StatusForbidden, "forbidden", "Operation not permitted"}
+	errQuotaExceeded      = &apiError{http.StatusForbidden, "quota_exceeded", "Storage quota exceeded"}
+	errKeyNotFound        = &apiError{http.StatusNotFound, "key_not_found", "Key does not exist"}
+	errRouteNotFound      = &apiError{http.StatusNotFound, "route_not_found", "Route does not exist"}
+	errMethodNotAllowed   = &apiError{http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed"}
+	errRateLimited        = &apiError{http.StatusTooManyRequests, "rate_limited", "Too many requests"}
+	errRequestCanceled    = &apiError{statusClientClosedRequest, "request_canceled", "Request was canceled"}
+	errInvalidCacheSize   = &apiError{http.StatusInternalServerError, "invalid_cache_size", "Cache is misconfigured"}
+	errInternal           = &apiError{http.StatusInternalServerError, "internal", "Internal error"}
+	errTimeout            = &apiError{http.StatusGatewayTimeout, "timeout", "Request timed out"}
+)
+
+type problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// problem replies with the error, detail explains this occurrence and may be empty
+func (a *api) problem(w http.ResponseWriter, r *http.Request, e *apiError, detail string) {
+	// Audited requests that failed are recorded with the code
+	if info := auditInfoFromContext(r.Context()); info != nil && info.detail == "" {
+		info.detail = e.code
+	}
+
+	data, err := json.Marshal(problem{
+		Type:      problemTypePrefix + e.code,
+		Title:     e.title,
+		Status:    e.status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      e.code,
+		RequestID: logging.RequestID(r.Context()),
+	})
+	if err != nil {
+		a.log.ErrorContext(r.Context(), err.Error())
+
+		w.WriteHeader(e.status)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(e.status)
+	w.Write(data)
+}
+
+// cacheError replies with the problem matching an error of the cache.
+// Unexpected errors are logged, their text is not sent to the client.
+func (a *api) cacheError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, cache.ErrKeyDoesNotExist):
+		a.problem(w, r, errKeyNotFound, "")
+	case errors.Is(err, cache.ErrInvalidCacheSize):
+		a.log.ErrorContext(r.Context(), err.Error())
+
+		a.problem(w, r, errInvalidCacheSize, "")
+	case errors.Is(err, context.Canceled):
+		a.problem(w, r, errRequestCanceled, "")
+	case errors.Is(err, context.DeadlineExceeded):
+		a.problem(w, r, errTimeout, "")
+	default:
+		a.log.ErrorContext(r.Context(), err.Error())
+
+		a.problem(w, r, errInternal, "")
+	}
+}
+
+func (a *api) notFound(w http.ResponseWriter, r *http.Request) {
+	a.problem(w, r, errRouteNotFound, "")
+}
+
+func (a *api) methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	a.problem(w, r, errMethodNotAllowed, r.Method+" is not allowed on "+r.URL.Path)
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skantay/lru-api/internal/cache"
+	"github.com/skantay/lru-api/internal/cache/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingCache fails every operation with err
+type failingCache struct {
+	ILRUCache
+	err error
+}
+
+func (c failingCache) Get(context.Context, string) (interface{}, time.Time, error) {
+	return nil, time.Time{}, c.err
+}
+
+func TestProblems(t *testing.T) {
+	c, err := cache.New(10, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	tests := []struct {
+		name   string
+		cache  ILRUCache
+		method string
+		target string
+		body   string
+		status int
+		code   string
+	}{
+		{name: "malformed json", method: http.MethodPost, target: "/api/lru", body: `{"key":`, status: http.StatusBadRequest, code: "malformed_json"},
+		{name: "empty key", method: http.MethodPost, target: "/api/lru", body: `{"value":1}`, status: http.StatusBadRequest, code: "empty_key"},
+		{name: "null value", method: http.MethodPost, target: "/api/lru", body: `{"key":"a","value":null}`, status: http.StatusBadRequest, code: "null_value"},
+		{name: "key not found", method: http.MethodGet, target: "/api/lru/a", status: http.StatusNotFound, code: "key_not_found"},
+		{name: "evict not found", method: http.MethodDelete, target: "/api/lru/a", status: http.StatusNotFound, code: "key_not_found"},
+		{name: "invalid filter", method: http.MethodGet, target: "/api/watch?key=a&prefix=b", status: http.StatusBadRequest, code: "invalid_filter"},
+		{name: "invalid last event id", method: http.MethodGet, target: "/api/watch?key=a", status: http.StatusBadRequest, code: "invalid_last_event_id"},
+		{name: "route not found", method: http.MethodGet, target: "/api/nope", status: http.StatusNotFound, code: "route_not_found"},
+		{name: "method not allowed", method: http.MethodPut, target: "/api/lru", status: http.StatusMethodNotAllowed, code: "method_not_allowed"},
+		{name: "websocket upgrade", method: http.MethodGet, target: "/api/ws", status: http.StatusBadRequest, code: "websocket_upgrade_failed"},
+		{name: "canceled", cache: failingCache{err: context.Canceled}, method: http.MethodGet, target: "/api/lru/a", status: 499, code: "request_canceled"},
+		{name: "timeout", cache: failingCache{err: context.DeadlineExceeded}, method: http.MethodGet, target: "/api/lru/a", status: http.StatusGatewayTimeout, code: "timeout"},
+		{name: "invalid cache size", cache: failingCache{err: cache.ErrInvalidCacheSize}, method: http.MethodGet, target: "/api/lru/a", status: http.StatusInternalServerError, code: "invalid_cache_size"},
+		{name: "internal", cache: failingCache{err: io.ErrUnexpectedEOF}, method: http.MethodGet, target: "/api/lru/a", status: http.StatusInternalServerError, code: "internal"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var lru ILRUCache = c
+			if test.cache != nil {
+				lru = test.cache
+			}
+
+			r := httptest.NewRequest(test.method, test.target, strings.NewReader(test.body))
+			r.Header.Set("X-Request-Id", "req-1")
+
+			if test.code == "invalid_last_event_id" {
+				r.Header.Set("Last-Event-ID", "latest")
+			}
+
+			w := httptest.NewRecorder()
+			New(lru, log).ServeHTTP(w, r)
+
+			assert.Equal(t, test.status, w.Code)
+			assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+
+			var body problem
+
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, test.code, body.Code)
+			assert.Equal(t, "urn:lru-api:problem:"+test.code, body.Type)
+			assert.Equal(t, test.status, body.Status)
+			assert.NotEmpty(t, body.Title)
+			assert.Equal(t, r.URL.Path, body.Instance)
+			assert.Equal(t, "req-1", body.RequestID)
+
+			// Errors of unexpected failures are logged, not sent to clients
+			if test.code == "internal" {
+				assert.Empty(t, body.Detail)
+			}
+		})
+	}
+}
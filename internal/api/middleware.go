@@ -1,19 +1,159 @@
 package api
 
 import (
+	"context"
+	"crypto/rand"
+	"log/slog"
+	mathrand "math/rand/v2"
+	"net"
 	"net/http"
 	"time"
+
+	"github.com/skantay/lru-api/internal/logging"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/chi/v5"
 )
 
+// requestInfo collects details of a request set by inner middlewares for the request log
+type requestInfo struct {
+	principal string
+}
+
+type requestInfoKey struct{}
+
+func requestInfoFromContext(ctx context.Context) *requestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(*requestInfo)
+
+	return info
+}
+
+// requestIDHeader carries the ID correlating a request with its log records, it is echoed in the response
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds request IDs sent by clients, as they are logged
+const maxRequestIDLength = 128
+
+// requestID keeps the request ID sent by the client or generates one, so it is logged and echoed in the response
+func (a *api) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = rand.Text()
+		}
+
+		w.Header().Set(requestIDHeader, id)
+
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
+	})
+}
+
+// validRequestID accepts visible ASCII up to maxRequestIDLength, so IDs cannot forge log lines
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// WithAccessLog logs handled requests at level, sampleRatio of them is logged.
+// Server errors are logged at ERROR at least and are never sampled out.
+func WithAccessLog(level slog.Level, sampleRatio float64) Option {
+	return func(a *api) {
+		a.accessLevel = level
+		a.accessSampleRatio = sampleRatio
+	}
+}
+
 func (a *api) logger(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
-		a.log.Debug("received request", "method", r.Method, "path", r.URL.Path)
+		info := &requestInfo{}
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, info)))
 
-		next.ServeHTTP(w, r)
+		duration := time.Since(start)
+		status := status(ww, r)
 
-		a.log.Debug("request handled", "path", r.URL.Path, "duration", time.Since(start))
+		a.accessLog(r, status, ww.BytesWritten(), duration, info)
+
+		if a.metrics != nil {
+			a.metrics.ObserveRequest(r.Method, routePattern(r), status, duration)
+		}
 	})
+}
+
+// accessLog writes the access log line of a handled request
+func (a *api) accessLog(r *http.Request, status, bytes int, duration time.Duration, info *requestInfo) {
+	level := a.accessLevel
+
+	if status >= http.StatusInternalServerError {
+		level = max(level, slog.LevelError)
+	} else if a.accessSampleRatio < 1 && mathrand.Float64() >= a.accessSampleRatio {
+		return
+	}
+
+	ctx := r.Context()
+
+	if !a.log.Enabled(ctx, level) {
+		return
+	}
+
+	a.log.LogAttrs(ctx, level, "request handled",
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.String("route", routePattern(r)),
+		slog.String("proto", r.Proto),
+		slog.Int("status", status),
+		slog.Int("bytes", bytes),
+		slog.Duration("duration", duration),
+		slog.String("client_ip", clientIP(r)),
+		slog.String("user_agent", r.UserAgent()),
+		slog.String("principal", info.principal),
+	)
+}
+
+// clientIP returns the address of the peer, forwarding headers are not trusted
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// routePattern returns the matched chi route, so metrics are not labelled by keys
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		if pattern := rctx.RoutePattern(); pattern != "" {
+			return pattern
+		}
+	}
+
+	return "unmatched"
+}
+
+// status returns the status code sent to the client
+func status(ww middleware.WrapResponseWriter, r *http.Request) int {
+	if ww.Status() != 0 {
+		return ww.Status()
+	}
+
+	// A hijacked WebSocket connection answered with 101 bypassing the writer
+	if r.Header.Get("Upgrade") != "" {
+		return http.StatusSwitchingProtocols
+	}
 
+	// Nothing was written, net/http sends 200
+	return http.StatusOK
 }
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skantay/lru-api/internal/cache"
+	"github.com/skantay/lru-api/internal/cache/mocks"
+	"github.com/skantay/lru-api/internal/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	c, err := cache.New(10, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+
+	log, err := logging.New(&buf, logging.FormatJSON, slog.LevelDebug)
+	assert.NoError(t, err)
+
+	handler := New(c, log)
+
+	tests := []struct {
+		name string
+		id   string
+		kept bool
+	}{
+		{name: "sent", id: "req-1", kept: true},
+		{name: "generated"},
+		{name: "too long", id: strings.Repeat("a", maxRequestIDLength+1)},
+		{name: "control characters", id: "req\n1"},
+	}
+
+	for _, test := range tests {
+		buf.Reset()
+
+		r := httptest.NewRequest(http.MethodGet, "/api/lru/a", nil)
+		if test.id != "" {
+			r.Header.Set(requestIDHeader, test.id)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		id := w.Header().Get(requestIDHeader)
+		assert.NotEmpty(t, id, test.name)
+		assert.Equal(t, test.kept, id == test.id, test.name)
+
+		// The ID is in the problem and in every record of the request, including the records of the cache
+		var problem problem
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem), test.name)
+		assert.Equal(t, id, problem.RequestID, test.name)
+
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			var record map[string]interface{}
+			assert.NoError(t, json.Unmarshal([]byte(line), &record), test.name)
+			assert.Equal(t, id, record["request_id"], test.name)
+		}
+	}
+
+	// Records logged by the cache with the context of the request
+	buf.Reset()
+
+	logged, err := cache.New(10, time.Minute, log)
+	assert.NoError(t, err)
+
+	_, _, err = logged.Get(logging.WithRequestID(context.Background(), "req-2"), "a")
+	assert.ErrorIs(t, err, cache.ErrKeyDoesNotExist)
+	assert.Contains(t, buf.String(), `"request_id":"req-2"`)
+}
+
+func TestAccessLog(t *testing.T) {
+	c, err := cache.New(10, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	assert.NoError(t, c.Put(context.Background(), "a", "value", 0))
+
+	tests := []struct {
+		name        string
+		level       slog.Level
+		sampleRatio float64
+		cache       ILRUCache
+		logged      bool
+		recordLevel string
+		status      float64
+	}{
+		{name: "default level", level: slog.LevelWarn, sampleRatio: 1, logged: true, recordLevel: "WARN", status: http.StatusOK},
+		{name: "below the log level", level: slog.LevelDebug, sampleRatio: 1},
+		{name: "sampled out", level: slog.LevelWarn, sampleRatio: 0},
+		{name: "server errors are not sampled", level: slog.LevelInfo, sampleRatio: 0, cache: failingCache{err: cache.ErrInvalidCacheSize}, logged: true, recordLevel: "ERROR", status: http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+
+		log, err := logging.New(&buf, logging.FormatJSON, slog.LevelInfo)
+		assert.NoError(t, err)
+
+		cache := test.cache
+		if cache == nil {
+			cache = c
+		}
+
+		r := httptest.NewRequest(http.MethodGet, "/api/lru/a", nil)
+		r.Header.Set("User-Agent", "test")
+		r.Header.Set("X-Request-ID", "req-1")
+
+		New(cache, log, WithAccessLog(test.level, test.sampleRatio)).ServeHTTP(httptest.NewRecorder(), r)
+
+		var record map[string]interface{}
+
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			if strings.Contains(line, `"msg":"request handled"`) {
+				assert.NoError(t, json.Unmarshal([]byte(line), &record), test.name)
+			}
+		}
+
+		if !test.logged {
+			assert.Nil(t, record, test.name)
+
+			continue
+		}
+
+		assert.Equal(t, test.recordLevel, record["level"], test.name)
+		assert.Equal(t, http.MethodGet, record["method"], test.name)
+		assert.Equal(t, "/api/lru/a", record["path"], test.name)
+		assert.Equal(t, "/api/lru/{key}", record["route"], test.name)
+		assert.Equal(t, test.status, record["status"], test.name)
+		assert.Greater(t, record["bytes"], float64(0), test.name)
+		assert.Contains(t, record, "duration", test.name)
+		assert.Equal(t, "192.0.2.1", record["client_ip"], test.name)
+		assert.Equal(t, "test", record["user_agent"], test.name)
+		assert.Equal(t, "req-1", record["request_id"], test.name)
+	}
+}
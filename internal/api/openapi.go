@@ -0,0 +1,28 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPISpec describes every route of the API, TestOpenAPIRoutes keeps it in sync with the router
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// docsPage renders openAPISpec, it is self-contained so it works without access to the internet
+//
+//go:embed docs.html
+var docsPage []byte
+
+// openAPI serves the OpenAPI 3.1 description of the API
+func (a *api) openAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
+
+// docs serves the interactive documentation
+func (a *api) docs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(docsPage)
+}
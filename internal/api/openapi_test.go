@@ -0,0 +1,124 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/skantay/lru-api/internal/audit"
+	"github.com/skantay/lru-api/internal/auth"
+	"github.com/skantay/lru-api/internal/cache"
+	"github.com/skantay/lru-api/internal/cache/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+type stubMetrics struct{}
+
+func (stubMetrics) ObserveRequest(method, route string, status int, duration time.Duration) {}
+
+func (stubMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {}
+
+type stubHealth struct{}
+
+func (stubHealth) Healthz(w http.ResponseWriter, r *http.Request) {}
+
+func (stubHealth) Livez(w http.ResponseWriter, r *http.Request) {}
+
+func (stubHealth) Readyz(w http.ResponseWriter, r *http.Request) {}
+
+type stubAuditor struct{}
+
+func (stubAuditor) Append(record audit.Record) (audit.Record, error) {
+	return record, nil
+}
+
+func (stubAuditor) Query(filter audit.Filter) ([]audit.Record, error) {
+	return nil, nil
+}
+
+func (stubAuditor) Verify() (int, error) {
+	return 0, nil
+}
+
+type denyAll struct{}
+
+func (denyAll) Authenticate(r *http.Request) (auth.Principal, error) {
+	return auth.Principal{}, auth.ErrMissingCredentials
+}
+
+type openAPIDocument struct {
+	OpenAPI string                                `json:"openapi"`
+	Paths   map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+// TestOpenAPIRoutes fails if a route of the router is missing from the description or the description has a route the router does not
+func TestOpenAPIRoutes(t *testing.T) {
+	c, err := cache.New(10, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	// Every optional route is enabled
+	handler := New(c, slog.New(slog.NewTextHandler(io.Discard, nil)), WithMetrics(stubMetrics{}), WithHealth(stubHealth{}), WithAudit(stubAuditor{}))
+
+	var spec openAPIDocument
+
+	assert.NoError(t, json.Unmarshal(openAPISpec, &spec))
+	assert.Equal(t, "3.1.0", spec.OpenAPI)
+
+	routed := map[string]bool{}
+
+	err = chi.Walk(handler.Handler.(chi.Routes), func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		method = strings.ToLower(method)
+		routed[method+" "+route] = true
+
+		_, ok := spec.Paths[route][method]
+		assert.True(t, ok, "%s %s is missing from openapi.json", method, route)
+
+		return nil
+	})
+	assert.NoError(t, err)
+
+	for path, item := range spec.Paths {
+		for method := range item {
+			if method == "parameters" {
+				continue
+			}
+
+			assert.True(t, routed[method+" "+path], "%s %s of openapi.json is not routed", method, path)
+		}
+	}
+}
+
+func TestOpenAPIServed(t *testing.T) {
+	c, err := cache.New(10, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	handler := New(c, slog.New(slog.NewTextHandler(io.Discard, nil)), WithAuthenticator(denyAll{}))
+
+	tests := []struct {
+		target      string
+		contentType string
+	}{
+		{target: "/api/openapi.json", contentType: "application/json"},
+		{target: "/api/docs", contentType: "text/html; charset=utf-8"},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.target, nil))
+
+		assert.Equal(t, http.StatusOK, w.Code, test.target)
+		assert.Equal(t, test.contentType, w.Header().Get("Content-Type"), test.target)
+	}
+
+	// The rest of /api stays authenticated
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/lru", nil))
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
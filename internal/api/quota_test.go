@@ -0,0 +1,89 @@
+package api
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skantay/lru-api/internal/cache"
+	"github.com/skantay/lru-api/internal/cache/mocks"
+	"github.com/skantay/lru-api/internal/quota"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuota(t *testing.T) {
+	c, err := cache.New(10, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	tracker := quota.New(quota.Config{Default: quota.Limits{Entries: 1}})
+
+	handler := New(c, slog.New(slog.NewTextHandler(io.Discard, nil)), WithQuota(tracker))
+
+	create := func(body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/lru", strings.NewReader(body))
+		r.RemoteAddr = "10.0.0.1:1234"
+		r.Header.Set("X-Request-Id", "req-1")
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		return w
+	}
+
+	assert.Equal(t, http.StatusCreated, create(`{"key":"a","value":"1"}`).Code)
+	assert.Equal(t, http.StatusCreated, create(`{"key":"a","value":"2"}`).Code)
+
+	w := create(`{"key":"b","value":"1"}`)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.JSONEq(t, `{
+		"type": "urn:lru-api:problem:quota_exceeded",
+		"title": "Storage quota exceeded",
+		"status": 403,
+		"detail": "quota exceeded: tenant 10.0.0.1 may store 1 entries",
+		"instance": "/api/lru",
+		"code": "quota_exceeded",
+		"request_id": "req-1"
+	}`, w.Body.String())
+
+	_, _, err = c.Get(t.Context(), "b")
+	assert.ErrorIs(t, err, cache.ErrKeyDoesNotExist)
+}
+
+func TestQuotaRollback(t *testing.T) {
+	c, err := cache.New(10, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	tracker := quota.New(quota.Config{})
+
+	handler := New(c, slog.New(slog.NewTextHandler(io.Discard, nil)), WithQuota(tracker))
+
+	create := func(ctx context.Context, body string) int {
+		r := httptest.NewRequestWithContext(ctx, http.MethodPost, "/api/lru", strings.NewReader(body))
+		r.RemoteAddr = "10.0.0.1:1234"
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusCreated, create(context.Background(), `{"key":"a","value":"1"}`))
+
+	usage := tracker.Usage("10.0.0.1")
+
+	// The key is still cached with its previous value, so it stays charged for it
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.NotEqual(t, http.StatusCreated, create(ctx, `{"key":"a","value":"a much longer value"}`))
+	assert.Equal(t, usage, tracker.Usage("10.0.0.1"))
+
+	assert.NotEqual(t, http.StatusCreated, create(ctx, `{"key":"b","value":"1"}`))
+	assert.Equal(t, usage, tracker.Usage("10.0.0.1"))
+}
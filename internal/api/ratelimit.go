@@ -0,0 +1,82 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/skantay/lru-api/internal/ratelimit"
+)
+
+// IRateLimiter limits the request rate of clients
+type IRateLimiter interface {
+	// Allow учет запроса клиента, возвращает разрешен ли запрос и состояние его лимита
+	Allow(client string) ratelimit.Result
+}
+
+// IQuota limits what tenants store in the cache
+type IQuota interface {
+	// Reserve резервирование места под ключ арендатора, ошибка если квота превышена.
+	// rollback отменяет резервирование, если запись не удалась
+	Reserve(tenant, key string, size uint64) (rollback func(), err error)
+}
+
+// WithRateLimits limits requests to /api of every client, reads and writes separately.
+// A nil limiter does not limit its requests.
+func WithRateLimits(read, write IRateLimiter) Option {
+	return func(a *api) {
+		a.readLimiter = read
+		a.writeLimiter = write
+	}
+}
+
+// WithQuota rejects writes exceeding the quota of the client
+func WithQuota(quota IQuota) Option {
+	return func(a *api) {
+		a.quota = quota
+	}
+}
+
+// rateLimit replies 429 once the client used up its bucket.
+// RateLimit-* headers follow the IETF draft, values are in requests and seconds.
+func (a *api) rateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limiter := a.writeLimiter
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			limiter = a.readLimiter
+		}
+
+		if limiter == nil {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		result := limiter.Allow(principalOrIP(r))
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(seconds(result.Reset)))
+
+		if !result.Allowed {
+			a.log.InfoContext(r.Context(), "rate limited", "client", principalOrIP(r), "method", r.Method, "path", r.URL.Path)
+
+			retryAfter := strconv.Itoa(max(seconds(result.RetryAfter), 1))
+
+			w.Header().Set("Retry-After", retryAfter)
+			a.problem(w, r, errRateLimited, "retry in "+retryAfter+"s")
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// seconds rounds d up to whole seconds
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
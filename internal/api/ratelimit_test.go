@@ -0,0 +1,101 @@
+package api
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/skantay/lru-api/internal/cache"
+	"github.com/skantay/lru-api/internal/cache/mocks"
+	"github.com/skantay/lru-api/internal/ratelimit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimit(t *testing.T) {
+	c, err := cache.New(10, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	handler := New(c, slog.New(slog.NewTextHandler(io.Discard, nil)), WithRateLimits(
+		ratelimit.New(ratelimit.Limit{Rate: 0.01, Burst: 2}),
+		nil,
+	))
+
+	request := func(method, remote string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/api/lru", nil)
+		r.RemoteAddr = remote
+		r.Header.Set("X-Request-Id", "req-1")
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		return w
+	}
+
+	w := request(http.MethodGet, "10.0.0.1:1234")
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "100", w.Header().Get("RateLimit-Reset"))
+
+	assert.Equal(t, http.StatusNoContent, request(http.MethodGet, "10.0.0.1:1235").Code)
+
+	w = request(http.MethodGet, "10.0.0.1:1236")
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "100", w.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{
+		"type": "urn:lru-api:problem:rate_limited",
+		"title": "Too many requests",
+		"status": 429,
+		"detail": "retry in 100s",
+		"instance": "/api/lru",
+		"code": "rate_limited",
+		"request_id": "req-1"
+	}`, w.Body.String())
+
+	// Other clients and writes are not limited
+	assert.Equal(t, http.StatusNoContent, request(http.MethodGet, "10.0.0.2:1234").Code)
+
+	w = request(http.MethodDelete, "10.0.0.1:1237")
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+}
+
+func TestRateLimitAuthentication(t *testing.T) {
+	c, err := cache.New(10, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	handler := New(c, slog.New(slog.NewTextHandler(io.Discard, nil)),
+		WithAuthenticator(userHeader{}),
+		WithRateLimits(ratelimit.New(ratelimit.Limit{Rate: 0.01, Burst: 1}), nil),
+	)
+
+	request := func(user, remote string) int {
+		r := httptest.NewRequest(http.MethodGet, "/api/lru", nil)
+		r.RemoteAddr = remote
+
+		if user != "" {
+			r.Header.Set("X-User", user)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		return w.Code
+	}
+
+	// Failed authentications are limited by client IP
+	assert.Equal(t, http.StatusUnauthorized, request("", "10.0.0.1:1234"))
+	assert.Equal(t, http.StatusTooManyRequests, request("", "10.0.0.1:1235"))
+
+	// Principals have their own bucket, whatever their IP
+	assert.Equal(t, http.StatusNoContent, request("alice", "10.0.0.1:1236"))
+	assert.Equal(t, http.StatusTooManyRequests, request("alice", "10.0.0.2:1234"))
+	assert.Equal(t, http.StatusNoContent, request("bob", "10.0.0.2:1235"))
+}
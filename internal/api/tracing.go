@@ -0,0 +1,86 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-chi/chi/middleware"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const tracerName = "github.com/skantay/lru-api/internal/api"
+
+// WithTracerProvider records a span for every request, continuing the trace from the traceparent header
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(a *api) {
+		a.tracer = provider.Tracer(tracerName)
+	}
+}
+
+func defaultTracer() trace.Tracer {
+	return noop.NewTracerProvider().Tracer(tracerName)
+}
+
+// tracing starts a server span for the request, it is named by the route pattern once the request is routed
+func (a *api) tracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := a.propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		ctx, span := a.tracer.Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+				semconv.ClientAddress(r.RemoteAddr),
+			),
+		)
+		defer span.End()
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		route := routePattern(r)
+		statusCode := status(ww, r)
+
+		span.SetName(r.Method + " " + route)
+		span.SetAttributes(
+			semconv.HTTPRoute(route),
+			semconv.HTTPResponseStatusCode(statusCode),
+		)
+
+		if statusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(statusCode))
+		}
+	})
+}
+
+// marshal encodes v in its own span, so encoding is told apart from the cache and the network
+func (a *api) marshal(ctx context.Context, c *codec, v interface{}) ([]byte, error) {
+	_, span := a.tracer.Start(ctx, c.name+".Marshal")
+	defer span.End()
+
+	data, err := c.marshal(v)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return data, err
+}
+
+// unmarshal decodes data in its own span
+func (a *api) unmarshal(ctx context.Context, c *codec, data []byte, v interface{}) error {
+	_, span := a.tracer.Start(ctx, c.name+".Unmarshal")
+	defer span.End()
+
+	err := c.unmarshal(data, v)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return err
+}
@@ -0,0 +1,299 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/skantay/lru-api/internal/authz"
+	"github.com/skantay/lru-api/internal/cache"
+)
+
+// IUpdater is implemented by caches that update nodes/items atomically.
+// If the cache passed to New implements it, /api/v2 is enabled.
+type IUpdater interface {
+	// Update атомарное чтение и условная замена данных по ключу
+	Update(ctx context.Context, key string, fn cache.UpdateFunc) error
+}
+
+// Format of times in v2 responses, RFC 3339 in UTC with milliseconds
+const timeFormatV2 = "2006-01-02T15:04:05.000Z07:00"
+
+var (
+	errInvalidTTL         = &apiError{http.StatusBadRequest, "invalid_ttl", "TTL is not a duration"}
+	errInvalidCondition   = &apiError{http.StatusBadRequest, "invalid_condition", "nx and xx are mutually exclusive"}
+	errPreconditionFailed = &apiError{http.StatusPreconditionFailed, "precondition_failed", "Condition of the write is not met"}
+)
+
+type previousV2 struct {
+	Value     interface{} `json:"value"`
+	ExpiresAt string      `json:"expires_at,omitempty"`
+}
+
+type putResponseV2 struct {
+	Key     string `json:"key"`
+	Created bool   `json:"created"`
+	// Previous is only set when requested, it is null if the key did not exist
+	Previous *previousV2 `json:"previous,omitempty"`
+}
+
+type getResponseV2 struct {
+	Key       string      `json:"key"`
+	Value     interface{} `json:"value"`
+	ExpiresAt string      `json:"expires_at"`
+}
+
+type deleteResponseV2 struct {
+	Key      string      `json:"key"`
+	Previous *previousV2 `json:"previous"`
+}
+
+func formatTimeV2(t time.Time) string {
+	return t.UTC().Format(timeFormatV2)
+}
+
+// parseTTL parses seconds or a Go duration, e.g. "90", "1m30s" or "1500ms".
+// "keep" keeps the current expiration time, empty applies the default TTL.
+func parseTTL(s string) (time.Duration, error) {
+	switch s {
+	case "":
+		return 0, nil
+	case "keep":
+		return cache.KeepTTL, nil
+	}
+
+	if seconds, err := strconv.ParseUint(s, 10, 32); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	ttl, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+
+	if ttl <= 0 {
+		return 0, fmt.Errorf("TTL %s is not positive", s)
+	}
+
+	return ttl, nil
+}
+
+// putV2 creates or replaces a node/item with the value of the body.
+// JSON, MessagePack and CBOR bodies are decoded, bodies of other content types are stored verbatim as blobs.
+//
+// The TTL is read from the ttl query parameter or the X-TTL header.
+// nx (or If-None-Match: *) only creates, xx (or If-Match: *) only replaces.
+// return=previous adds the previous value to the response.
+func (a *api) putV2(w http.ResponseWriter, r *http.Request) {
+	key := keyParam(r)
+	query := r.URL.Query()
+
+	ttlParam := query.Get("ttl")
+	if ttlParam == "" {
+		ttlParam = r.Header.Get("X-TTL")
+	}
+
+	ttl, err := parseTTL(ttlParam)
+	if err != nil {
+		a.problem(w, r, errInvalidTTL, err.Error())
+
+		return
+	}
+
+	nx := query.Has("nx") || r.Header.Get("If-None-Match") == "*"
+	xx := query.Has("xx") || r.Header.Get("If-Match") == "*"
+
+	if nx && xx {
+		a.problem(w, r, errInvalidCondition, "")
+
+		return
+	}
+
+	returnPrevious := query.Get("return") == "previous"
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			a.problem(w, r, errBodyTooLarge, "body is larger than "+strconv.FormatInt(maxBytesErr.Limit, 10)+" bytes")
+
+			return
+		}
+
+		a.problem(w, r, errUnreadableBody, err.Error())
+
+		return
+	}
+
+	var value interface{}
+
+	contentType := r.Header.Get("Content-Type")
+
+	if c := requestCodec(contentType); c != nil {
+		if err := a.unmarshal(r.Context(), c, data, &value); err != nil {
+			a.problem(w, r, c.malformed, err.Error())
+
+			return
+		}
+
+		if value == nil {
+			a.problem(w, r, errNullValue, "")
+
+			return
+		}
+	} else {
+		value = cache.Blob{ContentType: contentType, Data: data}
+	}
+
+	if !a.authorize(w, r, authz.OpPut, key) {
+		return
+	}
+
+	if returnPrevious && !a.authorize(w, r, authz.OpGet, key) {
+		return
+	}
+
+	var (
+		previous  *previousV2
+		existed   bool
+		stored    bool
+		quotaErr  error
+		tenant    = principalOrIP(r)
+		valueSize = cache.SizeOf(key, value)
+	)
+
+	err = a.updater.Update(r.Context(), key, func(current interface{}, expiresAt time.Time, ok bool) (interface{}, time.Duration, bool) {
+		existed = ok
+
+		if ok {
+			previous = &previousV2{Value: current, ExpiresAt: formatTimeV2(expiresAt)}
+		}
+
+		if (nx && ok) || (xx && !ok) {
+			return nil, 0, false
+		}
+
+		// The quota is reserved while the cache is locked, so a write that is not stored is not charged
+		if a.quota != nil {
+			if _, quotaErr = a.quota.Reserve(tenant, key, valueSize); quotaErr != nil {
+				return nil, 0, false
+			}
+		}
+
+		stored = true
+
+		return value, ttl, true
+	})
+	if err != nil {
+		a.cacheError(w, r, err)
+
+		return
+	}
+
+	if quotaErr != nil {
+		a.log.InfoContext(r.Context(), "quota exceeded", "client", tenant, "key", key, "error", quotaErr.Error())
+
+		a.problem(w, r, errQuotaExceeded, quotaErr.Error())
+
+		return
+	}
+
+	if !stored {
+		detail := "key does not exist"
+		if existed {
+			detail = "key exists"
+		}
+
+		a.problem(w, r, errPreconditionFailed, detail)
+
+		return
+	}
+
+	response := putResponseV2{
+		Key:     key,
+		Created: !existed,
+	}
+
+	if returnPrevious {
+		response.Previous = previous
+	}
+
+	status := http.StatusOK
+	if !existed {
+		status = http.StatusCreated
+	}
+
+	a.respond(w, r, status, response)
+}
+
+// getV2 handles a retrieval of a node/item from cache.
+// Blobs are answered with their bytes and content type, range requests are supported.
+// Conditional requests are answered with 304.
+func (a *api) getV2(w http.ResponseWriter, r *http.Request) {
+	key := keyParam(r)
+
+	if !a.authorize(w, r, authz.OpGet, key) {
+		return
+	}
+
+	entry, err := a.getEntry(r.Context(), key)
+	if err != nil {
+		a.cacheError(w, r, err)
+
+		return
+	}
+
+	if blob, ok := entry.Value.(cache.Blob); ok {
+		w.Header().Set("Content-Type", blob.ContentType)
+		w.Header().Set("X-Expires-At", formatTimeV2(entry.ExpiresAt))
+		a.setCacheHeaders(w.Header(), entry, strongETag([]byte(blob.ContentType), blob.Data))
+
+		// ServeContent evaluates the conditional and range requests against ETag and the modification time
+		http.ServeContent(w, r, "", entry.ModifiedAt, bytes.NewReader(blob.Data))
+
+		return
+	}
+
+	a.respondCacheable(w, r, entry, getResponseV2{
+		Key:       key,
+		Value:     entry.Value,
+		ExpiresAt: formatTimeV2(entry.ExpiresAt),
+	})
+}
+
+// deleteV2 handles a deletion of a node/item, return=previous answers with the deleted value
+func (a *api) deleteV2(w http.ResponseWriter, r *http.Request) {
+	key := keyParam(r)
+	returnPrevious := r.URL.Query().Get("return") == "previous"
+
+	if !a.authorize(w, r, authz.OpEvict, key) {
+		return
+	}
+
+	if returnPrevious && !a.authorize(w, r, authz.OpGet, key) {
+		return
+	}
+
+	value, err := a.cache.Evict(r.Context(), key)
+	if err != nil {
+		a.cacheError(w, r, err)
+
+		return
+	}
+
+	if !returnPrevious {
+		w.WriteHeader(http.StatusNoContent)
+
+		return
+	}
+
+	a.respond(w, r, http.StatusOK, deleteResponseV2{
+		Key:      key,
+		Previous: &previousV2{Value: value},
+	})
+}
@@ -0,0 +1,214 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skantay/lru-api/internal/cache"
+	"github.com/skantay/lru-api/internal/cache/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestV2(t *testing.T) {
+	c, err := cache.New(10, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	handler := New(c, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		header map[string]string
+		body   string
+		status int
+		resp   string
+	}{
+		{name: "xx on missing key", method: http.MethodPut, target: "/api/v2/keys/a?xx", body: `1`, status: http.StatusPreconditionFailed},
+		{name: "create", method: http.MethodPut, target: "/api/v2/keys/a?nx", body: `{"n":1}`, status: http.StatusCreated,
+			resp: `{"key":"a","created":true}`},
+		{name: "nx on existing key", method: http.MethodPut, target: "/api/v2/keys/a", header: map[string]string{"If-None-Match": "*"}, body: `2`, status: http.StatusPreconditionFailed},
+		{name: "replace", method: http.MethodPut, target: "/api/v2/keys/a?xx&return=previous", header: map[string]string{"X-TTL": "1h"}, body: `"two"`, status: http.StatusOK},
+		{name: "get", method: http.MethodGet, target: "/api/v2/keys/a", status: http.StatusOK},
+		{name: "create with previous", method: http.MethodPut, target: "/api/v2/keys/b?return=previous&ttl=90", body: `true`, status: http.StatusCreated,
+			resp: `{"key":"b","created":true}`},
+		{name: "keep ttl", method: http.MethodPut, target: "/api/v2/keys/b?ttl=keep", body: `false`, status: http.StatusOK,
+			resp: `{"key":"b","created":false}`},
+		{name: "nx and xx", method: http.MethodPut, target: "/api/v2/keys/a?nx&xx", body: `1`, status: http.StatusBadRequest},
+		{name: "invalid ttl", method: http.MethodPut, target: "/api/v2/keys/a?ttl=-1s", body: `1`, status: http.StatusBadRequest},
+		{name: "null value", method: http.MethodPut, target: "/api/v2/keys/a", body: `null`, status: http.StatusBadRequest},
+		{name: "delete with previous", method: http.MethodDelete, target: "/api/v2/keys/a?return=previous", status: http.StatusOK,
+			resp: `{"key":"a","previous":{"value":"two"}}`},
+		{name: "delete", method: http.MethodDelete, target: "/api/v2/keys/b", status: http.StatusNoContent},
+		{name: "delete missing", method: http.MethodDelete, target: "/api/v2/keys/b", status: http.StatusNotFound},
+		{name: "v1 unchanged", method: http.MethodGet, target: "/api/lru", status: http.StatusNoContent},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, test.target, strings.NewReader(test.body))
+		for name, value := range test.header {
+			r.Header.Set(name, value)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		assert.Equal(t, test.status, w.Code, test.name)
+
+		if test.resp != "" {
+			assert.JSONEq(t, test.resp, w.Body.String(), test.name)
+		}
+
+		switch test.name {
+		case "replace":
+			var response putResponseV2
+
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, map[string]interface{}{"n": float64(1)}, response.Previous.Value)
+
+			expiresAt, err := time.Parse(time.RFC3339, response.Previous.ExpiresAt)
+			assert.NoError(t, err)
+			assert.WithinDuration(t, time.Now().Add(time.Minute), expiresAt, 2*time.Second)
+		case "get":
+			var response getResponseV2
+
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, "two", response.Value)
+			assert.Regexp(t, `^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{3}Z$`, response.ExpiresAt)
+
+			expiresAt, err := time.Parse(time.RFC3339, response.ExpiresAt)
+			assert.NoError(t, err)
+			assert.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, 2*time.Second)
+		}
+	}
+}
+
+func TestV2Blob(t *testing.T) {
+	c, err := cache.New(10, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	// A real server, http.ServeContent needs a writer implementing io.ReaderFrom
+	server := httptest.NewServer(New(c, slog.New(slog.NewTextHandler(io.Discard, nil))))
+	defer server.Close()
+
+	data := []byte{0x89, 'P', 'N', 'G', 0x00, 0xff, 0x10, 0x20}
+
+	tests := []struct {
+		name   string
+		method string
+		key    string
+		header map[string]string
+		body   []byte
+		status int
+		resp   []byte
+	}{
+		{name: "put", method: http.MethodPut, key: "img", header: map[string]string{"Content-Type": "image/png"}, body: data, status: http.StatusCreated},
+		{name: "get", method: http.MethodGet, key: "img", status: http.StatusOK, resp: data},
+		{name: "head", method: http.MethodHead, key: "img", status: http.StatusOK, resp: []byte{}},
+		{name: "range", method: http.MethodGet, key: "img", header: map[string]string{"Range": "bytes=4-5"}, status: http.StatusPartialContent, resp: data[4:6]},
+		{name: "unsatisfiable range", method: http.MethodGet, key: "img", header: map[string]string{"Range": "bytes=100-"}, status: http.StatusRequestedRangeNotSatisfiable},
+		{name: "put json", method: http.MethodPut, key: "doc", header: map[string]string{"Content-Type": "application/merge-patch+json"}, body: []byte(`{"n":1}`), status: http.StatusCreated},
+	}
+
+	for _, test := range tests {
+		r, err := http.NewRequest(test.method, server.URL+"/api/v2/keys/"+test.key, bytes.NewReader(test.body))
+		assert.NoError(t, err)
+
+		for name, value := range test.header {
+			r.Header.Set(name, value)
+		}
+
+		resp, err := http.DefaultClient.Do(r)
+		assert.NoError(t, err)
+
+		body, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, test.status, resp.StatusCode, test.name)
+
+		if test.resp != nil {
+			assert.Equal(t, test.resp, body, test.name)
+			assert.Equal(t, "image/png", resp.Header.Get("Content-Type"), test.name)
+			assert.NotEmpty(t, resp.Header.Get("X-Expires-At"), test.name)
+		}
+	}
+
+	value, _, err := c.Get(context.Background(), "img")
+	assert.NoError(t, err)
+	assert.Equal(t, cache.Blob{ContentType: "image/png", Data: data}, value)
+
+	// JSON content types are still decoded
+	value, _, err = c.Get(context.Background(), "doc")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"n": int64(1)}, value)
+}
+
+func TestV2BodySize(t *testing.T) {
+	c, err := cache.New(10, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	handler := New(c, slog.New(slog.NewTextHandler(io.Discard, nil)), WithMaxBodySize(16))
+
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		status   int
+	}{
+		{name: "small", body: []byte(`"value"`), status: http.StatusCreated},
+		{name: "too large", body: []byte(`"` + strings.Repeat("a", 32) + `"`), status: http.StatusRequestEntityTooLarge},
+		{name: "gzip bomb", encoding: "gzip", body: encode(t, "gzip", `"`+strings.Repeat("a", 1<<20)+`"`), status: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPut, "/api/v2/keys/"+strings.ReplaceAll(test.name, " ", "-"), bytes.NewReader(test.body))
+		r.Header.Set("Content-Encoding", test.encoding)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		assert.Equal(t, test.status, w.Code, test.name)
+
+		if test.status == http.StatusRequestEntityTooLarge {
+			assert.Contains(t, w.Body.String(), `"code":"body_too_large"`, test.name)
+		}
+	}
+}
+
+func TestParseTTL(t *testing.T) {
+	tests := []struct {
+		in  string
+		ttl time.Duration
+		err bool
+	}{
+		{in: "", ttl: 0},
+		{in: "90", ttl: 90 * time.Second},
+		{in: "1m30s", ttl: 90 * time.Second},
+		{in: "1500ms", ttl: 1500 * time.Millisecond},
+		{in: "keep", ttl: cache.KeepTTL},
+		{in: "-1s", err: true},
+		{in: "0s", err: true},
+		{in: "soon", err: true},
+	}
+
+	for _, test := range tests {
+		ttl, err := parseTTL(test.in)
+		if test.err {
+			assert.Error(t, err, test.in)
+
+			continue
+		}
+
+		assert.NoError(t, err, test.in)
+		assert.Equal(t, test.ttl, ttl, test.in)
+	}
+}
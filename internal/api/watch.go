@@ -0,0 +1,149 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/skantay/lru-api/internal/authz"
+	"github.com/skantay/lru-api/internal/cache"
+)
+
+// IWatcher is implemented by caches that publish change events.
+// If the cache passed to New implements it, the watch endpoint is enabled.
+type IWatcher interface {
+	// Subscribe подписка на изменения ключей, с повтором событий после lastEventID
+	Subscribe(filter cache.Filter, lastEventID uint64) *cache.Subscription
+}
+
+// How often a comment is sent to idle streams, so proxies do not close them
+const watchHeartbeat = 15 * time.Second
+
+type watchEvent struct {
+	Key       string      `json:"key,omitempty"`
+	Value     interface{} `json:"value"`
+	ExpiresAt int64       `json:"expires_at,omitempty"`
+	Time      int64       `json:"time"`
+}
+
+// watch streams cache change events as Server-Sent Events.
+// Events are filtered by the key or prefix query parameter and can be resumed with Last-Event-ID.
+// If the client is too slow to read events, the stream is closed and the client is expected
+// to reconnect with Last-Event-ID.
+func (a *api) watch(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter := cache.Filter{
+		Key:    query.Get("key"),
+		Prefix: query.Get("prefix"),
+	}
+
+	if filter.Key != "" && filter.Prefix != "" {
+		a.log.DebugContext(r.Context(), "bad request", "key", filter.Key, "prefix", filter.Prefix)
+
+		a.problem(w, r, errInvalidFilter, "")
+
+		return
+	}
+
+	if filter.Key != "" && !a.authorize(w, r, authz.OpGet, filter.Key) {
+		return
+	}
+
+	var lastEventID uint64
+
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		parsed, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			a.log.DebugContext(r.Context(), "bad request", "Last-Event-ID", id)
+
+			a.problem(w, r, errInvalidLastEventID, err.Error())
+
+			return
+		}
+
+		lastEventID = parsed
+	}
+
+	rc := http.NewResponseController(w)
+
+	// Server WriteTimeout is meant for regular requests, streams live as long as the client
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		a.log.DebugContext(r.Context(), "write deadline is not supported", "error", err.Error())
+	}
+
+	sub := a.watcher.Subscribe(filter, lastEventID)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	if err := rc.Flush(); err != nil {
+		a.log.ErrorContext(r.Context(), err.Error())
+
+		return
+	}
+
+	heartbeat := time.NewTicker(watchHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-sub.C:
+			if !ok {
+				a.log.WarnContext(r.Context(), "watch stream closed", "error", sub.Err())
+
+				return
+			}
+
+			// Events of keys the principal may not get are skipped, flush events have no key
+			if event.Key != "" && !a.permitted(r.Context(), authz.OpGet, event.Key) {
+				continue
+			}
+
+			if err := writeEvent(w, event); err != nil {
+				a.log.DebugContext(r.Context(), "watch stream write failed", "error", err.Error())
+
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func newWatchEvent(event cache.Event) watchEvent {
+	payload := watchEvent{
+		Key:   event.Key,
+		Value: event.Value,
+		Time:  event.Time.Unix(),
+	}
+
+	if !event.ExpiresAt.IsZero() {
+		payload.ExpiresAt = event.ExpiresAt.Unix()
+	}
+
+	return payload
+}
+
+func writeEvent(w http.ResponseWriter, event cache.Event) error {
+	data, err := json.Marshal(newWatchEvent(event))
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+
+	return err
+}
@@ -0,0 +1,474 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/skantay/lru-api/internal/audit"
+	"github.com/skantay/lru-api/internal/auth"
+	"github.com/skantay/lru-api/internal/authz"
+	"github.com/skantay/lru-api/internal/cache"
+	"github.com/skantay/lru-api/internal/logging"
+)
+
+const (
+	// Time allowed to write a message to the peer
+	wsWriteWait = 10 * time.Second
+	// Time allowed to read the next pong message from the peer
+	wsPongWait = 60 * time.Second
+	// Pings are sent with this period, must be less than wsPongWait
+	wsPingPeriod = wsPongWait * 9 / 10
+	// Maximum message size allowed from the peer
+	wsMaxMessageSize = 1 << 20
+	// Outgoing messages buffered per connection
+	wsSendBuffer = 64
+)
+
+// WebSocket operations
+const (
+	wsOpGet         = "get"
+	wsOpPut         = "put"
+	wsOpEvict       = "evict"
+	wsOpSubscribe   = "subscribe"
+	wsOpUnsubscribe = "unsubscribe"
+)
+
+// WebSocket message types sent by the server
+const (
+	wsTypeResponse = "response"
+	wsTypeEvent    = "event"
+)
+
+// Operations checked by the authorizer, subscribe is checked when it is limited to a key
+var wsOperations = map[string]authz.Operation{
+	wsOpGet:       authz.OpGet,
+	wsOpPut:       authz.OpPut,
+	wsOpEvict:     authz.OpEvict,
+	wsOpSubscribe: authz.OpGet,
+}
+
+// Operations recorded by the auditor
+var wsAuditActions = map[string]string{
+	wsOpPut:   audit.ActionCreate,
+	wsOpEvict: audit.ActionDelete,
+}
+
+var (
+	errWSSlowConsumer = errors.New("websocket client is too slow")
+	errWSForbidden    = errors.New("operation is not permitted")
+	errWSRateLimited  = errors.New("rate limited")
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+}
+
+// wsRequest is a command sent by the client.
+// ID is echoed in the response, so the client can match responses to requests.
+type wsRequest struct {
+	ID           string      `json:"id"`
+	Op           string      `json:"op"`
+	Key          string      `json:"key,omitempty"`
+	Prefix       string      `json:"prefix,omitempty"`
+	Value        interface{} `json:"value,omitempty"`
+	TTLSeconds   uint        `json:"ttl_seconds,omitempty"`
+	LastEventID  uint64      `json:"last_event_id,omitempty"`
+	Subscription string      `json:"subscription,omitempty"`
+}
+
+// wsMessage is a response to a command or a pushed change event
+type wsMessage struct {
+	Type         string      `json:"type"`
+	ID           string      `json:"id,omitempty"`
+	OK           bool        `json:"ok"`
+	Error        string      `json:"error,omitempty"`
+	Key          string      `json:"key,omitempty"`
+	Value        interface{} `json:"value,omitempty"`
+	ExpiresAt    int64       `json:"expires_at,omitempty"`
+	Subscription string      `json:"subscription,omitempty"`
+	Event        *wsEvent    `json:"event,omitempty"`
+}
+
+type wsEvent struct {
+	ID uint64 `json:"id"`
+
+	Type cache.EventType `json:"type"`
+
+	watchEvent
+}
+
+// wsConn is a single WebSocket client.
+// All writes go through send and are performed by writeLoop, reads are done by readLoop.
+type wsConn struct {
+	api      *api
+	conn     *websocket.Conn
+	clientIP string
+	// client is charged for rate limits and quotas
+	client string
+	send   chan wsMessage
+	done   chan struct{}
+	once   sync.Once
+
+	m             sync.Mutex
+	subscriptions map[string]*cache.Subscription
+	nextSub       uint64
+}
+
+// ws upgrades the connection to WebSocket and serves get, put, evict and subscribe commands over it
+func (a *api) ws(w http.ResponseWriter, r *http.Request) {
+	upgrader := upgrader
+	upgrader.Error = func(w http.ResponseWriter, r *http.Request, status int, reason error) {
+		a.problem(w, r, &apiError{status, "websocket_upgrade_failed", "WebSocket upgrade failed"}, reason.Error())
+	}
+
+	// Tracked before the connection is hijacked, so Shutdown cannot miss it
+	a.conns.Add(1)
+	defer a.conns.Done()
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied with an error
+		a.log.DebugContext(r.Context(), "websocket upgrade failed", "error", err.Error())
+
+		return
+	}
+
+	c := &wsConn{
+		api:           a,
+		conn:          conn,
+		clientIP:      clientIP(r),
+		client:        principalOrIP(r),
+		send:          make(chan wsMessage, wsSendBuffer),
+		done:          make(chan struct{}),
+		subscriptions: make(map[string]*cache.Subscription),
+	}
+	defer c.unsubscribeAll()
+
+	go c.readLoop(r.Context())
+
+	c.writeLoop(r.Context())
+}
+
+func (c *wsConn) close() {
+	c.once.Do(func() {
+		close(c.done)
+	})
+}
+
+func (c *wsConn) readLoop(ctx context.Context) {
+	defer c.close()
+
+	c.conn.SetReadLimit(wsMaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		var request wsRequest
+
+		if err := c.conn.ReadJSON(&request); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				c.api.log.DebugContext(ctx, "websocket read failed", "error", err.Error())
+			}
+
+			return
+		}
+
+		response := c.handle(ctx, request)
+		c.audit(ctx, request, response)
+
+		if !c.push(ctx, response) {
+			return
+		}
+	}
+}
+
+func (c *wsConn) writeLoop(ctx context.Context) {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case message := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+
+			if err := c.conn.WriteJSON(message); err != nil {
+				c.api.log.DebugContext(ctx, "websocket write failed", "error", err.Error())
+
+				return
+			}
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				return
+			}
+		case <-c.done:
+			c.writeClose(ctx, websocket.CloseNormalClosure, "")
+
+			return
+		case <-ctx.Done():
+			c.writeClose(ctx, websocket.CloseGoingAway, "server is shutting down")
+
+			return
+		case <-c.api.closing:
+			c.writeClose(ctx, websocket.CloseGoingAway, "server is shutting down")
+
+			return
+		}
+	}
+}
+
+func (c *wsConn) writeClose(ctx context.Context, code int, text string) {
+	message := websocket.FormatCloseMessage(code, text)
+
+	if err := c.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(wsWriteWait)); err != nil {
+		c.api.log.DebugContext(ctx, "websocket close failed", "error", err.Error())
+	}
+}
+
+// push queues a message, it returns false if the connection is closed or too slow
+func (c *wsConn) push(ctx context.Context, message wsMessage) bool {
+	select {
+	case c.send <- message:
+		return true
+	case <-c.done:
+		return false
+	default:
+		c.api.log.WarnContext(ctx, errWSSlowConsumer.Error())
+		c.close()
+
+		return false
+	}
+}
+
+func (c *wsConn) handle(ctx context.Context, request wsRequest) wsMessage {
+	response := wsMessage{
+		Type: wsTypeResponse,
+		ID:   request.ID,
+		Key:  request.Key,
+	}
+
+	if retryAfter, ok := c.allow(request.Op); !ok {
+		c.api.log.InfoContext(ctx, "rate limited", "client", c.client, "op", request.Op)
+		response.Error = errWSRateLimited.Error() + ", retry in " + strconv.Itoa(retryAfter) + "s"
+
+		return response
+	}
+
+	if op, ok := wsOperations[request.Op]; ok && request.Key != "" && !c.api.permitted(ctx, op, request.Key) {
+		c.api.logDenied(ctx, op, request.Key)
+		response.Error = errWSForbidden.Error()
+
+		return response
+	}
+
+	switch request.Op {
+	case wsOpGet:
+		value, expiresAt, err := c.api.cache.Get(ctx, request.Key)
+		if err != nil {
+			response.Error = err.Error()
+
+			return response
+		}
+
+		response.Value = value
+		response.ExpiresAt = expiresAt.Unix()
+	case wsOpPut:
+		if request.Key == "" || request.Value == nil {
+			response.Error = "key and value are required"
+
+			return response
+		}
+
+		rollback := func() {}
+
+		if c.api.quota != nil {
+			var err error
+
+			if rollback, err = c.api.quota.Reserve(c.client, request.Key, cache.SizeOf(request.Key, request.Value)); err != nil {
+				c.api.log.InfoContext(ctx, "quota exceeded", "client", c.client, "key", request.Key, "error", err.Error())
+				response.Error = err.Error()
+
+				return response
+			}
+		}
+
+		if err := c.api.cache.Put(ctx, request.Key, request.Value, time.Duration(request.TTLSeconds)*time.Second); err != nil {
+			rollback()
+			response.Error = err.Error()
+
+			return response
+		}
+	case wsOpEvict:
+		value, err := c.api.cache.Evict(ctx, request.Key)
+		if err != nil {
+			response.Error = err.Error()
+
+			return response
+		}
+
+		response.Value = value
+	case wsOpSubscribe:
+		if c.api.watcher == nil {
+			response.Error = "subscriptions are not supported"
+
+			return response
+		}
+
+		if request.Key != "" && request.Prefix != "" {
+			response.Error = "only one of key and prefix is allowed"
+
+			return response
+		}
+
+		response.Subscription = c.subscribe(ctx, cache.Filter{Key: request.Key, Prefix: request.Prefix}, request.LastEventID)
+	case wsOpUnsubscribe:
+		if !c.unsubscribe(request.Subscription) {
+			response.Error = "subscription does not exist"
+
+			return response
+		}
+
+		response.Subscription = request.Subscription
+	default:
+		response.Error = "unknown operation"
+
+		return response
+	}
+
+	response.OK = true
+
+	return response
+}
+
+// allow charges the rate limit of the client for a command, as if it was a request of its own.
+// Commands changing the cache are writes, the others are reads. If it is not allowed, the seconds to wait are returned.
+func (c *wsConn) allow(op string) (int, bool) {
+	limiter := c.api.readLimiter
+	if op == wsOpPut || op == wsOpEvict {
+		limiter = c.api.writeLimiter
+	}
+
+	if limiter == nil {
+		return 0, true
+	}
+
+	result := limiter.Allow(c.client)
+	if result.Allowed {
+		return 0, true
+	}
+
+	return max(seconds(result.RetryAfter), 1), false
+}
+
+// audit records commands changing the cache, every command is recorded as the connection is a single request
+func (c *wsConn) audit(ctx context.Context, request wsRequest, response wsMessage) {
+	action, ok := wsAuditActions[request.Op]
+	if !ok || c.api.auditor == nil {
+		return
+	}
+
+	record := audit.Record{
+		Action:    action,
+		ClientIP:  c.clientIP,
+		RequestID: logging.RequestID(ctx),
+		Key:       request.Key,
+		Outcome:   audit.OutcomeSuccess,
+		Detail:    "websocket",
+	}
+
+	if principal, ok := auth.FromContext(ctx); ok {
+		record.Principal = principal.Subject
+	}
+
+	switch {
+	case response.Error == errWSForbidden.Error():
+		record.Outcome, record.Detail = audit.OutcomeDenied, "websocket: "+response.Error
+	case !response.OK:
+		record.Outcome, record.Detail = audit.OutcomeFailure, "websocket: "+response.Error
+	}
+
+	c.api.appendAudit(ctx, record)
+}
+
+func (c *wsConn) subscribe(ctx context.Context, filter cache.Filter, lastEventID uint64) string {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.nextSub++
+	id := strconv.FormatUint(c.nextSub, 10)
+
+	sub := c.api.watcher.Subscribe(filter, lastEventID)
+	c.subscriptions[id] = sub
+
+	go c.forward(ctx, id, sub)
+
+	return id
+}
+
+// forward pushes events of a subscription to the client until the subscription is closed.
+// Events of keys the principal may not get are skipped.
+func (c *wsConn) forward(ctx context.Context, id string, sub *cache.Subscription) {
+	for event := range sub.C {
+		if event.Key != "" && !c.api.permitted(ctx, authz.OpGet, event.Key) {
+			continue
+		}
+
+		message := wsMessage{
+			Type:         wsTypeEvent,
+			OK:           true,
+			Subscription: id,
+			Event: &wsEvent{
+				ID:         event.ID,
+				Type:       event.Type,
+				watchEvent: newWatchEvent(event),
+			},
+		}
+
+		if !c.push(ctx, message) {
+			return
+		}
+	}
+
+	if err := sub.Err(); err != nil {
+		c.push(ctx, wsMessage{
+			Type:         wsTypeEvent,
+			Subscription: id,
+			Error:        err.Error(),
+		})
+	}
+}
+
+func (c *wsConn) unsubscribe(id string) bool {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	sub, ok := c.subscriptions[id]
+	if !ok {
+		return false
+	}
+
+	sub.Close()
+	delete(c.subscriptions, id)
+
+	return true
+}
+
+func (c *wsConn) unsubscribeAll() {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	for id, sub := range c.subscriptions {
+		sub.Close()
+		delete(c.subscriptions, id)
+	}
+}
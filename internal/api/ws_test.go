@@ -0,0 +1,62 @@
+package api
+
+import (
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/skantay/lru-api/internal/cache"
+	"github.com/skantay/lru-api/internal/cache/mocks"
+	"github.com/skantay/lru-api/internal/quota"
+	"github.com/skantay/lru-api/internal/ratelimit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWSLimits(t *testing.T) {
+	c, err := cache.New(10, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	tracker := quota.New(quota.Config{Default: quota.Limits{Entries: 1}})
+
+	server := httptest.NewServer(New(c, slog.New(slog.NewTextHandler(io.Discard, nil)),
+		WithQuota(tracker),
+		WithRateLimits(nil, ratelimit.New(ratelimit.Limit{Rate: 0.01, Burst: 2})),
+	))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/ws", nil)
+	assert.NoError(t, err)
+
+	defer conn.Close()
+
+	tests := []struct {
+		name    string
+		request wsRequest
+		err     string
+	}{
+		{name: "put", request: wsRequest{ID: "1", Op: wsOpPut, Key: "a", Value: "value"}},
+		{name: "quota exceeded", request: wsRequest{ID: "2", Op: wsOpPut, Key: "b", Value: "value"}, err: "quota exceeded"},
+		{name: "rate limited", request: wsRequest{ID: "3", Op: wsOpPut, Key: "a", Value: "value"}, err: "rate limited"},
+		{name: "reads are not limited", request: wsRequest{ID: "4", Op: wsOpGet, Key: "a"}},
+	}
+
+	for _, test := range tests {
+		assert.NoError(t, conn.WriteJSON(test.request), test.name)
+
+		var response wsMessage
+
+		assert.NoError(t, conn.ReadJSON(&response), test.name)
+		assert.Equal(t, test.request.ID, response.ID, test.name)
+		assert.Equal(t, test.err == "", response.OK, test.name)
+		assert.Contains(t, response.Error, test.err, test.name)
+	}
+
+	assert.Equal(t, quota.Usage{Entries: 1, Bytes: cache.SizeOf("a", "value")}, tracker.Usage("127.0.0.1"))
+
+	_, _, err = c.Get(t.Context(), "b")
+	assert.ErrorIs(t, err, cache.ErrKeyDoesNotExist)
+}
@@ -0,0 +1,68 @@
+package audit
+
+import (
+	"context"
+	"net"
+
+	"github.com/skantay/lru-api/internal/cache"
+)
+
+// Actor is a client of a listener without authentication, such as the RESP or memcached listener.
+// Its writes are recorded through the cache by RecordWrite, as the listeners have no principal to record.
+type Actor struct {
+	// Listener is grpc, resp, memcache or proxy
+	Listener string
+	ClientIP string
+}
+
+type actorKey struct{}
+
+// WithActor returns a context carrying the actor of the listener connected to remoteAddr (host:port)
+func WithActor(ctx context.Context, listener, remoteAddr string) context.Context {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+
+	return context.WithValue(ctx, actorKey{}, Actor{Listener: listener, ClientIP: host})
+}
+
+// ActorFromContext returns the actor of ctx, ok is false if there is none
+func ActorFromContext(ctx context.Context) (actor Actor, ok bool) {
+	actor, ok = ctx.Value(actorKey{}).(Actor)
+
+	return actor, ok
+}
+
+// cacheActions of the writes reported by the cache
+var cacheActions = map[cache.EventType]string{
+	cache.EventPut:   ActionCreate,
+	cache.EventEvict: ActionDelete,
+	cache.EventFlush: ActionFlush,
+}
+
+// RecordWrite records a write made to the cache by the actor of ctx, it implements cache.Recorder.
+// Writes without an actor are recorded by their callers, the HTTP API records its principals.
+func (l *Log) RecordWrite(ctx context.Context, typ cache.EventType, key string, err error) error {
+	actor, ok := ActorFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	record := Record{
+		Action:   cacheActions[typ],
+		Listener: actor.Listener,
+		ClientIP: actor.ClientIP,
+		Key:      key,
+		Outcome:  OutcomeSuccess,
+	}
+
+	if err != nil {
+		record.Outcome = OutcomeFailure
+		record.Detail = err.Error()
+	}
+
+	_, err = l.Append(record)
+
+	return err
+}
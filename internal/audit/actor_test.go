@@ -0,0 +1,66 @@
+package audit
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/skantay/lru-api/internal/cache"
+	"github.com/skantay/lru-api/internal/cache/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordWrite(t *testing.T) {
+	auditLog, err := Open(Config{Path: filepath.Join(t.TempDir(), "audit.log")})
+	assert.NoError(t, err)
+
+	defer auditLog.Close()
+
+	c, err := cache.New(10, time.Minute, &mocks.Logger{}, cache.WithRecorder(auditLog))
+	assert.NoError(t, err)
+
+	// Writes without an actor are recorded by their callers
+	assert.NoError(t, c.Put(context.Background(), "api", 1, 0))
+
+	ctx := WithActor(context.Background(), "resp", "192.0.2.1:5000")
+
+	assert.NoError(t, c.Put(ctx, "a", 1, 0))
+
+	// Reads through Update are not recorded
+	assert.NoError(t, c.Update(ctx, "a", func(value interface{}, _ time.Time, _ bool) (interface{}, time.Duration, bool) {
+		return value, 0, false
+	}))
+	assert.NoError(t, c.Update(ctx, "a", func(_ interface{}, _ time.Time, _ bool) (interface{}, time.Duration, bool) {
+		return 2, cache.KeepTTL, true
+	}))
+
+	_, err = c.Evict(ctx, "a")
+	assert.NoError(t, err)
+	_, err = c.Evict(ctx, "a")
+	assert.ErrorIs(t, err, cache.ErrKeyDoesNotExist)
+
+	assert.NoError(t, c.EvictAll(ctx))
+
+	records, err := auditLog.Query(Filter{})
+	assert.NoError(t, err)
+
+	expected := []Record{
+		{Action: ActionCreate, Key: "a", Outcome: OutcomeSuccess},
+		{Action: ActionCreate, Key: "a", Outcome: OutcomeSuccess},
+		{Action: ActionDelete, Key: "a", Outcome: OutcomeSuccess},
+		{Action: ActionDelete, Key: "a", Outcome: OutcomeFailure, Detail: cache.ErrKeyDoesNotExist.Error()},
+		{Action: ActionFlush, Outcome: OutcomeSuccess},
+	}
+
+	if !assert.Len(t, records, len(expected)) {
+		return
+	}
+
+	for i, record := range records {
+		expected[i].Listener, expected[i].ClientIP = "resp", "192.0.2.1"
+		expected[i].Seq, expected[i].Time, expected[i].PrevHash, expected[i].Hash = record.Seq, record.Time, record.PrevHash, record.Hash
+
+		assert.Equal(t, expected[i], record, i)
+	}
+}
@@ -0,0 +1,455 @@
+// Package audit keeps a tamper evident log of operations changing the cache.
+// Records are appended to a JSON lines file that is rotated by size. Every record carries the SHA-256
+// of the record before it, so records cannot be altered, removed or reordered without breaking the chain.
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Actions recorded by the API and the listeners
+const (
+	ActionCreate      = "create"
+	ActionDelete      = "delete"
+	ActionFlush       = "flush"
+	ActionImport      = "import"
+	ActionAuditQuery  = "audit_query"
+	ActionAuditVerify = "audit_verify"
+)
+
+// Outcomes of recorded operations
+const (
+	OutcomeSuccess = "success"
+	OutcomeDenied  = "denied"
+	OutcomeFailure = "failure"
+)
+
+// maxLine bounds a record read back from the file, keys are limited by the API long before that
+const maxLine = 1 << 20
+
+// Record of an operation.
+// Seq, Time, PrevHash and Hash are set by Append.
+type Record struct {
+	Seq       uint64    `json:"seq"`
+	Time      time.Time `json:"time"`
+	Action    string    `json:"action"`
+	Principal string    `json:"principal,omitempty"`
+	Listener  string    `json:"listener,omitempty"`
+	ClientIP  string    `json:"client_ip,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+	Key       string    `json:"key,omitempty"`
+	Outcome   string    `json:"outcome"`
+	Status    int       `json:"status,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+	PrevHash  string    `json:"prev_hash"`
+	Hash      string    `json:"hash,omitempty"`
+}
+
+// hash returns the hex encoded SHA-256 of the record without its hash, prev_hash links it to the previous one
+func (r Record) hash() string {
+	r.Hash = ""
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		// Records consist of strings, numbers and a time, they always marshal
+		panic(err)
+	}
+
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// Config of the log
+type Config struct {
+	// Path of the current file, rotated files are Path.1 (the newest), Path.2, ...
+	Path string
+	// MaxSize in bytes the current file grows to before it is rotated, 0 disables rotation
+	MaxSize int64
+	// MaxFiles rotated files are kept, older ones are removed, 0 keeps all
+	MaxFiles int
+}
+
+// Log appends records to the files of Config.
+// It is safe for concurrent use.
+type Log struct {
+	config Config
+
+	m    sync.Mutex
+	file *os.File
+	size int64
+	seq  uint64
+	last string
+
+	now func() time.Time
+}
+
+// Open opens the log and continues the chain of the records it holds
+func Open(config Config) (*Log, error) {
+	if config.Path == "" {
+		return nil, errors.New("audit log path is empty")
+	}
+
+	l := &Log{
+		config: config,
+		now:    time.Now,
+	}
+
+	paths, err := l.paths()
+	if err != nil {
+		return nil, err
+	}
+
+	// The newest file with records holds the end of the chain
+	for i := len(paths) - 1; i >= 0; i-- {
+		last, ok, err := lastRecord(paths[i])
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			l.seq, l.last = last.Seq, last.Hash
+
+			break
+		}
+	}
+
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+func (l *Log) open() error {
+	file, err := os.OpenFile(l.config.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("open audit log: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+
+		return fmt.Errorf("open audit log: %w", err)
+	}
+
+	l.file, l.size = file, info.Size()
+
+	return nil
+}
+
+// Append stamps the record, chains it to the previous one and writes it
+func (l *Log) Append(record Record) (Record, error) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	record.Seq = l.seq + 1
+	record.Time = l.now().UTC()
+	record.PrevHash = l.last
+	record.Hash = record.hash()
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return Record{}, fmt.Errorf("marshal audit record: %w", err)
+	}
+
+	data = append(data, '\n')
+
+	if l.config.MaxSize > 0 && l.size > 0 && l.size+int64(len(data)) > l.config.MaxSize {
+		if err := l.rotate(); err != nil {
+			return Record{}, err
+		}
+	}
+
+	n, err := l.file.Write(data)
+	l.size += int64(n)
+
+	if err != nil {
+		return Record{}, fmt.Errorf("write audit record: %w", err)
+	}
+
+	l.seq, l.last = record.Seq, record.Hash
+
+	return record, nil
+}
+
+// rotate renames the current file to Path.1, shifting older ones, and opens a new one.
+// It must be called with l.m held.
+func (l *Log) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return fmt.Errorf("rotate audit log: %w", err)
+	}
+
+	rotated, err := l.rotated()
+	if err != nil {
+		return err
+	}
+
+	// From the oldest, so no file is overwritten
+	for i := len(rotated) - 1; i >= 0; i-- {
+		n := rotated[i]
+
+		if l.config.MaxFiles > 0 && n >= l.config.MaxFiles {
+			if err := os.Remove(l.rotatedPath(n)); err != nil {
+				return fmt.Errorf("rotate audit log: %w", err)
+			}
+
+			continue
+		}
+
+		if err := os.Rename(l.rotatedPath(n), l.rotatedPath(n+1)); err != nil {
+			return fmt.Errorf("rotate audit log: %w", err)
+		}
+	}
+
+	if err := os.Rename(l.config.Path, l.rotatedPath(1)); err != nil {
+		return fmt.Errorf("rotate audit log: %w", err)
+	}
+
+	return l.open()
+}
+
+func (l *Log) rotatedPath(n int) string {
+	return l.config.Path + "." + strconv.Itoa(n)
+}
+
+// rotated returns the numbers of the rotated files, from the newest
+func (l *Log) rotated() ([]int, error) {
+	matches, err := filepath.Glob(l.config.Path + ".*")
+	if err != nil {
+		return nil, fmt.Errorf("list audit log files: %w", err)
+	}
+
+	var numbers []int
+
+	for _, match := range matches {
+		n, err := strconv.Atoi(strings.TrimPrefix(match, l.config.Path+"."))
+		if err == nil && n > 0 {
+			numbers = append(numbers, n)
+		}
+	}
+
+	slices.Sort(numbers)
+
+	return numbers, nil
+}
+
+// paths returns the files of the log from the oldest to the current one
+func (l *Log) paths() ([]string, error) {
+	rotated, err := l.rotated()
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(rotated)+1)
+
+	for i := len(rotated) - 1; i >= 0; i-- {
+		paths = append(paths, l.rotatedPath(rotated[i]))
+	}
+
+	return append(paths, l.config.Path), nil
+}
+
+// Close closes the current file
+func (l *Log) Close() error {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if err := l.file.Sync(); err != nil {
+		l.file.Close()
+
+		return err
+	}
+
+	return l.file.Close()
+}
+
+// Filter selects records, zero fields match every record
+type Filter struct {
+	Key       string
+	Action    string
+	Principal string
+	Listener  string
+	// Since and Until bound the time of records, both inclusive
+	Since time.Time
+	Until time.Time
+	// After skips records up to this sequence number, for paging
+	After uint64
+	// Limit on the number of records returned, 0 is unlimited
+	Limit int
+}
+
+func (f Filter) match(r Record) bool {
+	switch {
+	case f.Key != "" && r.Key != f.Key,
+		f.Action != "" && r.Action != f.Action,
+		f.Principal != "" && r.Principal != f.Principal,
+		f.Listener != "" && r.Listener != f.Listener,
+		!f.Since.IsZero() && r.Time.Before(f.Since),
+		!f.Until.IsZero() && r.Time.After(f.Until),
+		r.Seq <= f.After:
+		return false
+	}
+
+	return true
+}
+
+// Query returns the records matching the filter, from the oldest
+func (l *Log) Query(filter Filter) ([]Record, error) {
+	records := []Record{}
+
+	errLimit := errors.New("limit reached")
+
+	err := l.read(func(record Record) error {
+		if !filter.match(record) {
+			return nil
+		}
+
+		records = append(records, record)
+
+		if filter.Limit > 0 && len(records) == filter.Limit {
+			return errLimit
+		}
+
+		return nil
+	})
+	if err != nil && !errors.Is(err, errLimit) {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// Verify checks the chain of all records kept and returns their number.
+// The first record kept may follow removed ones, its prev_hash cannot be checked.
+func (l *Log) Verify() (int, error) {
+	var (
+		count int
+		prev  Record
+	)
+
+	err := l.read(func(record Record) error {
+		if record.hash() != record.Hash {
+			return fmt.Errorf("record %d does not match its hash", record.Seq)
+		}
+
+		if count > 0 && (record.Seq != prev.Seq+1 || record.PrevHash != prev.Hash) {
+			return fmt.Errorf("record %d does not follow record %d", record.Seq, prev.Seq)
+		}
+
+		count++
+		prev = record
+
+		return nil
+	})
+
+	return count, err
+}
+
+// read calls fn with every record from the oldest.
+// The files are opened with the log locked, records appended later are not read.
+func (l *Log) read(fn func(Record) error) error {
+	l.m.Lock()
+
+	paths, err := l.paths()
+	if err != nil {
+		l.m.Unlock()
+
+		return err
+	}
+
+	files := make([]*os.File, 0, len(paths))
+
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			l.m.Unlock()
+
+			return fmt.Errorf("open audit log: %w", err)
+		}
+
+		files = append(files, file)
+	}
+
+	// Records appended to the current file after this are left out
+	size := l.size
+
+	l.m.Unlock()
+
+	for i, file := range files {
+		var r io.Reader = file
+		if i == len(files)-1 {
+			r = io.LimitReader(file, size)
+		}
+
+		if err := readRecords(r, fn); err != nil {
+			return fmt.Errorf("%s: %w", filepath.Base(paths[i]), err)
+		}
+	}
+
+	return nil
+}
+
+// readRecords calls fn with every record of r
+func readRecords(r io.Reader, fn func(Record) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxLine)
+
+	for line := 1; scanner.Scan(); line++ {
+		var record Record
+
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// lastRecord returns the last record of the file, ok is false if it has none
+func lastRecord(path string) (last Record, ok bool, err error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Record{}, false, nil
+	}
+
+	if err != nil {
+		return Record{}, false, fmt.Errorf("open audit log: %w", err)
+	}
+	defer file.Close()
+
+	err = readRecords(file, func(record Record) error {
+		last, ok = record, true
+
+		return nil
+	})
+	if err != nil {
+		return Record{}, false, fmt.Errorf("read audit log %s: %w", filepath.Base(path), err)
+	}
+
+	return last, ok, nil
+}
@@ -0,0 +1,176 @@
+package audit
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	l, err := Open(Config{Path: path})
+	assert.NoError(t, err)
+
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	l.now = func() time.Time {
+		now = now.Add(time.Minute)
+
+		return now
+	}
+
+	first, err := l.Append(Record{Action: ActionCreate, Principal: "alice", Key: "a", Outcome: OutcomeSuccess})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), first.Seq)
+	assert.Empty(t, first.PrevHash)
+	assert.Len(t, first.Hash, 64)
+
+	second, err := l.Append(Record{Action: ActionFlush, Principal: "bob", Outcome: OutcomeDenied})
+	assert.NoError(t, err)
+	assert.Equal(t, first.Hash, second.PrevHash)
+
+	assert.NoError(t, l.Close())
+
+	// The chain continues after the log is reopened
+	l, err = Open(Config{Path: path})
+	assert.NoError(t, err)
+
+	l.now = func() time.Time {
+		now = now.Add(time.Minute)
+
+		return now
+	}
+
+	third, err := l.Append(Record{Action: ActionDelete, Principal: "alice", Key: "a", Outcome: OutcomeSuccess})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), third.Seq)
+	assert.Equal(t, second.Hash, third.PrevHash)
+
+	count, err := l.Verify()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	tests := []struct {
+		name   string
+		filter Filter
+		seqs   []uint64
+	}{
+		{name: "all", seqs: []uint64{1, 2, 3}},
+		{name: "key", filter: Filter{Key: "a"}, seqs: []uint64{1, 3}},
+		{name: "action", filter: Filter{Action: ActionFlush}, seqs: []uint64{2}},
+		{name: "principal", filter: Filter{Principal: "alice"}, seqs: []uint64{1, 3}},
+		{name: "since", filter: Filter{Since: second.Time}, seqs: []uint64{2, 3}},
+		{name: "until", filter: Filter{Until: second.Time}, seqs: []uint64{1, 2}},
+		{name: "after", filter: Filter{After: 1, Limit: 1}, seqs: []uint64{2}},
+		{name: "none", filter: Filter{Key: "b"}, seqs: []uint64{}},
+	}
+
+	for _, test := range tests {
+		records, err := l.Query(test.filter)
+		assert.NoError(t, err, test.name)
+
+		seqs := []uint64{}
+		for _, record := range records {
+			seqs = append(seqs, record.Seq)
+		}
+
+		assert.Equal(t, test.seqs, seqs, test.name)
+	}
+
+	assert.NoError(t, l.Close())
+}
+
+func TestRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	// About two records per file
+	l, err := Open(Config{Path: path, MaxSize: 600, MaxFiles: 2})
+	assert.NoError(t, err)
+
+	for range 10 {
+		_, err := l.Append(Record{Action: ActionCreate, Key: "key", Outcome: OutcomeSuccess})
+		assert.NoError(t, err)
+	}
+
+	for _, name := range []string{"audit.log", "audit.log.1", "audit.log.2"} {
+		info, err := os.Stat(filepath.Join(filepath.Dir(path), name))
+		assert.NoError(t, err, name)
+		assert.LessOrEqual(t, info.Size(), int64(600), name)
+	}
+
+	_, err = os.Stat(path + ".3")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// Removed files do not break the chain of the kept ones
+	records, err := l.Query(Filter{})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10), records[len(records)-1].Seq)
+	assert.Greater(t, records[0].Seq, uint64(1))
+
+	count, err := l.Verify()
+	assert.NoError(t, err)
+	assert.Equal(t, len(records), count)
+
+	assert.NoError(t, l.Close())
+
+	// The chain continues from the rotated file if the current one is empty
+	assert.NoError(t, os.Rename(path+".1", path+".3"))
+	assert.NoError(t, os.Rename(path, path+".1"))
+
+	l, err = Open(Config{Path: path, MaxSize: 600, MaxFiles: 2})
+	assert.NoError(t, err)
+
+	record, err := l.Append(Record{Action: ActionCreate, Key: "key", Outcome: OutcomeSuccess})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(11), record.Seq)
+	assert.Equal(t, records[len(records)-1].Hash, record.PrevHash)
+
+	assert.NoError(t, l.Close())
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(data []byte) []byte
+	}{
+		{name: "altered", tamper: func(data []byte) []byte {
+			return bytes.Replace(data, []byte(`"key":"b"`), []byte(`"key":"c"`), 1)
+		}},
+		{name: "removed", tamper: func(data []byte) []byte {
+			lines := bytes.SplitAfter(data, []byte("\n"))
+
+			return bytes.Join(append(lines[:1], lines[2:]...), nil)
+		}},
+		{name: "reordered", tamper: func(data []byte) []byte {
+			lines := bytes.SplitAfter(data, []byte("\n"))
+			lines[1], lines[2] = lines[2], lines[1]
+
+			return bytes.Join(lines, nil)
+		}},
+	}
+
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), "audit.log")
+
+		l, err := Open(Config{Path: path})
+		assert.NoError(t, err, test.name)
+
+		for _, key := range []string{"a", "b", "c"} {
+			_, err := l.Append(Record{Action: ActionDelete, Key: key, Outcome: OutcomeSuccess})
+			assert.NoError(t, err, test.name)
+		}
+
+		data, err := os.ReadFile(path)
+		assert.NoError(t, err, test.name)
+		assert.NoError(t, os.WriteFile(path, test.tamper(data), 0o600), test.name)
+
+		_, err = l.Verify()
+		assert.Error(t, err, test.name)
+
+		assert.NoError(t, l.Close(), test.name)
+	}
+}
@@ -0,0 +1,261 @@
+// Package auth authenticates requests with static API keys or JWT bearer tokens.
+package auth
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Methods a principal is authenticated with
+const (
+	MethodAPIKey     = "api_key"
+	MethodJWT        = "jwt"
+	MethodClientCert = "client_cert"
+)
+
+// Allowed clock skew when validating exp, nbf and iat of tokens
+const leeway = 30 * time.Second
+
+var (
+	// ErrMissingCredentials is returned when the request carries neither an API key nor a bearer token
+	ErrMissingCredentials = errors.New("missing credentials")
+
+	// ErrInvalidAPIKey is returned for an unknown API key
+	ErrInvalidAPIKey = errors.New("invalid API key")
+
+	// ErrInvalidToken is returned for a bearer token that fails validation
+	ErrInvalidToken = errors.New("invalid token")
+
+	// ErrInvalidClientCert is returned for a client certificate without a name
+	ErrInvalidClientCert = errors.New("client certificate has neither a common name nor a URI SAN")
+)
+
+// Principal is an authenticated caller
+type Principal struct {
+	// Subject is the name of the API key, the sub claim of the token or the name of the client certificate
+	Subject string
+	// Method is MethodAPIKey, MethodJWT or MethodClientCert
+	Method string
+	// Claims of the token, nil for API keys
+	Claims jwt.MapClaims
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the principal
+func NewContext(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, principal)
+}
+
+// FromContext returns the principal of the request, ok is false for unauthenticated requests
+func FromContext(ctx context.Context) (principal Principal, ok bool) {
+	principal, ok = ctx.Value(contextKey{}).(Principal)
+
+	return principal, ok
+}
+
+// Config of the authenticator, authentication is disabled if nothing is configured
+type Config struct {
+	// APIKeys are name:sha256 pairs, sha256 is the hex encoded SHA-256 of the key
+	APIKeys []string
+	// APIKeysFile contains a name:sha256 pair per line, empty lines and lines starting with # are ignored
+	APIKeysFile string
+
+	// HS256KeyFile contains the shared secret of HS256 tokens
+	HS256KeyFile string
+	// RS256KeyFile contains the PEM encoded RSA public key of RS256 tokens
+	RS256KeyFile string
+	// JWKSFile contains a JSON Web Key Set, keys are selected by the kid header of tokens
+	JWKSFile string
+
+	// Issuer and Audience are checked if set
+	Issuer   string
+	Audience string
+
+	// ClientCerts accepts client certificates verified by the TLS server of requests without other credentials
+	ClientCerts bool
+}
+
+// Authenticator validates credentials of requests
+type Authenticator struct {
+	// SHA-256 of API keys to their names
+	apiKeys map[[sha256.Size]byte]string
+
+	keys   *keySet
+	parser *jwt.Parser
+
+	clientCerts bool
+}
+
+// New loads keys configured in config.
+// It returns nil if neither API keys, token keys nor client certificates are configured.
+func New(config Config) (*Authenticator, error) {
+	a := &Authenticator{
+		apiKeys:     make(map[[sha256.Size]byte]string),
+		keys:        &keySet{},
+		clientCerts: config.ClientCerts,
+	}
+
+	entries := config.APIKeys
+
+	if config.APIKeysFile != "" {
+		fileEntries, err := readAPIKeysFile(config.APIKeysFile)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, fileEntries...)
+	}
+
+	for _, entry := range entries {
+		name, hash, err := parseAPIKey(entry)
+		if err != nil {
+			return nil, err
+		}
+
+		a.apiKeys[hash] = name
+	}
+
+	if err := a.keys.load(config); err != nil {
+		return nil, err
+	}
+
+	if len(a.apiKeys) == 0 && a.keys.empty() && !a.clientCerts {
+		return nil, nil
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(a.keys.methods()),
+		jwt.WithLeeway(leeway),
+		jwt.WithExpirationRequired(),
+	}
+
+	if config.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(config.Issuer))
+	}
+
+	if config.Audience != "" {
+		opts = append(opts, jwt.WithAudience(config.Audience))
+	}
+
+	a.parser = jwt.NewParser(opts...)
+
+	return a, nil
+}
+
+// Authenticate returns the principal of the request.
+// API keys are read from the X-API-Key header, tokens from Authorization: Bearer.
+// Without either, the verified client certificate of the connection is used if enabled.
+func (a *Authenticator) Authenticate(r *http.Request) (Principal, error) {
+	if a.clientCerts && r.Header.Get("X-API-Key") == "" && r.Header.Get("Authorization") == "" && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		subject, err := certificateSubject(r.TLS.VerifiedChains[0][0])
+		if err != nil {
+			return Principal{}, err
+		}
+
+		return Principal{Subject: subject, Method: MethodClientCert}, nil
+	}
+
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		name, ok := a.apiKeys[sha256.Sum256([]byte(key))]
+		if !ok {
+			return Principal{}, ErrInvalidAPIKey
+		}
+
+		return Principal{Subject: name, Method: MethodAPIKey}, nil
+	}
+
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return Principal{}, ErrMissingCredentials
+	}
+
+	if a.keys.empty() {
+		return Principal{}, fmt.Errorf("%w: bearer tokens are not accepted", ErrInvalidToken)
+	}
+
+	claims := jwt.MapClaims{}
+
+	if _, err := a.parser.ParseWithClaims(strings.TrimSpace(token), claims, a.keys.keyfunc); err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return Principal{}, fmt.Errorf("%w: sub claim is required", ErrInvalidToken)
+	}
+
+	return Principal{Subject: subject, Method: MethodJWT, Claims: claims}, nil
+}
+
+// certificateSubject returns the common name of the certificate, or its first URI SAN (e.g. a SPIFFE ID)
+func certificateSubject(cert *x509.Certificate) (string, error) {
+	if cert.Subject.CommonName != "" {
+		return cert.Subject.CommonName, nil
+	}
+
+	if len(cert.URIs) > 0 {
+		return cert.URIs[0].String(), nil
+	}
+
+	return "", ErrInvalidClientCert
+}
+
+// HashAPIKey returns the hex encoded SHA-256 of key, as it is configured
+func HashAPIKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+
+	return hex.EncodeToString(hash[:])
+}
+
+func parseAPIKey(entry string) (string, [sha256.Size]byte, error) {
+	var hash [sha256.Size]byte
+
+	name, hexHash, ok := strings.Cut(strings.TrimSpace(entry), ":")
+	if !ok || name == "" {
+		return "", hash, fmt.Errorf("API key %q is not name:sha256", entry)
+	}
+
+	decoded, err := hex.DecodeString(hexHash)
+	if err != nil || len(decoded) != sha256.Size {
+		return "", hash, fmt.Errorf("API key %q: hash is not a hex encoded SHA-256", name)
+	}
+
+	copy(hash[:], decoded)
+
+	return name, hash, nil
+}
+
+func readAPIKeysFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []string
+
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		entries = append(entries, line)
+	}
+
+	return entries, scanner.Err()
+}
@@ -0,0 +1,279 @@
+package auth_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/skantay/lru-api/internal/api"
+	"github.com/skantay/lru-api/internal/auth"
+	"github.com/skantay/lru-api/internal/cache"
+	"github.com/skantay/lru-api/internal/cache/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFile(t *testing.T, name string, data []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, data, 0o600))
+
+	return path
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+
+	signed, err := token.SignedString(key)
+	assert.NoError(t, err)
+
+	return signed
+}
+
+func request(apiKey, token string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/api/lru", nil)
+
+	if apiKey != "" {
+		r.Header.Set("X-API-Key", apiKey)
+	}
+
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return r
+}
+
+func TestAuthenticate(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	publicDER, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	assert.NoError(t, err)
+
+	jwksKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	jwks, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{
+			{
+				"kty": "RSA",
+				"kid": "rsa-1",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(jwksKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(jwksKey.E)).Bytes()),
+			},
+			{
+				"kty": "oct",
+				"kid": "hmac-1",
+				"k":   base64.RawURLEncoding.EncodeToString([]byte("jwks secret")),
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	authenticator, err := auth.New(auth.Config{
+		APIKeys:      []string{"deploy:" + auth.HashAPIKey("key-1")},
+		APIKeysFile:  writeFile(t, "keys", []byte("# admins\n\nadmin:"+auth.HashAPIKey("key-2")+"\n")),
+		HS256KeyFile: writeFile(t, "hs256", []byte("file secret\n")),
+		RS256KeyFile: writeFile(t, "rs256.pem", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})),
+		JWKSFile:     writeFile(t, "jwks.json", jwks),
+		Issuer:       "issuer",
+	})
+	assert.NoError(t, err)
+
+	valid := func(sub string) jwt.MapClaims {
+		return jwt.MapClaims{
+			"sub": sub,
+			"iss": "issuer",
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}
+	}
+
+	expired := valid("alice")
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+
+	tests := []struct {
+		name    string
+		request *http.Request
+		subject string
+		method  string
+		err     error
+	}{
+		{name: "API key from config", request: request("key-1", ""), subject: "deploy", method: auth.MethodAPIKey},
+		{name: "API key from file", request: request("key-2", ""), subject: "admin", method: auth.MethodAPIKey},
+		{name: "unknown API key", request: request("key-3", ""), err: auth.ErrInvalidAPIKey},
+		{name: "no credentials", request: request("", ""), err: auth.ErrMissingCredentials},
+		{
+			name:    "HS256 key file",
+			request: request("", sign(t, jwt.SigningMethodHS256, []byte("file secret"), "", valid("alice"))),
+			subject: "alice",
+			method:  auth.MethodJWT,
+		},
+		{
+			name:    "RS256 key file",
+			request: request("", sign(t, jwt.SigningMethodRS256, rsaKey, "", valid("bob"))),
+			subject: "bob",
+			method:  auth.MethodJWT,
+		},
+		{
+			name:    "RS256 JWKS",
+			request: request("", sign(t, jwt.SigningMethodRS256, jwksKey, "rsa-1", valid("carol"))),
+			subject: "carol",
+			method:  auth.MethodJWT,
+		},
+		{
+			name:    "HS256 JWKS",
+			request: request("", sign(t, jwt.SigningMethodHS256, []byte("jwks secret"), "hmac-1", valid("dave"))),
+			subject: "dave",
+			method:  auth.MethodJWT,
+		},
+		{
+			name:    "wrong key",
+			request: request("", sign(t, jwt.SigningMethodHS256, []byte("other"), "", valid("eve"))),
+			err:     auth.ErrInvalidToken,
+		},
+		{
+			name:    "RSA public key used as HMAC secret",
+			request: request("", sign(t, jwt.SigningMethodHS256, []byte(jwks), "rsa-1", valid("eve"))),
+			err:     auth.ErrInvalidToken,
+		},
+		{
+			name:    "expired",
+			request: request("", sign(t, jwt.SigningMethodHS256, []byte("file secret"), "", expired)),
+			err:     auth.ErrInvalidToken,
+		},
+		{
+			name:    "wrong issuer",
+			request: request("", sign(t, jwt.SigningMethodHS256, []byte("file secret"), "", jwt.MapClaims{"sub": "eve", "iss": "other", "exp": time.Now().Add(time.Hour).Unix()})),
+			err:     auth.ErrInvalidToken,
+		},
+		{
+			name:    "without sub",
+			request: request("", sign(t, jwt.SigningMethodHS256, []byte("file secret"), "", jwt.MapClaims{"iss": "issuer", "exp": time.Now().Add(time.Hour).Unix()})),
+			err:     auth.ErrInvalidToken,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			principal, err := authenticator.Authenticate(test.request)
+			assert.ErrorIs(t, err, test.err)
+			assert.Equal(t, test.subject, principal.Subject)
+			assert.Equal(t, test.method, principal.Method)
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	authenticator, err := auth.New(auth.Config{})
+	assert.NoError(t, err)
+	assert.Nil(t, authenticator)
+
+	_, err = auth.New(auth.Config{APIKeys: []string{"deploy:plain-text-key"}})
+	assert.Error(t, err)
+
+	_, err = auth.New(auth.Config{HS256KeyFile: filepath.Join(t.TempDir(), "missing")})
+	assert.Error(t, err)
+}
+
+func TestClientCert(t *testing.T) {
+	authenticator, err := auth.New(auth.Config{ClientCerts: true})
+	assert.NoError(t, err)
+
+	spiffe, err := url.Parse("spiffe://example.org/deploy")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		cert      *x509.Certificate
+		principal auth.Principal
+		err       error
+	}{
+		{
+			cert:      &x509.Certificate{Subject: pkix.Name{CommonName: "deploy"}},
+			principal: auth.Principal{Subject: "deploy", Method: auth.MethodClientCert},
+		},
+		{
+			cert:      &x509.Certificate{URIs: []*url.URL{spiffe}},
+			principal: auth.Principal{Subject: "spiffe://example.org/deploy", Method: auth.MethodClientCert},
+		},
+		{cert: &x509.Certificate{}, err: auth.ErrInvalidClientCert},
+		{cert: nil, err: auth.ErrMissingCredentials},
+	}
+
+	for _, test := range tests {
+		r := request("", "")
+		if test.cert != nil {
+			r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{test.cert}}}
+		}
+
+		principal, err := authenticator.Authenticate(r)
+
+		assert.ErrorIs(t, err, test.err)
+		assert.Equal(t, test.principal, principal)
+	}
+
+	// Other credentials take precedence, they are rejected if not configured
+	r := request("key-1", "")
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{tests[0].cert}}}
+
+	_, err = authenticator.Authenticate(r)
+	assert.ErrorIs(t, err, auth.ErrInvalidAPIKey)
+}
+
+func TestMiddleware(t *testing.T) {
+	c, err := cache.New(10, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	authenticator, err := auth.New(auth.Config{APIKeys: []string{"deploy:" + auth.HashAPIKey("key-1")}})
+	assert.NoError(t, err)
+
+	handler := api.New(c, slog.New(slog.NewTextHandler(io.Discard, nil)), api.WithAuthenticator(authenticator))
+
+	r := request("", "")
+	r.Header.Set("X-Request-Id", "req-1")
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `Bearer realm="lru-api"`, w.Header().Get("WWW-Authenticate"))
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{
+		"type": "urn:lru-api:problem:unauthenticated",
+		"title": "Authentication required",
+		"status": 401,
+		"detail": "missing credentials",
+		"instance": "/api/lru",
+		"code": "unauthenticated",
+		"request_id": "req-1"
+	}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, request("", "not-a-token"))
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `Bearer realm="lru-api", error="invalid_token"`, w.Header().Get("WWW-Authenticate"))
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, request("key-1", ""))
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
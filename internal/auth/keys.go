@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// keySet holds the keys tokens are verified with
+type keySet struct {
+	hmac []byte
+	rsa  *rsa.PublicKey
+
+	// Keys of the JWKS file by kid, []byte for oct keys and *rsa.PublicKey for RSA keys
+	jwks map[string]interface{}
+}
+
+func (s *keySet) load(config Config) error {
+	if config.HS256KeyFile != "" {
+		data, err := os.ReadFile(config.HS256KeyFile)
+		if err != nil {
+			return err
+		}
+
+		s.hmac = []byte(strings.TrimRight(string(data), "\r\n"))
+		if len(s.hmac) == 0 {
+			return fmt.Errorf("HS256 key file %s is empty", config.HS256KeyFile)
+		}
+	}
+
+	if config.RS256KeyFile != "" {
+		data, err := os.ReadFile(config.RS256KeyFile)
+		if err != nil {
+			return err
+		}
+
+		s.rsa, err = jwt.ParseRSAPublicKeyFromPEM(data)
+		if err != nil {
+			return fmt.Errorf("RS256 key file %s: %w", config.RS256KeyFile, err)
+		}
+	}
+
+	if config.JWKSFile != "" {
+		data, err := os.ReadFile(config.JWKSFile)
+		if err != nil {
+			return err
+		}
+
+		s.jwks, err = parseJWKS(data)
+		if err != nil {
+			return fmt.Errorf("JWKS file %s: %w", config.JWKSFile, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *keySet) empty() bool {
+	return s.hmac == nil && s.rsa == nil && len(s.jwks) == 0
+}
+
+// methods returns the signing methods there are keys for, tokens signed otherwise are rejected
+func (s *keySet) methods() []string {
+	var hs, rs bool
+
+	hs = s.hmac != nil
+	rs = s.rsa != nil
+
+	for _, key := range s.jwks {
+		switch key.(type) {
+		case []byte:
+			hs = true
+		case *rsa.PublicKey:
+			rs = true
+		}
+	}
+
+	var methods []string
+
+	if hs {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+
+	if rs {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+
+	return methods
+}
+
+// keyfunc selects the key by the kid header, tokens without a known kid are verified with the key files
+func (s *keySet) keyfunc(token *jwt.Token) (interface{}, error) {
+	var key interface{}
+
+	if kid, _ := token.Header["kid"].(string); kid != "" {
+		key = s.jwks[kid]
+	}
+
+	if key == nil {
+		switch token.Method.Alg() {
+		case jwt.SigningMethodHS256.Alg():
+			if s.hmac != nil {
+				key = s.hmac
+			}
+		case jwt.SigningMethodRS256.Alg():
+			if s.rsa != nil {
+				key = s.rsa
+			}
+		}
+	}
+
+	// A key of another type would let an RSA public key be used as an HMAC secret
+	switch key.(type) {
+	case []byte:
+		if token.Method.Alg() == jwt.SigningMethodHS256.Alg() {
+			return key, nil
+		}
+	case *rsa.PublicKey:
+		if token.Method.Alg() == jwt.SigningMethodRS256.Alg() {
+			return key, nil
+		}
+	}
+
+	return nil, errors.New("no key for the token")
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	// RSA modulus and exponent
+	N string `json:"n"`
+	E string `json:"e"`
+	// Symmetric key
+	K string `json:"k"`
+}
+
+// parseJWKS parses RSA and oct signing keys, other keys are skipped
+func parseJWKS(data []byte) (map[string]interface{}, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]interface{})
+
+	for _, key := range set.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+
+		if key.Kid == "" {
+			return nil, errors.New("key without kid")
+		}
+
+		switch key.Kty {
+		case "RSA":
+			n, err := base64.RawURLEncoding.DecodeString(key.N)
+			if err != nil {
+				return nil, fmt.Errorf("key %s: n: %w", key.Kid, err)
+			}
+
+			e, err := base64.RawURLEncoding.DecodeString(key.E)
+			if err != nil {
+				return nil, fmt.Errorf("key %s: e: %w", key.Kid, err)
+			}
+
+			exponent := new(big.Int).SetBytes(e)
+			if !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+				return nil, fmt.Errorf("key %s: invalid exponent", key.Kid)
+			}
+
+			keys[key.Kid] = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(exponent.Int64()),
+			}
+		case "oct":
+			k, err := base64.RawURLEncoding.DecodeString(key.K)
+			if err != nil || len(k) == 0 {
+				return nil, fmt.Errorf("key %s: invalid k", key.Kid)
+			}
+
+			keys[key.Kid] = k
+		}
+	}
+
+	return keys, nil
+}
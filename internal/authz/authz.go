@@ -0,0 +1,202 @@
+// Package authz decides which operations an authenticated principal may run on which keys.
+// The policy maps principals to roles and roles to operations allowed on key patterns.
+package authz
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/skantay/lru-api/internal/auth"
+	"github.com/skantay/lru-api/internal/glob"
+)
+
+// Operation guarded by the policy
+type Operation string
+
+const (
+	OpGet   Operation = "get"
+	OpPut   Operation = "put"
+	OpEvict Operation = "evict"
+	OpFlush Operation = "flush"
+	OpAdmin Operation = "admin"
+)
+
+var operations = map[Operation]bool{
+	OpGet:   true,
+	OpPut:   true,
+	OpEvict: true,
+	OpFlush: true,
+	OpAdmin: true,
+}
+
+// Rule allows operations on keys matching any of the patterns.
+// Patterns use internal/glob syntax, e.g. "team-a:*". Operations without a key, flush and admin,
+// are allowed by a rule regardless of its patterns.
+type Rule struct {
+	Operations []Operation `json:"operations"`
+	Keys       []string    `json:"keys"`
+}
+
+// Policy is the authorization policy, it is read from a JSON file:
+//
+//	{
+//	  "roles": {
+//	    "reader": [{"operations": ["get"], "keys": ["*"]}],
+//	    "team-a": [{"operations": ["get", "put", "evict"], "keys": ["team-a:*"]}],
+//	    "admin":  [{"operations": ["get", "put", "evict", "flush", "admin"], "keys": ["*"]}]
+//	  },
+//	  "principals": {"apikey:deploy": ["team-a"], "jwt:alice": ["admin"]},
+//	  "default_roles": ["reader"],
+//	  "roles_claim": "roles"
+//	}
+type Policy struct {
+	Roles map[string][]Rule `json:"roles"`
+	// Principals maps principals to their roles, by subject prefixed with their authentication method,
+	// e.g. "apikey:ops", "jwt:ops" or "cert:ops"
+	Principals map[string][]string `json:"principals"`
+	// DefaultRoles are given to every authenticated principal
+	DefaultRoles []string `json:"default_roles"`
+	// RolesClaim is a JWT claim holding an array of additional roles
+	RolesClaim string `json:"roles_claim"`
+}
+
+// methodPrefixes of principals in a policy, so an API key, a token and a certificate with the same name get their own roles
+var methodPrefixes = map[string]string{
+	auth.MethodAPIKey:     "apikey:",
+	auth.MethodJWT:        "jwt:",
+	auth.MethodClientCert: "cert:",
+}
+
+// Load reads the policy from a JSON file
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("policy %s: %w", path, err)
+	}
+
+	return policy, nil
+}
+
+// Parse parses and validates a policy
+func Parse(data []byte) (*Policy, error) {
+	var policy Policy
+
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, err
+	}
+
+	for role, rules := range policy.Roles {
+		for _, rule := range rules {
+			for _, op := range rule.Operations {
+				if !operations[op] {
+					return nil, fmt.Errorf("role %s: unknown operation %q", role, op)
+				}
+			}
+		}
+	}
+
+	check := func(owner string, roles []string) error {
+		for _, role := range roles {
+			if _, ok := policy.Roles[role]; !ok {
+				return fmt.Errorf("%s: unknown role %q", owner, role)
+			}
+		}
+
+		return nil
+	}
+
+	if err := check("default_roles", policy.DefaultRoles); err != nil {
+		return nil, err
+	}
+
+	for principal, roles := range policy.Principals {
+		if !prefixed(principal) {
+			return nil, fmt.Errorf("principal %s: not prefixed with apikey:, jwt: or cert:", principal)
+		}
+
+		if err := check("principal "+principal, roles); err != nil {
+			return nil, err
+		}
+	}
+
+	return &policy, nil
+}
+
+// Allowed reports whether the principal may run the operation on the key.
+// Roles from the roles claim that the policy does not define are ignored.
+func (p *Policy) Allowed(principal auth.Principal, op Operation, key string) bool {
+	for _, role := range p.roles(principal) {
+		for _, rule := range p.Roles[role] {
+			if rule.allows(op, key) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (p *Policy) roles(principal auth.Principal) []string {
+	roles := p.DefaultRoles[:len(p.DefaultRoles):len(p.DefaultRoles)]
+
+	if prefix, ok := methodPrefixes[principal.Method]; ok {
+		roles = append(roles, p.Principals[prefix+principal.Subject]...)
+	}
+
+	if p.RolesClaim != "" && principal.Claims != nil {
+		if claimed, ok := principal.Claims[p.RolesClaim].([]interface{}); ok {
+			for _, role := range claimed {
+				if name, ok := role.(string); ok {
+					roles = append(roles, name)
+				}
+			}
+		}
+	}
+
+	return roles
+}
+
+func prefixed(principal string) bool {
+	for _, prefix := range methodPrefixes {
+		if strings.HasPrefix(principal, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r Rule) allows(op Operation, key string) bool {
+	allowed := false
+
+	for _, ruleOp := range r.Operations {
+		if ruleOp == op {
+			allowed = true
+
+			break
+		}
+	}
+
+	if !allowed {
+		return false
+	}
+
+	if op == OpFlush || op == OpAdmin {
+		return true
+	}
+
+	for _, pattern := range r.Keys {
+		if glob.Match(pattern, key) {
+			return true
+		}
+	}
+
+	return false
+}
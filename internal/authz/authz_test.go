@@ -0,0 +1,89 @@
+package authz
+
+import (
+	"testing"
+
+	"github.com/skantay/lru-api/internal/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+const testPolicy = `{
+	"roles": {
+		"reader": [{"operations": ["get"], "keys": ["*"]}],
+		"team-a": [{"operations": ["get", "put", "evict"], "keys": ["team-a:*"]}],
+		"admin":  [{"operations": ["get", "put", "evict", "flush", "admin"], "keys": ["*"]}]
+	},
+	"principals": {"apikey:deploy": ["team-a"], "jwt:alice": ["admin"], "jwt:bob": ["reader"], "cert:admin": ["admin"]},
+	"roles_claim": "roles"
+}`
+
+func TestAllowed(t *testing.T) {
+	policy, err := Parse([]byte(testPolicy))
+	assert.NoError(t, err)
+
+	deploy := auth.Principal{Subject: "deploy", Method: auth.MethodAPIKey}
+	alice := auth.Principal{Subject: "alice", Method: auth.MethodJWT}
+	bob := auth.Principal{Subject: "bob", Method: auth.MethodJWT}
+	carol := auth.Principal{Subject: "carol", Method: auth.MethodJWT, Claims: map[string]interface{}{"roles": []interface{}{"team-a", "unknown"}}}
+	unknown := auth.Principal{Subject: "mallory", Method: auth.MethodAPIKey}
+	// Bindings are per authentication method, an API key named like a certificate does not get its roles
+	certAdmin := auth.Principal{Subject: "admin", Method: auth.MethodClientCert}
+	keyAdmin := auth.Principal{Subject: "admin", Method: auth.MethodAPIKey}
+	deployToken := auth.Principal{Subject: "deploy", Method: auth.MethodJWT}
+
+	tests := []struct {
+		principal auth.Principal
+		op        Operation
+		key       string
+		allowed   bool
+	}{
+		{principal: deploy, op: OpPut, key: "team-a:1", allowed: true},
+		{principal: deploy, op: OpGet, key: "team-a:1", allowed: true},
+		{principal: deploy, op: OpPut, key: "team-b:1", allowed: false},
+		{principal: deploy, op: OpGet, key: "team-b:1", allowed: false},
+		{principal: deploy, op: OpFlush, allowed: false},
+		{principal: alice, op: OpFlush, allowed: true},
+		{principal: alice, op: OpAdmin, allowed: true},
+		{principal: alice, op: OpEvict, key: "any", allowed: true},
+		{principal: bob, op: OpGet, key: "team-b:1", allowed: true},
+		{principal: bob, op: OpPut, key: "team-b:1", allowed: false},
+		{principal: carol, op: OpEvict, key: "team-a:2", allowed: true},
+		{principal: carol, op: OpEvict, key: "team-b:2", allowed: false},
+		{principal: unknown, op: OpGet, key: "team-a:1", allowed: false},
+		{principal: certAdmin, op: OpFlush, allowed: true},
+		{principal: keyAdmin, op: OpFlush, allowed: false},
+		{principal: deployToken, op: OpPut, key: "team-a:1", allowed: false},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.allowed, policy.Allowed(test.principal, test.op, test.key), "%s %s %s %s", test.principal.Method, test.principal.Subject, test.op, test.key)
+	}
+}
+
+func TestDefaultRoles(t *testing.T) {
+	policy, err := Parse([]byte(`{
+		"roles": {"reader": [{"operations": ["get"], "keys": ["public:*"]}]},
+		"default_roles": ["reader"]
+	}`))
+	assert.NoError(t, err)
+
+	anyone := auth.Principal{Subject: "anyone"}
+
+	assert.True(t, policy.Allowed(anyone, OpGet, "public:1"))
+	assert.False(t, policy.Allowed(anyone, OpGet, "private:1"))
+}
+
+func TestParse(t *testing.T) {
+	tests := []string{
+		`{"roles": {"r": [{"operations": ["delete"], "keys": ["*"]}]}}`,
+		`{"roles": {}, "principals": {"jwt:alice": ["admin"]}}`,
+		`{"roles": {"admin": []}, "principals": {"alice": ["admin"]}}`,
+		`{"roles": {}, "default_roles": ["reader"]}`,
+		`not json`,
+	}
+
+	for _, test := range tests {
+		_, err := Parse([]byte(test))
+		assert.Error(t, err, test)
+	}
+}
@@ -0,0 +1,9 @@
+package cache
+
+// Blob is a binary value stored verbatim together with its media type, e.g. an image or a protobuf message.
+// Data must not be modified once the blob is stored.
+// Encoded as JSON, Data is base64.
+type Blob struct {
+	ContentType string `json:"content_type"`
+	Data        []byte `json:"data"`
+}
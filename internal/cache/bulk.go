@@ -0,0 +1,127 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Entry is a node/item as it is got by GetEntry, scanned and loaded
+type Entry struct {
+	Key       string
+	Value     interface{}
+	ExpiresAt time.Time
+	// ModifiedAt is the time the node/item was last written, it is ignored by Load
+	ModifiedAt time.Time
+}
+
+// Scan calls fn with batches of up to batchSize live nodes/items, from the least to the most recently used.
+// The cache is locked only while a batch is collected, so fn may be slow and may use the cache.
+// Keys are taken when the scan starts: nodes/items created later are not scanned, evicted ones are skipped.
+// Scanning does not move nodes/items to the front of LRU cache.
+func (l *LRUCache) Scan(ctx context.Context, batchSize int, fn func(entries []Entry) error) error {
+	span := l.startSpan(ctx, "LRUCache.Scan", "")
+	defer span.End()
+
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	l.lock(span)
+
+	keys := make([]string, 0, l.len)
+
+	for node := l.least; node != nil; node = node.prev {
+		keys = append(keys, node.key)
+	}
+
+	l.m.Unlock()
+
+	batch := make([]Entry, 0, batchSize)
+
+	for start := 0; start < len(keys); start += batchSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		batch = l.collect(batch[:0], keys[start:min(start+batchSize, len(keys))])
+
+		if len(batch) == 0 {
+			continue
+		}
+
+		if err := fn(batch); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// collect appends the live nodes/items of keys to batch, values are decompressed after the cache is unlocked
+func (l *LRUCache) collect(batch []Entry, keys []string) []Entry {
+	defer l.observeSince(opScan, time.Now())
+
+	start := len(batch)
+
+	l.m.Lock()
+
+	now := time.Now()
+
+	for _, key := range keys {
+		if node, ok := l.values[key]; ok && !now.After(node.ttl) {
+			batch = append(batch, Entry{Key: key, Value: node.value, ExpiresAt: node.ttl, ModifiedAt: node.modified})
+		}
+	}
+
+	l.m.Unlock()
+
+	for i := range batch[start:] {
+		batch[start+i].Value = unpack(batch[start+i].Value)
+	}
+
+	return batch
+}
+
+// Load inserts or updates nodes/items with their absolute expiration times under a single lock.
+// If ExpiresAt is zero, then default TTL is applied. Entries that already expired are skipped.
+func (l *LRUCache) Load(ctx context.Context, entries []Entry) error {
+	defer l.observeSince(opLoad, time.Now())
+
+	span := l.startSpan(ctx, "LRUCache.Load", "")
+	defer span.End()
+
+	select {
+	case <-ctx.Done():
+		l.log.WarnContext(ctx, ctx.Err().Error())
+		return ctx.Err()
+	default:
+	}
+
+	// Compressed before the cache is locked
+	values := make([]interface{}, len(entries))
+	for i, entry := range entries {
+		values[i] = l.pack(entry.Value)
+	}
+
+	l.lock(span)
+	defer l.unlock()
+
+	now := time.Now()
+
+	for i, entry := range entries {
+		expiration := entry.ExpiresAt
+		if expiration.IsZero() {
+			expiration = now.Add(l.defaultTTL)
+		}
+
+		if now.After(expiration) {
+			l.log.DebugContext(ctx, "expired node skipped", "key", entry.Key)
+
+			continue
+		}
+
+		l.putUntil(ctx, entry.Key, values[i], expiration)
+	}
+
+	return nil
+}
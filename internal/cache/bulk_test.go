@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/skantay/lru-api/internal/cache/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScan(t *testing.T) {
+	c, err := New(10, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		assert.NoError(t, c.Put(ctx, key, key, 0))
+	}
+
+	assert.NoError(t, c.Put(ctx, "expired", 0, time.Millisecond))
+	time.Sleep(2 * time.Millisecond)
+
+	// "a" becomes the most recently used
+	_, _, err = c.Get(ctx, "a")
+	assert.NoError(t, err)
+
+	var (
+		keys    []string
+		batches int
+	)
+
+	err = c.Scan(ctx, 2, func(entries []Entry) error {
+		batches++
+
+		for _, entry := range entries {
+			keys = append(keys, entry.Key)
+			assert.Equal(t, entry.Key, entry.Value)
+			assert.WithinDuration(t, time.Now().Add(time.Minute), entry.ExpiresAt, time.Second)
+		}
+
+		// The cache is not locked while the batch is handled
+		if batches == 1 {
+			_, err := c.Evict(ctx, "d")
+			assert.NoError(t, err)
+		}
+
+		return nil
+	})
+	assert.NoError(t, err)
+
+	// "expired" is skipped and "d" was evicted during the scan
+	assert.Equal(t, []string{"b", "c", "e", "a"}, keys)
+
+	// Scanning does not change the LRU order
+	assert.Equal(t, "a", c.most.key)
+
+	stop := errors.New("stop")
+
+	assert.ErrorIs(t, c.Scan(ctx, 1, func([]Entry) error { return stop }), stop)
+}
+
+func TestLoad(t *testing.T) {
+	c, err := New(2, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+
+	assert.NoError(t, c.Load(ctx, []Entry{
+		{Key: "absolute", Value: 1, ExpiresAt: expiresAt},
+		{Key: "expired", Value: 2, ExpiresAt: time.Now().Add(-time.Second)},
+		{Key: "default", Value: 3},
+	}))
+
+	value, gotExpiresAt, err := c.Get(ctx, "absolute")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, value)
+	assert.Equal(t, expiresAt, gotExpiresAt)
+
+	_, _, err = c.Get(ctx, "expired")
+	assert.ErrorIs(t, err, ErrKeyDoesNotExist)
+
+	_, gotExpiresAt, err = c.Get(ctx, "default")
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), gotExpiresAt, time.Second)
+
+	// Capacity applies as for Put
+	assert.NoError(t, c.Load(ctx, []Entry{{Key: "new", Value: 4}}))
+
+	_, _, err = c.Get(ctx, "absolute")
+	assert.ErrorIs(t, err, ErrKeyDoesNotExist)
+
+	ctxCanceled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	assert.ErrorIs(t, c.Load(ctxCanceled, nil), context.Canceled)
+}
@@ -6,6 +6,9 @@ import (
 	"errors"
 	"sync"
 	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 var (
@@ -16,11 +19,20 @@ var (
 	ErrInvalidCacheSize = errors.New("invalid cache size")
 )
 
+// KeepTTL returned from UpdateFunc keeps the current expiration time of a node/item
+const KeepTTL time.Duration = -1
+
+// UpdateFunc receives the current value of a node/item, its expiration time and whether it exists.
+// It returns the new value, its TTL and whether the new value should be stored.
+// If ttl == 0, then default TTL is applied, if ttl == KeepTTL, then current expiration time is kept.
+type UpdateFunc func(value interface{}, expiresAt time.Time, ok bool) (newValue interface{}, ttl time.Duration, store bool)
+
+// logger takes the context of the call, so handlers can log request scoped values such as the request ID
 type logger interface {
-	Debug(msg string, args ...any)
-	Warn(msg string, args ...any)
-	Info(msg string, args ...any)
-	Error(msg string, args ...any)
+	DebugContext(ctx context.Context, msg string, args ...any)
+	WarnContext(ctx context.Context, msg string, args ...any)
+	InfoContext(ctx context.Context, msg string, args ...any)
+	ErrorContext(ctx context.Context, msg string, args ...any)
 }
 
 type node struct {
@@ -28,130 +40,302 @@ type node struct {
 	value      interface{}
 	key        string
 	ttl        time.Time
+	modified   time.Time
+	size       uint64
 }
 
 // LRUCache implements a concurrent safe LRU cache with TTL support.
 type LRUCache struct {
 	defaultTTL  time.Duration
 	len, cap    uint
+	bytes       uint64
 	values      map[string]*node
 	m           *sync.Mutex
 	most, least *node
+	events      *hub
+	stats       stats
+	observe     func(operation string, duration time.Duration)
+	tracer      trace.Tracer
+	recorder    Recorder
+
+	// compressThreshold is the length above which values are compressed, 0 disables compression
+	compressThreshold int
 
 	// Decided to inject an abstraction, not an implementation
 	// It will be much easier to test
 	log logger
 }
 
+// Option configures optional behaviour of LRUCache
+type Option func(*LRUCache)
+
+// WithObserver sets a function that receives the duration of every operation, including the time spent waiting for the lock.
+// Operations are named put, update, get, get_all, evict, evict_all, scan (a batch of Scan) and load.
+func WithObserver(observe func(operation string, duration time.Duration)) Option {
+	return func(l *LRUCache) {
+		l.observe = observe
+	}
+}
+
 // New creates a new instance of LRUCache with the specified cache size, TTL, and logger.
 func New(
 	cacheSize uint,
 	ttl time.Duration,
 	log logger,
+	opts ...Option,
 ) (*LRUCache, error) {
 	if cacheSize == 0 {
 		return nil, ErrInvalidCacheSize
 	}
 
-	return &LRUCache{
+	l := &LRUCache{
 		cap:        cacheSize,
 		defaultTTL: ttl,
 		m:          &sync.Mutex{},
 		values:     make(map[string]*node),
+		events:     newHub(defaultBacklogSize, defaultSubscriberBuffer),
+		stats:      newStats(),
+		tracer:     noop.NewTracerProvider().Tracer(tracerName),
 		log:        log,
-	}, nil
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l, nil
 }
 
 // Put inserts or updates a node/item.
 // If ttl == 0, then default TTL is applied
-func (l *LRUCache) Put(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+func (l *LRUCache) Put(ctx context.Context, key string, value interface{}, ttl time.Duration) (err error) {
+	// Deferred first, so it runs after the cache is unlocked
+	defer func() { l.record(ctx, EventPut, key, err) }()
+	defer l.observeSince(opPut, time.Now())
+
+	span := l.startSpan(ctx, "LRUCache.Put", key)
+	defer span.End()
+
 	select {
 	case <-ctx.Done():
-		l.log.Warn(ctx.Err().Error())
+		l.log.WarnContext(ctx, ctx.Err().Error())
 		return ctx.Err()
 	default:
 	}
 
-	l.m.Lock()
-	defer l.m.Unlock()
+	// Compressed before the cache is locked, values returned by the UpdateFunc of Update are compressed while it is
+	value = l.pack(value)
+
+	l.lock(span)
+	defer l.unlock()
+
+	l.put(ctx, key, value, ttl)
 
+	return nil
+}
+
+// Update atomically reads and conditionally replaces a node/item.
+// fn is called with the cache locked, so it must not call the cache.
+// An expired node/item is reported to fn as not existing.
+// If fn decides not to store, the node/item is left untouched and it is not moved to the front of LRU cache.
+func (l *LRUCache) Update(ctx context.Context, key string, fn UpdateFunc) error {
+	var stored bool
+
+	defer func() {
+		if stored {
+			l.record(ctx, EventPut, key, nil)
+		}
+	}()
+	defer l.observeSince(opUpdate, time.Now())
+
+	span := l.startSpan(ctx, "LRUCache.Update", key)
+	defer span.End()
+
+	select {
+	case <-ctx.Done():
+		l.log.WarnContext(ctx, ctx.Err().Error())
+		return ctx.Err()
+	default:
+	}
+
+	l.lock(span)
+	defer l.unlock()
+
+	var (
+		value     interface{}
+		expiresAt time.Time
+	)
+
+	var (
+		node *node
+		ok   bool
+	)
+
+	for {
+		node, ok = l.values[key]
+		if ok && time.Now().After(node.ttl) {
+			l.evictNode(node)
+			l.stats.evictions[EvictionExpired]++
+			l.publish(EventExpire, node)
+			l.log.DebugContext(ctx, "node expired and has been evicted", "key", node.key)
+
+			ok = false
+		}
+
+		if !ok {
+			break
+		}
+
+		// Read again if the node/item was written while its value was decompressed
+		if unpacked, current := l.unpackUnlocked(node); current {
+			value, expiresAt = unpacked, node.ttl
+
+			break
+		}
+	}
+
+	span.SetAttributes(attrHit.Bool(ok))
+
+	newValue, ttl, store := fn(value, expiresAt, ok)
+	if !store {
+		return nil
+	}
+
+	if ttl == KeepTTL {
+		ttl = 0
+
+		if ok {
+			ttl = time.Until(expiresAt)
+		}
+	}
+
+	l.put(ctx, key, l.pack(newValue), ttl)
+
+	stored = true
+
+	return nil
+}
+
+// put must be called with l.m held
+func (l *LRUCache) put(ctx context.Context, key string, value interface{}, ttl time.Duration) {
 	if ttl == 0 {
-		l.log.Debug("default ttl applied", "key", key)
+		l.log.DebugContext(ctx, "default ttl applied", "key", key)
 		ttl = l.defaultTTL
 	}
 
+	l.putUntil(ctx, key, value, time.Now().Add(ttl))
+}
+
+// putUntil must be called with l.m held, value is stored as it is, packed by the caller
+func (l *LRUCache) putUntil(ctx context.Context, key string, value interface{}, expiration time.Time) {
 	now := time.Now()
-	expiration := now.Add(ttl)
 
-	l.log.Debug("node created/updated", "key", key, "created time", now.Format(time.RFC1123), "expiration time", expiration.Format(time.RFC1123))
+	l.log.DebugContext(ctx, "node created/updated", "key", key, "created time", now.Format(time.RFC1123), "expiration time", expiration.Format(time.RFC1123))
 
 	if nodeFound, ok := l.values[key]; !ok {
-		l.createNode(
-			key,
-			&node{
-				ttl:   expiration,
-				value: value,
-				next:  l.most,
-				key:   key,
-			})
-		l.log.Debug("creating new node", "key", key)
+		newNode := &node{
+			ttl:      expiration,
+			modified: now,
+			value:    value,
+			next:     l.most,
+			key:      key,
+			size:     SizeOf(key, value),
+		}
+
+		l.createNode(ctx, key, newNode)
+		l.publish(EventPut, newNode)
+		l.log.DebugContext(ctx, "creating new node", "key", key)
 	} else {
+		l.bytes -= nodeFound.size
+
 		nodeFound.value = value
 		nodeFound.ttl = expiration
+		nodeFound.modified = now
+		nodeFound.size = SizeOf(key, value)
+
+		l.bytes += nodeFound.size
 
 		l.updateNode(nodeFound)
+		l.publish(EventUpdate, nodeFound)
 
-		l.log.Debug("node accessed, updated and moved to the front of LRU cache", "key", key)
+		l.log.DebugContext(ctx, "node accessed, updated and moved to the front of LRU cache", "key", key)
 	}
-
-	return nil
 }
 
 // Get retrieves a node/item by a specific key.
 // If node/item was not found, then it returns ErrKeyDoesNotExist
 func (l *LRUCache) Get(ctx context.Context, key string) (value interface{}, expiresAt time.Time, err error) {
-	l.m.Lock()
-	defer l.m.Unlock()
+	entry, err := l.GetEntry(ctx, key)
+
+	return entry.Value, entry.ExpiresAt, err
+}
+
+// GetEntry retrieves a node/item like Get, together with the time it was last written
+func (l *LRUCache) GetEntry(ctx context.Context, key string) (entry Entry, err error) {
+	// Decompressed after the cache is unlocked
+	defer func() { entry.Value = unpack(entry.Value) }()
+	defer l.observeSince(opGet, time.Now())
+
+	span := l.startSpan(ctx, "LRUCache.Get", key)
+	defer span.End()
+
+	l.lock(span)
+	defer l.unlock()
 
 	select {
 	case <-ctx.Done():
-		l.log.Warn(ctx.Err().Error())
-		return nil, time.Time{}, ctx.Err()
+		l.log.WarnContext(ctx, ctx.Err().Error())
+		return Entry{}, ctx.Err()
 	default:
 	}
 
 	node, ok := l.values[key]
 	if !ok {
-		l.log.Warn(ErrKeyDoesNotExist.Error(), "key", key)
+		l.stats.misses++
+		span.SetAttributes(attrHit.Bool(false))
+		l.log.WarnContext(ctx, ErrKeyDoesNotExist.Error(), "key", key)
 
-		return nil, time.Time{}, ErrKeyDoesNotExist
+		return Entry{}, ErrKeyDoesNotExist
 	}
 
 	if time.Now().After(node.ttl) {
 		l.evictNode(node)
-		l.log.Debug("node expired and has been evicted", "key", node.key)
+		l.stats.misses++
+		span.SetAttributes(attrHit.Bool(false))
+		l.stats.evictions[EvictionExpired]++
+		l.publish(EventExpire, node)
+		l.log.DebugContext(ctx, "node expired and has been evicted", "key", node.key)
 
-		return nil, time.Time{}, ErrKeyDoesNotExist
+		return Entry{}, ErrKeyDoesNotExist
 	}
 
+	l.stats.hits++
+	span.SetAttributes(attrHit.Bool(true))
 	l.updateNode(node)
-	l.log.Debug("node accessed and moved to the front of LRU cache", "key", node.key)
-
-	value = node.value
+	l.log.DebugContext(ctx, "node accessed and moved to the front of LRU cache", "key", node.key)
 
-	expiresAt = node.ttl
-
-	return
+	return Entry{Key: key, Value: node.value, ExpiresAt: node.ttl, ModifiedAt: node.modified}, nil
 }
 
 // Get retrieves all nodes/items from cache.
 func (l *LRUCache) GetAll(ctx context.Context) (keys []string, values []interface{}, err error) {
-	l.m.Lock()
-	defer l.m.Unlock()
+	// Decompressed after the cache is unlocked
+	defer func() {
+		for i := range values {
+			values[i] = unpack(values[i])
+		}
+	}()
+	defer l.observeSince(opGetAll, time.Now())
+
+	span := l.startSpan(ctx, "LRUCache.GetAll", "")
+	defer span.End()
+
+	l.lock(span)
+	defer l.unlock()
 
 	select {
 	case <-ctx.Done():
-		l.log.Warn(ctx.Err().Error())
+		l.log.WarnContext(ctx, ctx.Err().Error())
 		return nil, nil, ctx.Err()
 	default:
 	}
@@ -161,7 +345,9 @@ func (l *LRUCache) GetAll(ctx context.Context) (keys []string, values []interfac
 	for key, node := range l.values {
 		if now.After(node.ttl) {
 			l.evictNode(node)
-			l.log.Debug("node expired and has been evicted", "key", node.key)
+			l.stats.evictions[EvictionExpired]++
+			l.publish(EventExpire, node)
+			l.log.DebugContext(ctx, "node expired and has been evicted", "key", node.key)
 		} else {
 			keys = append(keys, key)
 			values = append(values, node.value)
@@ -174,19 +360,29 @@ func (l *LRUCache) GetAll(ctx context.Context) (keys []string, values []interfac
 // Evict deletes a node/item from cache by a specific key.
 // If node/item was not found, then it returns ErrKeyDoesNotEXist
 func (l *LRUCache) Evict(ctx context.Context, key string) (value interface{}, err error) {
-	l.m.Lock()
-	defer l.m.Unlock()
+	defer func() { l.record(ctx, EventEvict, key, err) }()
+	// Decompressed after the cache is unlocked
+	defer func() { value = unpack(value) }()
+	defer l.observeSince(opEvict, time.Now())
+
+	span := l.startSpan(ctx, "LRUCache.Evict", key)
+	defer span.End()
+
+	l.lock(span)
+	defer l.unlock()
 
 	select {
 	case <-ctx.Done():
-		l.log.Warn(ctx.Err().Error())
+		l.log.WarnContext(ctx, ctx.Err().Error())
 		return nil, ctx.Err()
 	default:
 	}
 
 	node, ok := l.values[key]
+	span.SetAttributes(attrHit.Bool(ok))
+
 	if !ok {
-		l.log.Warn(ErrKeyDoesNotExist.Error(), "key", key)
+		l.log.WarnContext(ctx, ErrKeyDoesNotExist.Error(), "key", key)
 
 		return nil, ErrKeyDoesNotExist
 	}
@@ -194,30 +390,43 @@ func (l *LRUCache) Evict(ctx context.Context, key string) (value interface{}, er
 	value = node.value
 
 	l.evictNode(node)
-	l.log.Debug("node has been evicted", "key", node.key)
+	l.stats.evictions[EvictionDeleted]++
+	l.publish(EventEvict, node)
+	l.log.DebugContext(ctx, "node has been evicted", "key", node.key)
 
 	return
 }
 
 // EvictAll flushes the cache.
-func (l *LRUCache) EvictAll(ctx context.Context) error {
-	l.m.Lock()
-	defer l.m.Unlock()
+func (l *LRUCache) EvictAll(ctx context.Context) (err error) {
+	defer func() { l.record(ctx, EventFlush, "", err) }()
+	defer l.observeSince(opEvictAll, time.Now())
+
+	span := l.startSpan(ctx, "LRUCache.EvictAll", "")
+	defer span.End()
+
+	l.lock(span)
+	defer l.unlock()
 
 	select {
 	case <-ctx.Done():
-		l.log.Warn(ctx.Err().Error())
+		l.log.WarnContext(ctx, ctx.Err().Error())
 		return ctx.Err()
 	default:
 	}
 
+	l.stats.evictions[EvictionFlushed] += uint64(l.len)
+
 	l.values = make(map[string]*node)
 
 	l.len = 0
+	l.bytes = 0
 	l.most = nil
 	l.least = nil
 
-	l.log.Debug("cache successfully has been flushed")
+	l.publish(EventFlush, nil)
+
+	l.log.DebugContext(ctx, "cache successfully has been flushed")
 
 	return nil
 }
@@ -260,9 +469,10 @@ func (l *LRUCache) evictNode(node *node) {
 
 	delete(l.values, node.key)
 	l.len--
+	l.bytes -= node.size
 }
 
-func (l *LRUCache) createNode(key string, node *node) {
+func (l *LRUCache) createNode(ctx context.Context, key string, node *node) {
 	if l.most != nil {
 		l.most.prev = node
 	}
@@ -278,7 +488,10 @@ func (l *LRUCache) createNode(key string, node *node) {
 			leastPrev := l.least.prev
 
 			delete(l.values, l.least.key)
-			l.log.Debug("least used node has been evicted", "key", l.least.key)
+			l.bytes -= l.least.size
+			l.stats.evictions[EvictionCapacity]++
+			l.publish(EventEvict, l.least)
+			l.log.DebugContext(ctx, "least used node has been evicted", "key", l.least.key)
 
 			l.least = leastPrev
 
@@ -291,4 +504,5 @@ func (l *LRUCache) createNode(key string, node *node) {
 	}
 
 	l.values[key] = node
+	l.bytes += node.size
 }
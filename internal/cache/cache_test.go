@@ -288,3 +288,78 @@ func TestEvictAll(t *testing.T) {
 		t.Error("cache is supposed to be empty")
 	}
 }
+
+func TestUpdate(t *testing.T) {
+	cache, err := New(2, time.Second*60, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	increment := func(value interface{}, _ time.Time, ok bool) (interface{}, time.Duration, bool) {
+		if !ok {
+			return 1, 0, true
+		}
+
+		return value.(int) + 1, KeepTTL, true
+	}
+
+	err = cache.Update(context.Background(), "key 1", increment)
+	assert.NoError(t, err)
+
+	_, expiresAt, err := cache.Get(context.Background(), "key 1")
+	assert.NoError(t, err)
+
+	err = cache.Update(context.Background(), "key 1", increment)
+	assert.NoError(t, err)
+
+	value, newExpiresAt, err := cache.Get(context.Background(), "key 1")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, value)
+	assert.WithinDuration(t, expiresAt, newExpiresAt, time.Millisecond)
+
+	var found bool
+
+	err = cache.Update(context.Background(), "key 2", func(_ interface{}, _ time.Time, ok bool) (interface{}, time.Duration, bool) {
+		found = ok
+
+		return nil, 0, false
+	})
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	_, _, err = cache.Get(context.Background(), "key 2")
+	assert.Equal(t, ErrKeyDoesNotExist, err)
+}
+
+func TestGetEntry(t *testing.T) {
+	c, err := New(2, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+
+	before := time.Now()
+	assert.NoError(t, c.Put(ctx, "key", "value 1", time.Hour))
+
+	entry, err := c.GetEntry(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, "key", entry.Key)
+	assert.Equal(t, "value 1", entry.Value)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), entry.ExpiresAt, time.Second)
+	assert.False(t, entry.ModifiedAt.Before(before))
+
+	modified := entry.ModifiedAt
+
+	time.Sleep(time.Millisecond)
+
+	// Reading does not change the modification time, writing does
+	entry, err = c.GetEntry(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, modified, entry.ModifiedAt)
+
+	assert.NoError(t, c.Put(ctx, "key", "value 2", 0))
+
+	entry, err = c.GetEntry(ctx, "key")
+	assert.NoError(t, err)
+	assert.True(t, entry.ModifiedAt.After(modified))
+
+	_, err = c.GetEntry(ctx, "missing")
+	assert.ErrorIs(t, err, ErrKeyDoesNotExist)
+}
@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// WithCompression stores strings, byte slices and blobs longer than threshold bytes compressed with zstd.
+// Values are decompressed when they are read, so callers get back what they stored,
+// and Stats.Bytes counts the compressed size. Values that do not shrink are stored as they are.
+func WithCompression(threshold int) Option {
+	return func(l *LRUCache) {
+		l.compressThreshold = threshold
+	}
+}
+
+// Kinds of compressed values, so they are decompressed into their original type
+const (
+	kindString = iota
+	kindBytes
+	kindBlob
+)
+
+// compressed is a value stored compressed
+type compressed struct {
+	kind        int
+	contentType string
+	data        []byte
+}
+
+// Encoding and decoding whole values is safe for concurrent use, one coder serves the cache
+var (
+	zstdEncoder = sync.OnceValue(func() *zstd.Encoder {
+		return mustCoder(zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1)))
+	})
+	zstdDecoder = sync.OnceValue(func() *zstd.Decoder {
+		return mustCoder(zstd.NewReader(nil))
+	})
+)
+
+// mustCoder panics on an error, coders fail on invalid options only
+func mustCoder[T any](coder T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+
+	return coder
+}
+
+// pack compresses value if compression is enabled and it is worth it
+func (l *LRUCache) pack(value interface{}) interface{} {
+	if l.compressThreshold <= 0 {
+		return value
+	}
+
+	var c compressed
+
+	var data []byte
+
+	switch v := value.(type) {
+	case string:
+		c.kind, data = kindString, []byte(v)
+	case []byte:
+		c.kind, data = kindBytes, v
+	case Blob:
+		c.kind, c.contentType, data = kindBlob, v.ContentType, v.Data
+	default:
+		return value
+	}
+
+	if len(data) <= l.compressThreshold {
+		return value
+	}
+
+	c.data = zstdEncoder().EncodeAll(data, make([]byte, 0, len(data)/2))
+	if len(c.data) >= len(data) {
+		return value
+	}
+
+	return c
+}
+
+// unpack restores a value stored by pack
+func unpack(value interface{}) interface{} {
+	c, ok := value.(compressed)
+	if !ok {
+		return value
+	}
+
+	// The data was compressed by pack, it only fails to decode if memory is corrupted
+	data, err := zstdDecoder().DecodeAll(c.data, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	switch c.kind {
+	case kindString:
+		return string(data)
+	case kindBlob:
+		return Blob{ContentType: c.contentType, Data: data}
+	}
+
+	return data
+}
+
+// unpackUnlocked decompresses the value of node with l.m released, so other operations are not held up.
+// It must be called with l.m held and returns with it held again,
+// current is false if the node/item was written or removed meanwhile.
+func (l *LRUCache) unpackUnlocked(n *node) (value interface{}, current bool) {
+	c, ok := n.value.(compressed)
+	if !ok {
+		return n.value, true
+	}
+
+	l.m.Unlock()
+	value = unpack(c)
+	l.m.Lock()
+
+	// A write stores other data, and data is never empty
+	now, ok := n.value.(compressed)
+
+	return value, ok && l.values[n.key] == n && &now.data[0] == &c.data[0]
+}
+
+// unpacked returns the event with its value decompressed, the backlog keeps values compressed
+func unpacked(e Event) Event {
+	e.Value = unpack(e.Value)
+
+	return e
+}
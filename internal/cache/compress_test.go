@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skantay/lru-api/internal/cache/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompression(t *testing.T) {
+	c, err := New(10, time.Minute, &mocks.Logger{}, WithCompression(64))
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+
+	random := make([]byte, 1024)
+	rand.Read(random)
+
+	values := map[string]interface{}{
+		"string":       strings.Repeat("value ", 100),
+		"bytes":        bytes.Repeat([]byte{1, 2, 3}, 100),
+		"blob":         Blob{ContentType: "text/plain", Data: bytes.Repeat([]byte("text "), 100)},
+		"short":        "value",
+		"incompressed": random,
+		"number":       int64(1),
+	}
+
+	sub := c.Subscribe(Filter{}, 0)
+	defer sub.Close()
+
+	for key, value := range values {
+		assert.NoError(t, c.Put(ctx, key, value, 0))
+
+		event := <-sub.C
+		assert.Equal(t, value, event.Value, key)
+	}
+
+	for key, value := range values {
+		_, packed := c.values[key].value.(compressed)
+		assert.Equal(t, key == "string" || key == "bytes" || key == "blob", packed, key)
+
+		got, _, err := c.Get(ctx, key)
+		assert.NoError(t, err, key)
+		assert.Equal(t, value, got, key)
+	}
+
+	// Memory is counted compressed
+	uncompressed := uint64(0)
+	for key, value := range values {
+		uncompressed += SizeOf(key, value)
+	}
+
+	assert.Less(t, c.Stats().Bytes, uncompressed/2)
+
+	keys, got, err := c.GetAll(ctx)
+	assert.NoError(t, err)
+
+	for i, key := range keys {
+		assert.Equal(t, values[key], got[i], key)
+	}
+
+	assert.NoError(t, c.Scan(ctx, 10, func(entries []Entry) error {
+		for _, entry := range entries {
+			assert.Equal(t, values[entry.Key], entry.Value, entry.Key)
+		}
+
+		return nil
+	}))
+
+	assert.NoError(t, c.Update(ctx, "string", func(value interface{}, expiresAt time.Time, ok bool) (interface{}, time.Duration, bool) {
+		assert.Equal(t, values["string"], value)
+
+		return value.(string) + "more", KeepTTL, true
+	}))
+
+	value, err := c.Evict(ctx, "string")
+	assert.NoError(t, err)
+	assert.Equal(t, values["string"].(string)+"more", value)
+
+	// Loaded values are compressed too
+	assert.NoError(t, c.Load(ctx, []Entry{{Key: "loaded", Value: values["string"]}}))
+
+	_, packed := c.values["loaded"].value.(compressed)
+	assert.True(t, packed)
+}
@@ -0,0 +1,274 @@
+package cache
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Error for an event when a subscriber did not keep up with published events and has been disconnected
+var ErrSlowConsumer = errors.New("subscriber is too slow, events were dropped")
+
+// EventType describes what happened to a node/item
+type EventType string
+
+const (
+	// EventPut is published when a new node/item is created
+	EventPut EventType = "put"
+	// EventUpdate is published when an existing node/item is overwritten
+	EventUpdate EventType = "update"
+	// EventEvict is published when a node/item is evicted manually or by the LRU policy
+	EventEvict EventType = "evict"
+	// EventExpire is published when an expired node/item is found and removed
+	EventExpire EventType = "expire"
+	// EventFlush is published when the whole cache is flushed, Key is empty
+	EventFlush EventType = "flush"
+)
+
+const (
+	// Default amount of events kept in memory for Last-Event-ID style resumption
+	defaultBacklogSize = 1024
+	// Default amount of events buffered per subscriber
+	defaultSubscriberBuffer = 64
+)
+
+// Event is a change notification published by the cache.
+// IDs are assigned sequentially, starting from 1.
+type Event struct {
+	ID        uint64
+	Type      EventType
+	Key       string
+	Value     interface{}
+	ExpiresAt time.Time
+	Time      time.Time
+}
+
+// Filter selects events by key.
+// If Key is set, only events of this exact key are delivered,
+// otherwise events of keys starting with Prefix are delivered (empty Prefix matches everything).
+// Flush events are always delivered.
+type Filter struct {
+	Key    string
+	Prefix string
+}
+
+func (f Filter) match(e Event) bool {
+	if e.Type == EventFlush {
+		return true
+	}
+
+	if f.Key != "" {
+		return e.Key == f.Key
+	}
+
+	return strings.HasPrefix(e.Key, f.Prefix)
+}
+
+// Subscription is a stream of events matching a filter.
+//
+// Every subscription has a bounded buffer. When the buffer is full the subscriber is
+// considered slow: it is disconnected, C is closed and Err returns ErrSlowConsumer.
+// Publishers never block on subscribers, so a slow consumer cannot stall the cache.
+// A disconnected subscriber may resubscribe with the ID of the last event it has seen
+// and catch up from the backlog.
+type Subscription struct {
+	C <-chan Event
+
+	c      chan Event
+	filter Filter
+	hub    *hub
+	// from is the last event published before subscribing, later ones are delivered
+	from uint64
+
+	// guarded by hub.m
+	closed bool
+	err    error
+}
+
+// Err returns the reason the subscription was closed by the cache, if any.
+func (s *Subscription) Err() error {
+	s.hub.m.Lock()
+	defer s.hub.m.Unlock()
+
+	return s.err
+}
+
+// Close unsubscribes and closes C.
+func (s *Subscription) Close() {
+	s.hub.m.Lock()
+	defer s.hub.m.Unlock()
+
+	s.hub.remove(s, nil)
+}
+
+// hub fans out events to subscribers and keeps a short backlog of recent events.
+// Events are published with the cache locked and delivered once it is unlocked,
+// so their values are decompressed outside of the cache lock, once per event.
+type hub struct {
+	m           sync.Mutex
+	lastID      uint64
+	backlog     []Event
+	next        int
+	pending     []Event
+	subscribers map[*Subscription]struct{}
+	bufferSize  int
+
+	// delivering keeps pending events delivered in the order they were published
+	delivering sync.Mutex
+}
+
+func newHub(backlogSize, bufferSize int) *hub {
+	return &hub{
+		backlog:     make([]Event, 0, backlogSize),
+		subscribers: make(map[*Subscription]struct{}),
+		bufferSize:  bufferSize,
+	}
+}
+
+func (h *hub) publish(e Event) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	h.lastID++
+	e.ID = h.lastID
+
+	if cap(h.backlog) > 0 {
+		if len(h.backlog) < cap(h.backlog) {
+			h.backlog = append(h.backlog, e)
+		} else {
+			h.backlog[h.next] = e
+			h.next = (h.next + 1) % len(h.backlog)
+		}
+	}
+
+	if len(h.subscribers) > 0 {
+		h.pending = append(h.pending, e)
+	}
+}
+
+// deliver sends pending events to the subscribers, it must be called after the cache is unlocked
+func (h *hub) deliver() {
+	h.m.Lock()
+	empty := len(h.pending) == 0
+	h.m.Unlock()
+
+	// Whoever published the pending events delivers them
+	if empty {
+		return
+	}
+
+	h.delivering.Lock()
+	defer h.delivering.Unlock()
+
+	h.m.Lock()
+	pending := h.pending
+	h.pending = nil
+	h.m.Unlock()
+
+	for i := range pending {
+		pending[i] = unpacked(pending[i])
+	}
+
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	for _, e := range pending {
+		for s := range h.subscribers {
+			// Subscribed after the event was published, it was replayed if asked for
+			if e.ID <= s.from || !s.filter.match(e) {
+				continue
+			}
+
+			select {
+			case s.c <- e:
+			default:
+				h.remove(s, ErrSlowConsumer)
+			}
+		}
+	}
+}
+
+// subscribe registers a subscriber and replays backlog events with ID greater than since.
+// since == 0 means no replay.
+func (h *hub) subscribe(filter Filter, since uint64) *Subscription {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	var replay []Event
+
+	if since > 0 {
+		for i := range h.backlog {
+			e := h.backlog[(h.next+i)%len(h.backlog)]
+			if e.ID > since && filter.match(e) {
+				replay = append(replay, unpacked(e))
+			}
+		}
+	}
+
+	size := h.bufferSize
+	if len(replay) > size {
+		size = len(replay)
+	}
+
+	c := make(chan Event, size)
+	for _, e := range replay {
+		c <- e
+	}
+
+	s := &Subscription{
+		C:      c,
+		c:      c,
+		filter: filter,
+		hub:    h,
+		from:   h.lastID,
+	}
+
+	h.subscribers[s] = struct{}{}
+
+	return s
+}
+
+// remove must be called with h.m held
+func (h *hub) remove(s *Subscription, err error) {
+	if s.closed {
+		return
+	}
+
+	s.closed = true
+	s.err = err
+
+	delete(h.subscribers, s)
+	close(s.c)
+}
+
+// Subscribe returns a subscription to change events matching the filter.
+// If lastEventID is not zero, events published after it that are still in the backlog are replayed first.
+// The caller must Close the subscription when done.
+func (l *LRUCache) Subscribe(filter Filter, lastEventID uint64) *Subscription {
+	return l.events.subscribe(filter, lastEventID)
+}
+
+// LastEventID returns the ID of the last event published, 0 if there was none.
+// Events published later have greater IDs.
+func (l *LRUCache) LastEventID() uint64 {
+	l.events.m.Lock()
+	defer l.events.m.Unlock()
+
+	return l.events.lastID
+}
+
+func (l *LRUCache) publish(eventType EventType, node *node) {
+	e := Event{
+		Type: eventType,
+		Time: time.Now(),
+	}
+
+	if node != nil {
+		e.Key = node.key
+		e.Value = node.value
+		e.ExpiresAt = node.ttl
+	}
+
+	l.events.publish(e)
+}
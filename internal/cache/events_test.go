@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skantay/lru-api/internal/cache/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscribe(t *testing.T) {
+	cache, err := New(1, time.Second*60, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	sub := cache.Subscribe(Filter{}, 0)
+	defer sub.Close()
+
+	assert.NoError(t, cache.Put(context.Background(), "key 1", 1, 0))
+	assert.NoError(t, cache.Put(context.Background(), "key 1", 2, 0))
+	assert.NoError(t, cache.Put(context.Background(), "key 2", 3, 0))
+	_, err = cache.Evict(context.Background(), "key 2")
+	assert.NoError(t, err)
+	assert.NoError(t, cache.Put(context.Background(), "key 3", 4, 1))
+	time.Sleep(time.Millisecond)
+	_, _, err = cache.Get(context.Background(), "key 3")
+	assert.Equal(t, ErrKeyDoesNotExist, err)
+	assert.NoError(t, cache.EvictAll(context.Background()))
+
+	expected := []struct {
+		eventType EventType
+		key       string
+	}{
+		{EventPut, "key 1"},
+		{EventUpdate, "key 1"},
+		{EventEvict, "key 1"},
+		{EventPut, "key 2"},
+		{EventEvict, "key 2"},
+		{EventPut, "key 3"},
+		{EventExpire, "key 3"},
+		{EventFlush, ""},
+	}
+
+	for i, e := range expected {
+		event := <-sub.C
+		assert.Equal(t, uint64(i+1), event.ID)
+		assert.Equal(t, e.eventType, event.Type)
+		assert.Equal(t, e.key, event.Key)
+	}
+}
+
+func TestSubscribeFilterAndResume(t *testing.T) {
+	cache, err := New(10, time.Second*60, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	assert.NoError(t, cache.Put(context.Background(), "user:1", 1, 0))
+	assert.NoError(t, cache.Put(context.Background(), "order:1", 1, 0))
+	assert.NoError(t, cache.Put(context.Background(), "user:2", 2, 0))
+
+	sub := cache.Subscribe(Filter{Prefix: "user:"}, 1)
+	defer sub.Close()
+
+	event := <-sub.C
+	assert.Equal(t, uint64(3), event.ID)
+	assert.Equal(t, "user:2", event.Key)
+
+	assert.NoError(t, cache.Put(context.Background(), "order:2", 1, 0))
+	assert.NoError(t, cache.Put(context.Background(), "user:3", 3, 0))
+
+	event = <-sub.C
+	assert.Equal(t, "user:3", event.Key)
+}
+
+func TestSubscribeSlowConsumer(t *testing.T) {
+	cache, err := New(1, time.Second*60, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	sub := cache.Subscribe(Filter{Key: "key"}, 0)
+
+	for i := 0; i <= defaultSubscriberBuffer; i++ {
+		assert.NoError(t, cache.Put(context.Background(), "key", i, 0))
+	}
+
+	received := 0
+	for range sub.C {
+		received++
+	}
+
+	assert.Equal(t, defaultSubscriberBuffer, received)
+	assert.Equal(t, ErrSlowConsumer, sub.Err())
+
+	sub.Close()
+}
+
+func TestEventsInOrder(t *testing.T) {
+	cache, err := New(10, time.Second*60, &mocks.Logger{}, WithCompression(8))
+	assert.NoError(t, err)
+
+	const writers, writes = 8, 100
+
+	// Large enough to never be slow
+	cache.events.bufferSize = writers * writes
+
+	sub := cache.Subscribe(Filter{}, 0)
+	defer sub.Close()
+
+	done := make(chan struct{})
+
+	for w := range writers {
+		go func() {
+			defer func() { done <- struct{}{} }()
+
+			for i := range writes {
+				assert.NoError(t, cache.Put(context.Background(), string(rune('a'+w)), strings.Repeat("value ", i+2), 0))
+			}
+		}()
+	}
+
+	for range writers {
+		<-done
+	}
+
+	var lastID uint64
+
+	for range writers * writes {
+		e := <-sub.C
+		assert.Equal(t, lastID+1, e.ID)
+		assert.IsType(t, "", e.Value)
+
+		lastID = e.ID
+	}
+}
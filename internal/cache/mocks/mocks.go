@@ -1,8 +1,15 @@
 package mocks
 
+import "context"
+
 type Logger struct{}
 
 func (l *Logger) Debug(msg string, args ...any) {}
 func (l *Logger) Warn(msg string, args ...any)  {}
 func (l *Logger) Info(msg string, args ...any)  {}
 func (l *Logger) Error(msg string, args ...any) {}
+
+func (l *Logger) DebugContext(ctx context.Context, msg string, args ...any) {}
+func (l *Logger) WarnContext(ctx context.Context, msg string, args ...any)  {}
+func (l *Logger) InfoContext(ctx context.Context, msg string, args ...any)  {}
+func (l *Logger) ErrorContext(ctx context.Context, msg string, args ...any) {}
@@ -0,0 +1,29 @@
+package cache
+
+import "context"
+
+// Recorder is told about writes, e.g. to keep an audit log of them
+type Recorder interface {
+	// RecordWrite учет записи в кэш: typ - EventPut, EventEvict или EventFlush, err - ошибка записи
+	RecordWrite(ctx context.Context, typ EventType, key string, err error) error
+}
+
+// WithRecorder reports puts, updates, evictions and flushes to recorder once the cache is unlocked.
+// Updates are reported as EventPut. Expirations and evictions by capacity are not reported.
+func WithRecorder(recorder Recorder) Option {
+	return func(l *LRUCache) {
+		l.recorder = recorder
+	}
+}
+
+// record reports a write to the recorder.
+// It must be called with l.m released, recording may write a file.
+func (l *LRUCache) record(ctx context.Context, typ EventType, key string, err error) {
+	if l.recorder == nil {
+		return
+	}
+
+	if err := l.recorder.RecordWrite(ctx, typ, key, err); err != nil {
+		l.log.ErrorContext(ctx, "write not recorded", "type", typ, "key", key, "error", err.Error())
+	}
+}
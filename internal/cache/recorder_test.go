@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/skantay/lru-api/internal/cache/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+type write struct {
+	typ EventType
+	key string
+	err error
+}
+
+// writeRecorder keeps the writes reported by the cache, reading it back as the cache is unlocked
+type writeRecorder struct {
+	cache  *LRUCache
+	writes []write
+}
+
+func (r *writeRecorder) RecordWrite(ctx context.Context, typ EventType, key string, err error) error {
+	r.cache.Stats()
+	r.writes = append(r.writes, write{typ: typ, key: key, err: err})
+
+	return nil
+}
+
+func TestRecorder(t *testing.T) {
+	recorder := &writeRecorder{}
+
+	cache, err := New(10, time.Minute, &mocks.Logger{}, WithRecorder(recorder))
+	assert.NoError(t, err)
+
+	recorder.cache = cache
+
+	ctx := context.Background()
+
+	assert.NoError(t, cache.Put(ctx, "a", 1, 0))
+
+	// Reads through Update are not reported
+	assert.NoError(t, cache.Update(ctx, "a", func(value interface{}, _ time.Time, _ bool) (interface{}, time.Duration, bool) {
+		return value, 0, false
+	}))
+	assert.NoError(t, cache.Update(ctx, "a", func(_ interface{}, _ time.Time, _ bool) (interface{}, time.Duration, bool) {
+		return 2, KeepTTL, true
+	}))
+
+	_, err = cache.Evict(ctx, "a")
+	assert.NoError(t, err)
+	_, err = cache.Evict(ctx, "a")
+	assert.ErrorIs(t, err, ErrKeyDoesNotExist)
+
+	assert.NoError(t, cache.EvictAll(ctx))
+
+	assert.Equal(t, []write{
+		{typ: EventPut, key: "a"},
+		{typ: EventPut, key: "a"},
+		{typ: EventEvict, key: "a"},
+		{typ: EventEvict, key: "a", err: ErrKeyDoesNotExist},
+		{typ: EventFlush},
+	}, recorder.writes)
+}
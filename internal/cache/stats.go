@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Operation names reported to the observer set by WithObserver
+const (
+	opPut      = "put"
+	opUpdate   = "update"
+	opGet      = "get"
+	opGetAll   = "get_all"
+	opEvict    = "evict"
+	opEvictAll = "evict_all"
+	opScan     = "scan"
+	opLoad     = "load"
+)
+
+// EvictionReason tells why a node/item left the cache
+type EvictionReason string
+
+const (
+	// EvictionCapacity the least recently used node/item was evicted to make room for a new one
+	EvictionCapacity EvictionReason = "capacity"
+	// EvictionExpired the node/item was found expired
+	EvictionExpired EvictionReason = "expired"
+	// EvictionDeleted the node/item was evicted by Evict
+	EvictionDeleted EvictionReason = "deleted"
+	// EvictionFlushed the node/item was evicted by EvictAll
+	EvictionFlushed EvictionReason = "flushed"
+)
+
+// Stats is a snapshot of cache counters and size
+type Stats struct {
+	// Hits and Misses count lookups by Get
+	Hits   uint64
+	Misses uint64
+	// Evictions since the cache was created, by reason
+	Evictions map[EvictionReason]uint64
+	Len       uint
+	Cap       uint
+	// Bytes is an estimate of the memory used by keys and values
+	Bytes uint64
+}
+
+type stats struct {
+	hits, misses uint64
+	evictions    map[EvictionReason]uint64
+}
+
+func newStats() stats {
+	return stats{
+		evictions: map[EvictionReason]uint64{
+			EvictionCapacity: 0,
+			EvictionExpired:  0,
+			EvictionDeleted:  0,
+			EvictionFlushed:  0,
+		},
+	}
+}
+
+// Stats returns current counters of the cache
+func (l *LRUCache) Stats() Stats {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	evictions := make(map[EvictionReason]uint64, len(l.stats.evictions))
+	for reason, count := range l.stats.evictions {
+		evictions[reason] = count
+	}
+
+	return Stats{
+		Hits:      l.stats.hits,
+		Misses:    l.stats.misses,
+		Evictions: evictions,
+		Len:       l.len,
+		Cap:       l.cap,
+		Bytes:     l.bytes,
+	}
+}
+
+func (l *LRUCache) observeSince(operation string, start time.Time) {
+	if l.observe != nil {
+		l.observe(operation, time.Since(start))
+	}
+}
+
+// SizeOf estimates the memory used by a node/item.
+// Strings, byte slices and blobs are counted by length, numbers by their size,
+// other values by the length of their JSON encoding.
+func SizeOf(key string, value interface{}) uint64 {
+	size := uint64(len(key))
+
+	switch v := value.(type) {
+	case nil:
+	case string:
+		size += uint64(len(v))
+	case []byte:
+		size += uint64(len(v))
+	case Blob:
+		size += uint64(len(v.ContentType) + len(v.Data))
+	case compressed:
+		size += uint64(len(v.contentType) + len(v.data))
+	case bool, int8, uint8:
+		size++
+	case int16, uint16:
+		size += 2
+	case int32, uint32, float32:
+		size += 4
+	case int, int64, uint, uint64, float64:
+		size += 8
+	default:
+		if data, err := json.Marshal(v); err == nil {
+			size += uint64(len(data))
+		}
+	}
+
+	return size
+}
@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/skantay/lru-api/internal/cache/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	var (
+		mu         sync.Mutex
+		operations []string
+	)
+
+	c, err := New(2, time.Minute, &mocks.Logger{}, WithObserver(func(operation string, duration time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		operations = append(operations, operation)
+	}))
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+
+	assert.NoError(t, c.Put(ctx, "key 1", "value 1", 0))
+	assert.NoError(t, c.Put(ctx, "key 2", 2, time.Millisecond))
+
+	stats := c.Stats()
+	assert.Equal(t, uint(2), stats.Len)
+	assert.Equal(t, uint(2), stats.Cap)
+	assert.Equal(t, uint64(len("key 1value 1")+len("key 2")+8), stats.Bytes)
+
+	time.Sleep(2 * time.Millisecond)
+
+	_, _, err = c.Get(ctx, "key 1")
+	assert.NoError(t, err)
+
+	_, _, err = c.Get(ctx, "key 2")
+	assert.ErrorIs(t, err, ErrKeyDoesNotExist)
+
+	_, _, err = c.Get(ctx, "key 3")
+	assert.ErrorIs(t, err, ErrKeyDoesNotExist)
+
+	assert.NoError(t, c.Put(ctx, "key 3", "value 3", 0))
+	assert.NoError(t, c.Put(ctx, "key 4", "value 4", 0))
+
+	_, err = c.Evict(ctx, "key 4")
+	assert.NoError(t, err)
+
+	assert.NoError(t, c.Put(ctx, "key 5", "value 5", 0))
+	assert.NoError(t, c.EvictAll(ctx))
+
+	stats = c.Stats()
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, uint64(2), stats.Misses)
+	assert.Equal(t, map[EvictionReason]uint64{
+		EvictionCapacity: 1,
+		EvictionExpired:  1,
+		EvictionDeleted:  1,
+		EvictionFlushed:  2,
+	}, stats.Evictions)
+	assert.Equal(t, uint(0), stats.Len)
+	assert.Equal(t, uint64(0), stats.Bytes)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	assert.Equal(t, []string{opPut, opPut, opGet, opGet, opGet, opPut, opPut, opEvict, opPut, opEvictAll}, operations)
+}
+
+func TestSizeOfBlob(t *testing.T) {
+	blob := Blob{ContentType: "image/png", Data: make([]byte, 1024)}
+
+	assert.Equal(t, uint64(len("k")+len("image/png")+1024), SizeOf("k", blob))
+}
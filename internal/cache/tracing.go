@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/skantay/lru-api/internal/cache"
+
+// Attributes of cache spans
+const (
+	attrKey      = attribute.Key("cache.key")
+	attrHit      = attribute.Key("cache.hit")
+	attrLockWait = attribute.Key("cache.lock_wait_ms")
+)
+
+// WithTracerProvider records a span for every operation with the key, whether it was found,
+// and the time spent waiting for the lock. Spans are children of the span in the operation's context.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(l *LRUCache) {
+		l.tracer = provider.Tracer(tracerName)
+	}
+}
+
+func (l *LRUCache) startSpan(ctx context.Context, name, key string) trace.Span {
+	opts := []trace.SpanStartOption{trace.WithSpanKind(trace.SpanKindInternal)}
+
+	if key != "" {
+		opts = append(opts, trace.WithAttributes(attrKey.String(key)))
+	}
+
+	_, span := l.tracer.Start(ctx, name, opts...)
+
+	return span
+}
+
+// unlock releases l.m and delivers the events published while it was held
+func (l *LRUCache) unlock() {
+	l.m.Unlock()
+	l.events.deliver()
+}
+
+// lock acquires l.m and records how long it waited for it
+func (l *LRUCache) lock(span trace.Span) {
+	start := time.Now()
+
+	l.m.Lock()
+
+	if span.IsRecording() {
+		span.SetAttributes(attrLockWait.Float64(float64(time.Since(start)) / float64(time.Millisecond)))
+	}
+}
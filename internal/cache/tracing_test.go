@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/skantay/lru-api/internal/cache/mocks"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	c, err := New(10, time.Minute, &mocks.Logger{}, WithTracerProvider(provider))
+	assert.NoError(t, err)
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "request")
+
+	assert.NoError(t, c.Put(ctx, "key 1", "value 1", 0))
+
+	_, _, err = c.Get(ctx, "key 1")
+	assert.NoError(t, err)
+
+	_, _, err = c.Get(ctx, "key 2")
+	assert.ErrorIs(t, err, ErrKeyDoesNotExist)
+
+	parent.End()
+
+	spans := recorder.Ended()
+	if !assert.Len(t, spans, 4) {
+		return
+	}
+
+	tests := []struct {
+		name   string
+		key    string
+		hasHit bool
+		hit    bool
+	}{
+		{name: "LRUCache.Put", key: "key 1"},
+		{name: "LRUCache.Get", key: "key 1", hasHit: true, hit: true},
+		{name: "LRUCache.Get", key: "key 2", hasHit: true, hit: false},
+	}
+
+	for i, test := range tests {
+		span := spans[i]
+		attributes := attribute.NewSet(span.Attributes()...)
+
+		assert.Equal(t, test.name, span.Name())
+		assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+
+		key, _ := attributes.Value(attrKey)
+		assert.Equal(t, test.key, key.AsString())
+
+		_, ok := attributes.Value(attrLockWait)
+		assert.True(t, ok)
+
+		hit, ok := attributes.Value(attrHit)
+		assert.Equal(t, test.hasHit, ok)
+		assert.Equal(t, test.hit, hit.AsBool())
+	}
+}
@@ -0,0 +1,125 @@
+// Package glob implements Redis style glob-style pattern matching of keys.
+// Unlike path.Match, '/' is not treated specially, which suits arbitrary cache keys.
+//
+// Supported patterns:
+//
+//   - matches any sequence of characters, including an empty one
+//     ?      matches a single character
+//     [abc]  matches one character from the set, [^abc] or [!abc] negates it, [a-z] is a range
+//     \x     matches x literally
+package glob
+
+// Match reports whether key matches the pattern.
+// A malformed pattern, e.g. an unclosed bracket, does not match anything.
+func Match(pattern, key string) bool {
+	p, k := []rune(pattern), []rune(key)
+
+	// Position to backtrack to after the last '*'
+	star, mark := -1, 0
+
+	pi, ki := 0, 0
+
+	for ki < len(k) {
+		if pi < len(p) {
+			switch p[pi] {
+			case '*':
+				star, mark = pi, ki
+				pi++
+
+				continue
+			case '?':
+				pi++
+				ki++
+
+				continue
+			case '[':
+				matched, next, ok := matchClass(p, pi, k[ki])
+				if !ok {
+					return false
+				}
+
+				if matched {
+					pi = next
+					ki++
+
+					continue
+				}
+			case '\\':
+				if pi+1 < len(p) && p[pi+1] == k[ki] {
+					pi += 2
+					ki++
+
+					continue
+				}
+			default:
+				if p[pi] == k[ki] {
+					pi++
+					ki++
+
+					continue
+				}
+			}
+		}
+
+		if star == -1 {
+			return false
+		}
+
+		mark++
+		pi, ki = star+1, mark
+	}
+
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+
+	return pi == len(p)
+}
+
+// matchClass matches c against a bracket expression starting at p[start].
+// It returns whether c matched, the index right after the expression and false if the expression is malformed.
+func matchClass(p []rune, start int, c rune) (matched bool, next int, ok bool) {
+	i := start + 1
+
+	negate := i < len(p) && (p[i] == '^' || p[i] == '!')
+	if negate {
+		i++
+	}
+
+	first := true
+
+	for ; i < len(p); i++ {
+		if p[i] == ']' && !first {
+			return matched != negate, i + 1, true
+		}
+
+		first = false
+
+		lo := p[i]
+		if lo == '\\' && i+1 < len(p) {
+			i++
+			lo = p[i]
+		}
+
+		hi := lo
+		if i+2 < len(p) && p[i+1] == '-' && p[i+2] != ']' {
+			hi = p[i+2]
+			if hi == '\\' && i+3 < len(p) {
+				i++
+				hi = p[i+2]
+			}
+
+			i += 2
+		}
+
+		if lo > hi {
+			lo, hi = hi, lo
+		}
+
+		if lo <= c && c <= hi {
+			matched = true
+		}
+	}
+
+	return false, 0, false
+}
@@ -0,0 +1,40 @@
+package glob
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		key      string
+		expected bool
+	}{
+		{"*", "", true},
+		{"*", "user/1", true},
+		{"user:*", "user:1", true},
+		{"user:*", "order:1", false},
+		{"user/*/name", "user/1/name", true},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-c]llo", "hbllo", true},
+		{"h[a-c]llo", "hdllo", false},
+		{"h\\*llo", "h*llo", true},
+		{"h\\*llo", "hello", false},
+		{"*a*b", "xxaxxb", true},
+		{"*a*b", "xxaxxbx", false},
+		{"h[ello", "hello", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.pattern+" "+test.key, func(t *testing.T) {
+			assert.Equal(t, test.expected, Match(test.pattern, test.key))
+		})
+	}
+}
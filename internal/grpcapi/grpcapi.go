@@ -0,0 +1,350 @@
+// Package grpcapi provides a gRPC server for interacting with an LRU cache.
+// The service is defined in proto/lru/v1/lru.proto.
+package grpcapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/skantay/lru-api/internal/audit"
+	"github.com/skantay/lru-api/internal/cache"
+	lruv1 "github.com/skantay/lru-api/pkg/pb/lru/v1"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Cache is the LRU cache served over gRPC
+type Cache interface {
+	Put(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+	Get(ctx context.Context, key string) (value interface{}, expiresAt time.Time, err error)
+	GetAll(ctx context.Context) (keys []string, values []interface{}, err error)
+	Evict(ctx context.Context, key string) (value interface{}, err error)
+	EvictAll(ctx context.Context) error
+}
+
+// watcher is implemented by caches that publish change events, Watch is unimplemented otherwise
+type watcher interface {
+	Subscribe(filter cache.Filter, lastEventID uint64) *cache.Subscription
+}
+
+// Server serves LRUService
+type Server struct {
+	lruv1.UnimplementedLRUServiceServer
+
+	cache   Cache
+	watcher watcher
+	log     *slog.Logger
+
+	server    *grpc.Server
+	closing   chan struct{}
+	closeOnce sync.Once
+}
+
+// New creates a new gRPC server with the provided LRU cache and logger.
+func New(c Cache, log *slog.Logger, opts ...grpc.ServerOption) *Server {
+	s := &Server{
+		cache:   c,
+		log:     log,
+		server:  grpc.NewServer(append([]grpc.ServerOption{grpc.ChainUnaryInterceptor(withActor)}, opts...)...),
+		closing: make(chan struct{}),
+	}
+
+	if w, ok := c.(watcher); ok {
+		s.watcher = w
+	}
+
+	lruv1.RegisterLRUServiceServer(s.server, s)
+
+	return s
+}
+
+// withActor lets the cache audit writes as the client's, there is no principal to record
+func withActor(ctx context.Context, request any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if p, ok := peer.FromContext(ctx); ok {
+		ctx = audit.WithActor(ctx, "grpc", p.Addr.String())
+	}
+
+	return handler(ctx, request)
+}
+
+// ListenAndServe listens on addr and serves requests until Shutdown.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(l)
+}
+
+// Serve serves requests accepted on l until Shutdown.
+// It returns nil after Shutdown, like grpc.Server.Serve.
+func (s *Server) Serve(l net.Listener) error {
+	return s.server.Serve(l)
+}
+
+// Shutdown ends watch streams and stops the server gracefully.
+// Requests still running when ctx is done are cancelled.
+// It may be called more than once.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.closeOnce.Do(func() { close(s.closing) })
+
+	done := make(chan struct{})
+
+	go func() {
+		s.server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+
+		return ctx.Err()
+	}
+}
+
+// Get returns a node/item by key
+func (s *Server) Get(ctx context.Context, request *lruv1.GetRequest) (*lruv1.GetResponse, error) {
+	value, expiresAt, err := s.cache.Get(ctx, request.GetKey())
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+
+	entry, err := newEntry(request.GetKey(), value, expiresAt)
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+
+	return &lruv1.GetResponse{Entry: entry}, nil
+}
+
+// Put inserts or updates a node/item
+func (s *Server) Put(ctx context.Context, request *lruv1.PutRequest) (*lruv1.PutResponse, error) {
+	if request.GetKey() == "" || request.GetValue() == nil {
+		return nil, status.Error(codes.InvalidArgument, "key and value are required")
+	}
+
+	// Null values are rejected like in the HTTP API
+	if _, ok := request.GetValue().GetKind().(*structpb.Value_NullValue); ok || request.GetValue().GetKind() == nil {
+		return nil, status.Error(codes.InvalidArgument, "value must not be null")
+	}
+
+	var ttl time.Duration
+
+	if request.GetTtl() != nil {
+		if err := request.GetTtl().CheckValid(); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		ttl = request.GetTtl().AsDuration()
+		if ttl < 0 {
+			return nil, status.Error(codes.InvalidArgument, "ttl must not be negative")
+		}
+	}
+
+	if err := s.cache.Put(ctx, request.GetKey(), request.GetValue().AsInterface(), ttl); err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+
+	return &lruv1.PutResponse{}, nil
+}
+
+// Evict deletes a node/item by key
+func (s *Server) Evict(ctx context.Context, request *lruv1.EvictRequest) (*lruv1.EvictResponse, error) {
+	value, err := s.cache.Evict(ctx, request.GetKey())
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+
+	pbValue, err := toValue(value)
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+
+	return &lruv1.EvictResponse{Value: pbValue}, nil
+}
+
+// EvictAll flushes the cache
+func (s *Server) EvictAll(ctx context.Context, _ *lruv1.EvictAllRequest) (*lruv1.EvictAllResponse, error) {
+	if err := s.cache.EvictAll(ctx); err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+
+	return &lruv1.EvictAllResponse{}, nil
+}
+
+// GetAll streams every node/item of the cache.
+// ILRUCache.GetAll does not report expiration times, so expires_at is not set.
+func (s *Server) GetAll(_ *lruv1.GetAllRequest, stream grpc.ServerStreamingServer[lruv1.GetAllResponse]) error {
+	keys, values, err := s.cache.GetAll(stream.Context())
+	if err != nil {
+		return s.toStatus(stream.Context(), err)
+	}
+
+	for i := range keys {
+		pbValue, err := toValue(values[i])
+		if err != nil {
+			return s.toStatus(stream.Context(), err)
+		}
+
+		response := &lruv1.GetAllResponse{
+			Entry: &lruv1.Entry{
+				Key:   keys[i],
+				Value: pbValue,
+			},
+		}
+
+		if err := stream.Send(response); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Watch streams change events until the client cancels, the subscriber falls behind or the server shuts down
+func (s *Server) Watch(request *lruv1.WatchRequest, stream grpc.ServerStreamingServer[lruv1.WatchResponse]) error {
+	if s.watcher == nil {
+		return status.Error(codes.Unimplemented, "cache does not publish change events")
+	}
+
+	sub := s.watcher.Subscribe(
+		cache.Filter{
+			Key:    request.GetKey(),
+			Prefix: request.GetPrefix(),
+		},
+		request.GetLastEventId(),
+	)
+	defer sub.Close()
+
+	// Headers tell the client that the subscription is active
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return s.toStatus(stream.Context(), stream.Context().Err())
+		case <-s.closing:
+			return status.Error(codes.Unavailable, "server is shutting down")
+		case event, ok := <-sub.C:
+			if !ok {
+				if err := sub.Err(); err != nil {
+					return s.toStatus(stream.Context(), err)
+				}
+
+				return nil
+			}
+
+			pbEvent, err := newEvent(event)
+			if err != nil {
+				return s.toStatus(stream.Context(), err)
+			}
+
+			if err := stream.Send(&lruv1.WatchResponse{Event: pbEvent}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// toStatus maps cache errors to gRPC status codes.
+// Other errors are logged and answered with a generic message, as the HTTP API does.
+func (s *Server) toStatus(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, cache.ErrKeyDoesNotExist):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, cache.ErrSlowConsumer):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+
+	s.log.ErrorContext(ctx, err.Error())
+
+	return status.Error(codes.Internal, "internal error")
+}
+
+func newEntry(key string, value interface{}, expiresAt time.Time) (*lruv1.Entry, error) {
+	pbValue, err := toValue(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &lruv1.Entry{
+		Key:       key,
+		Value:     pbValue,
+		ExpiresAt: timestamppb.New(expiresAt),
+	}, nil
+}
+
+var eventTypes = map[cache.EventType]lruv1.Event_Type{
+	cache.EventPut:    lruv1.Event_TYPE_PUT,
+	cache.EventUpdate: lruv1.Event_TYPE_UPDATE,
+	cache.EventEvict:  lruv1.Event_TYPE_EVICT,
+	cache.EventExpire: lruv1.Event_TYPE_EXPIRE,
+	cache.EventFlush:  lruv1.Event_TYPE_FLUSH,
+}
+
+func newEvent(event cache.Event) (*lruv1.Event, error) {
+	pbEvent := &lruv1.Event{
+		Id:   event.ID,
+		Type: eventTypes[event.Type],
+		Key:  event.Key,
+		Time: timestamppb.New(event.Time),
+	}
+
+	if event.Value != nil {
+		pbValue, err := toValue(event.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		pbEvent.Value = pbValue
+	}
+
+	if !event.ExpiresAt.IsZero() {
+		pbEvent.ExpiresAt = timestamppb.New(event.ExpiresAt)
+	}
+
+	return pbEvent, nil
+}
+
+// toValue converts a cached value to google.protobuf.Value.
+// Values structpb does not know, e.g. structs stored by the protocol listeners, are converted through JSON.
+func toValue(value interface{}) (*structpb.Value, error) {
+	pbValue, err := structpb.NewValue(value)
+	if err == nil {
+		return pbValue, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var decoded interface{}
+
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, err
+	}
+
+	return structpb.NewValue(decoded)
+}
@@ -0,0 +1,161 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/skantay/lru-api/internal/cache"
+	"github.com/skantay/lru-api/internal/cache/mocks"
+	lruv1 "github.com/skantay/lru-api/pkg/pb/lru/v1"
+	"github.com/stretchr/testify/assert"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func newTestClient(t *testing.T) lruv1.LRUServiceClient {
+	c, err := cache.New(10, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	server := New(c, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	l := bufconn.Listen(1 << 20)
+
+	go server.Serve(l)
+
+	t.Cleanup(func() {
+		server.Shutdown(context.Background())
+	})
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return l.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	assert.NoError(t, err)
+
+	t.Cleanup(func() {
+		conn.Close()
+	})
+
+	return lruv1.NewLRUServiceClient(conn)
+}
+
+func TestGetPutEvict(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	_, err := client.Get(ctx, &lruv1.GetRequest{Key: "key 1"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.Put(ctx, &lruv1.PutRequest{Key: "key 1"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.Put(ctx, &lruv1.PutRequest{Key: "key 1", Value: structpb.NewNullValue()})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.Put(ctx, &lruv1.PutRequest{
+		Key:   "key 1",
+		Value: structpb.NewStringValue("value 1"),
+		Ttl:   durationpb.New(time.Hour),
+	})
+	assert.NoError(t, err)
+
+	response, err := client.Get(ctx, &lruv1.GetRequest{Key: "key 1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "value 1", response.GetEntry().GetValue().GetStringValue())
+	assert.WithinDuration(t, time.Now().Add(time.Hour), response.GetEntry().GetExpiresAt().AsTime(), time.Second)
+
+	evicted, err := client.Evict(ctx, &lruv1.EvictRequest{Key: "key 1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "value 1", evicted.GetValue().GetStringValue())
+
+	_, err = client.Evict(ctx, &lruv1.EvictRequest{Key: "key 1"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestGetAllAndWatch(t *testing.T) {
+	client := newTestClient(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watch, err := client.Watch(ctx, &lruv1.WatchRequest{Filter: &lruv1.WatchRequest_Prefix{Prefix: "user:"}})
+	assert.NoError(t, err)
+
+	// Wait for the subscription
+	_, err = watch.Header()
+	assert.NoError(t, err)
+
+	for _, key := range []string{"user:1", "order:1", "user:2"} {
+		_, err := client.Put(ctx, &lruv1.PutRequest{Key: key, Value: structpb.NewNumberValue(1)})
+		assert.NoError(t, err)
+	}
+
+	stream, err := client.GetAll(ctx, &lruv1.GetAllRequest{})
+	assert.NoError(t, err)
+
+	var keys []string
+
+	for {
+		response, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+
+		assert.NoError(t, err)
+
+		keys = append(keys, response.GetEntry().GetKey())
+	}
+
+	assert.ElementsMatch(t, []string{"user:1", "order:1", "user:2"}, keys)
+
+	for _, key := range []string{"user:1", "user:2"} {
+		response, err := watch.Recv()
+		assert.NoError(t, err)
+		assert.Equal(t, lruv1.Event_TYPE_PUT, response.GetEvent().GetType())
+		assert.Equal(t, key, response.GetEvent().GetKey())
+	}
+}
+
+func TestShutdownTwice(t *testing.T) {
+	c, err := cache.New(10, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	server := New(c, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	l := bufconn.Listen(1 << 20)
+
+	go server.Serve(l)
+
+	assert.NoError(t, server.Shutdown(context.Background()))
+	assert.NoError(t, server.Shutdown(context.Background()))
+}
+
+func TestToStatus(t *testing.T) {
+	c, err := cache.New(10, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	server := New(c, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	// Details of internal errors are only logged
+	st := status.Convert(server.toStatus(context.Background(), errors.New("open /var/lib/lru: permission denied")))
+	assert.Equal(t, codes.Internal, st.Code())
+	assert.Equal(t, "internal error", st.Message())
+
+	st = status.Convert(server.toStatus(context.Background(), cache.ErrKeyDoesNotExist))
+	assert.Equal(t, codes.NotFound, st.Code())
+	assert.Equal(t, cache.ErrKeyDoesNotExist.Error(), st.Message())
+}
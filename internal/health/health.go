@@ -0,0 +1,215 @@
+// Package health serves liveness and readiness probes.
+// Components register checks, the probes run them and report the results as JSON.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// How long a single check may run before it is reported as failed
+const defaultCheckTimeout = time.Second
+
+const (
+	statusOK   = "ok"
+	statusFail = "fail"
+)
+
+var (
+	// ErrDraining is reported by readiness once Drain was called
+	ErrDraining = errors.New("server is draining")
+
+	// ErrNotReady is reported by a Flag that is not set
+	ErrNotReady = errors.New("not ready")
+)
+
+// Check returns nil if the component is healthy
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker runs liveness and readiness checks
+type Checker struct {
+	mu        sync.RWMutex
+	liveness  []namedCheck
+	readiness []namedCheck
+
+	draining atomic.Bool
+	timeout  time.Duration
+}
+
+// New creates a checker without checks, it reports ready until Drain is called.
+func New() *Checker {
+	c := &Checker{timeout: defaultCheckTimeout}
+
+	c.AddReadinessCheck("draining", func(context.Context) error {
+		if c.draining.Load() {
+			return ErrDraining
+		}
+
+		return nil
+	})
+
+	return c
+}
+
+// AddLivenessCheck adds a check to /livez, the process is restarted when it fails
+func (c *Checker) AddLivenessCheck(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.liveness = append(c.liveness, namedCheck{name: name, check: check})
+}
+
+// AddReadinessCheck adds a check to /readyz, traffic is not routed to the process while it fails
+func (c *Checker) AddReadinessCheck(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.readiness = append(c.readiness, namedCheck{name: name, check: check})
+}
+
+// Drain makes readiness fail, so load balancers stop sending traffic before the servers shut down.
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// Flag is a readiness condition set by its owner, e.g. once a listener is bound.
+// Its zero value is not set.
+type Flag struct {
+	set atomic.Bool
+}
+
+// Set marks the condition as met
+func (f *Flag) Set() {
+	f.set.Store(true)
+}
+
+// Unset marks the condition as not met
+func (f *Flag) Unset() {
+	f.set.Store(false)
+}
+
+// Check is a Check reporting ErrNotReady while the flag is not set
+func (f *Flag) Check(context.Context) error {
+	if !f.set.Load() {
+		return ErrNotReady
+	}
+
+	return nil
+}
+
+type checkResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type response struct {
+	Status string        `json:"status"`
+	Checks []checkResult `json:"checks"`
+}
+
+// Healthz reports that the process is up and serving HTTP, it runs no checks
+func (c *Checker) Healthz(w http.ResponseWriter, r *http.Request) {
+	writeResponse(w, response{Status: statusOK, Checks: []checkResult{}})
+}
+
+// Livez runs the liveness checks
+func (c *Checker) Livez(w http.ResponseWriter, r *http.Request) {
+	c.mu.RLock()
+	checks := c.liveness
+	c.mu.RUnlock()
+
+	writeResponse(w, c.run(r.Context(), checks))
+}
+
+// Readyz runs the readiness checks
+func (c *Checker) Readyz(w http.ResponseWriter, r *http.Request) {
+	c.mu.RLock()
+	checks := c.readiness
+	c.mu.RUnlock()
+
+	writeResponse(w, c.run(r.Context(), checks))
+}
+
+// run runs the checks concurrently, a check that does not return in time fails
+func (c *Checker) run(ctx context.Context, checks []namedCheck) response {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	results := make([]checkResult, len(checks))
+
+	var wg sync.WaitGroup
+
+	for i, check := range checks {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			results[i] = checkResult{Name: check.name, Status: statusOK}
+
+			if err := runCheck(ctx, check.check); err != nil {
+				results[i].Status = statusFail
+				results[i].Error = err.Error()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	status := statusOK
+
+	for _, result := range results {
+		if result.Status != statusOK {
+			status = statusFail
+		}
+	}
+
+	return response{Status: status, Checks: results}
+}
+
+// runCheck returns when the check returns or ctx is done, a stuck check is left behind
+func runCheck(ctx context.Context, check Check) error {
+	done := make(chan error, 1)
+
+	go func() {
+		done <- check(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func writeResponse(w http.ResponseWriter, response response) {
+	data, err := json.Marshal(response)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	if response.Status != statusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	w.Write(data)
+}
@@ -0,0 +1,90 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func probe(t *testing.T, handler http.HandlerFunc) (int, response) {
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var body response
+
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	return w.Code, body
+}
+
+func TestReadiness(t *testing.T) {
+	c := New()
+
+	var listener Flag
+
+	c.AddReadinessCheck("http", listener.Check)
+
+	code, body := probe(t, c.Readyz)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, response{
+		Status: statusFail,
+		Checks: []checkResult{
+			{Name: "draining", Status: statusOK},
+			{Name: "http", Status: statusFail, Error: ErrNotReady.Error()},
+		},
+	}, body)
+
+	listener.Set()
+
+	code, body = probe(t, c.Readyz)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, statusOK, body.Status)
+
+	c.Drain()
+
+	code, body = probe(t, c.Readyz)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, checkResult{Name: "draining", Status: statusFail, Error: ErrDraining.Error()}, body.Checks[0])
+
+	// Draining does not affect liveness
+	code, _ = probe(t, c.Livez)
+	assert.Equal(t, http.StatusOK, code)
+
+	code, body = probe(t, c.Healthz)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, response{Status: statusOK, Checks: []checkResult{}}, body)
+}
+
+func TestLiveness(t *testing.T) {
+	c := New()
+	c.timeout = 10 * time.Millisecond
+
+	stuck := make(chan struct{})
+	defer close(stuck)
+
+	c.AddLivenessCheck("ok", func(context.Context) error { return nil })
+	c.AddLivenessCheck("failing", func(context.Context) error { return errors.New("broken") })
+	c.AddLivenessCheck("stuck", func(context.Context) error {
+		<-stuck
+
+		return nil
+	})
+
+	code, body := probe(t, c.Livez)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, response{
+		Status: statusFail,
+		Checks: []checkResult{
+			{Name: "ok", Status: statusOK},
+			{Name: "failing", Status: statusFail, Error: "broken"},
+			{Name: "stuck", Status: statusFail, Error: context.DeadlineExceeded.Error()},
+		},
+	}, body)
+}
@@ -0,0 +1,128 @@
+// Package httpcache decides the HTTP caching headers of nodes/items by the namespace of their keys.
+// A namespace is a key prefix, e.g. "user:", the longest matching prefix wins.
+package httpcache
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Mode tells which caches may store responses
+type Mode string
+
+const (
+	// ModePrivate lets only caches of the client store responses, e.g. browsers
+	ModePrivate Mode = "private"
+	// ModePublic lets shared caches store responses too, e.g. CDNs
+	ModePublic Mode = "public"
+	// ModeNoStore forbids storing responses, conditional requests still work
+	ModeNoStore Mode = "no-store"
+)
+
+// Rule decides the caching headers of keys with Prefix
+type Rule struct {
+	Prefix string
+	Mode   Mode
+	// MaxAge caps max-age, which is the remaining TTL of the node/item otherwise. Zero does not cap it.
+	MaxAge time.Duration
+}
+
+// Policy holds the rules of namespaces
+type Policy struct {
+	def   Rule
+	rules []Rule
+}
+
+// New creates a policy, keys matching none of the rules get def
+func New(def Rule, rules []Rule) *Policy {
+	sorted := append([]Rule(nil), rules...)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i].Prefix) > len(sorted[j].Prefix)
+	})
+
+	return &Policy{def: def, rules: sorted}
+}
+
+// Rule returns the rule of the longest prefix of key
+func (p *Policy) Rule(key string) Rule {
+	for _, rule := range p.rules {
+		if strings.HasPrefix(key, rule.Prefix) {
+			return rule
+		}
+	}
+
+	return p.def
+}
+
+// SetHeaders sets Cache-Control and Expires of a response for a node/item expiring at expiresAt
+func (r Rule) SetHeaders(header http.Header, now, expiresAt time.Time) {
+	if r.Mode == ModeNoStore {
+		header.Set("Cache-Control", string(ModeNoStore))
+
+		return
+	}
+
+	maxAge := max(expiresAt.Sub(now), 0)
+	if r.MaxAge > 0 {
+		maxAge = min(maxAge, r.MaxAge)
+	}
+
+	// Rounded down, so caches do not keep a response longer than the node/item lives
+	seconds := int64(math.Floor(maxAge.Seconds()))
+
+	header.Set("Cache-Control", string(r.Mode)+", max-age="+strconv.FormatInt(seconds, 10))
+	header.Set("Expires", now.Add(time.Duration(seconds)*time.Second).UTC().Format(http.TimeFormat))
+}
+
+// ParseRule parses mode[:seconds], e.g. "public:60" caps max-age of public responses at a minute
+func ParseRule(s string) (Rule, error) {
+	mode, maxAge, capped := strings.Cut(strings.TrimSpace(s), ":")
+
+	rule := Rule{Mode: Mode(mode)}
+
+	switch rule.Mode {
+	case ModePrivate, ModePublic, ModeNoStore:
+	default:
+		return Rule{}, fmt.Errorf("cache rule %q: mode is not private, public or no-store", s)
+	}
+
+	if capped {
+		seconds, err := strconv.ParseUint(maxAge, 10, 32)
+		if err != nil || seconds == 0 {
+			return Rule{}, fmt.Errorf("cache rule %q: invalid max-age", s)
+		}
+
+		rule.MaxAge = time.Duration(seconds) * time.Second
+	}
+
+	return rule, nil
+}
+
+// ParseRules parses prefix=mode[:seconds] rules, e.g. "user:=public:60" or "session:=no-store"
+func ParseRules(entries []string) ([]Rule, error) {
+	rules := make([]Rule, 0, len(entries))
+
+	for _, entry := range entries {
+		// Prefixes may contain '=', modes may not
+		i := strings.LastIndex(entry, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("cache rule %q is not prefix=mode[:seconds]", entry)
+		}
+
+		rule, err := ParseRule(entry[i+1:])
+		if err != nil {
+			return nil, err
+		}
+
+		rule.Prefix = strings.TrimSpace(entry[:i])
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
@@ -0,0 +1,62 @@
+package httpcache
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy(t *testing.T) {
+	rules, err := ParseRules([]string{"user:=public:60", "user:admin:=no-store", "a=b:=private"})
+	assert.NoError(t, err)
+
+	policy := New(Rule{Mode: ModePrivate}, rules)
+
+	assert.Equal(t, Rule{Prefix: "user:", Mode: ModePublic, MaxAge: time.Minute}, policy.Rule("user:1"))
+	assert.Equal(t, Rule{Prefix: "user:admin:", Mode: ModeNoStore}, policy.Rule("user:admin:1"))
+	assert.Equal(t, Rule{Prefix: "a=b:", Mode: ModePrivate}, policy.Rule("a=b:c"))
+	assert.Equal(t, Rule{Mode: ModePrivate}, policy.Rule("session:1"))
+}
+
+func TestSetHeaders(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		rule         Rule
+		expiresAt    time.Time
+		cacheControl string
+		expires      string
+	}{
+		{name: "remaining ttl", rule: Rule{Mode: ModePrivate}, expiresAt: now.Add(90*time.Second + 500*time.Millisecond),
+			cacheControl: "private, max-age=90", expires: "Wed, 01 May 2024 12:01:30 GMT"},
+		{name: "capped", rule: Rule{Mode: ModePublic, MaxAge: time.Minute}, expiresAt: now.Add(time.Hour),
+			cacheControl: "public, max-age=60", expires: "Wed, 01 May 2024 12:01:00 GMT"},
+		{name: "expired", rule: Rule{Mode: ModePublic}, expiresAt: now.Add(-time.Second),
+			cacheControl: "public, max-age=0", expires: "Wed, 01 May 2024 12:00:00 GMT"},
+		{name: "no-store", rule: Rule{Mode: ModeNoStore}, expiresAt: now.Add(time.Hour),
+			cacheControl: "no-store"},
+	}
+
+	for _, test := range tests {
+		header := http.Header{}
+		test.rule.SetHeaders(header, now, test.expiresAt)
+
+		assert.Equal(t, test.cacheControl, header.Get("Cache-Control"), test.name)
+		assert.Equal(t, test.expires, header.Get("Expires"), test.name)
+	}
+}
+
+func TestParseRule(t *testing.T) {
+	for _, s := range []string{"", "cache", "public:", "public:0", "public:-1", "public:1m"} {
+		_, err := ParseRule(s)
+		assert.Error(t, err, s)
+	}
+
+	for _, s := range []string{"user:1", "=public", "public"} {
+		_, err := ParseRules([]string{s})
+		assert.Error(t, err, s)
+	}
+}
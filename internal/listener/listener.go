@@ -0,0 +1,205 @@
+// Package listener provides a TCP server that serves every connection in its own goroutine
+// and limits the number of concurrent connections.
+// It is shared by the protocol listeners (RESP, memcached) of lru-api.
+package listener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Error returned by Serve after Shutdown
+var ErrServerClosed = errors.New("listener: server closed")
+
+// Handler serves a single connection. ctx is cancelled on Shutdown.
+// The connection is closed by the server after Handler returns.
+type Handler func(ctx context.Context, conn net.Conn)
+
+// Server accepts TCP connections and passes them to Handler.
+type Server struct {
+	Addr    string
+	Handler Handler
+
+	// MaxConns limits concurrent connections, 0 means no limit.
+	// Connections above the limit are passed to Reject, if set, and closed.
+	MaxConns int
+	Reject   func(conn net.Conn)
+
+	Log *slog.Logger
+
+	m        sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// ListenAndServe listens on s.Addr and serves connections until Shutdown.
+func (s *Server) ListenAndServe() error {
+	listener, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(listener)
+}
+
+// Serve accepts connections on the listener until Shutdown.
+// It always returns a non-nil error, after Shutdown it is ErrServerClosed.
+func (s *Server) Serve(listener net.Listener) error {
+	s.m.Lock()
+	if s.closed {
+		s.m.Unlock()
+		listener.Close()
+
+		return ErrServerClosed
+	}
+
+	s.listener = listener
+	s.conns = make(map[net.Conn]struct{})
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.m.Unlock()
+
+	var delay time.Duration
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				// Same backoff as net/http on temporary accept errors
+				if delay == 0 {
+					delay = 5 * time.Millisecond
+				} else if delay *= 2; delay > time.Second {
+					delay = time.Second
+				}
+
+				s.Log.Warn("accept error, retrying", "error", err.Error(), "delay", delay)
+				time.Sleep(delay)
+
+				continue
+			}
+
+			return err
+		}
+
+		delay = 0
+
+		if !s.track(conn) {
+			s.Log.Warn("connection rejected, too many connections", "remote", conn.RemoteAddr().String())
+
+			if s.Reject != nil {
+				s.Reject(conn)
+			}
+
+			conn.Close()
+
+			continue
+		}
+
+		go s.serve(conn)
+	}
+}
+
+// ListenAddr returns the address the server listens on, or nil if it is not listening yet.
+func (s *Server) ListenAddr() net.Addr {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.listener == nil {
+		return nil
+	}
+
+	return s.listener.Addr()
+}
+
+func (s *Server) serve(conn net.Conn) {
+	defer s.wg.Done()
+	defer s.untrack(conn)
+	defer conn.Close()
+
+	// A panic closes only the connection that caused it
+	defer func() {
+		if err := recover(); err != nil {
+			s.Log.Error("connection handler panicked", "remote", conn.RemoteAddr().String(), "error", fmt.Sprint(err), "stack", string(debug.Stack()))
+		}
+	}()
+
+	s.Handler(s.ctx, conn)
+}
+
+func (s *Server) track(conn net.Conn) bool {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.closed || (s.MaxConns > 0 && len(s.conns) >= s.MaxConns) {
+		return false
+	}
+
+	s.conns[conn] = struct{}{}
+	s.wg.Add(1)
+
+	return true
+}
+
+func (s *Server) untrack(conn net.Conn) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	delete(s.conns, conn)
+}
+
+func (s *Server) isClosed() bool {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return s.closed
+}
+
+// Shutdown stops accepting connections, cancels handlers' context and waits for them to return.
+// Connections still open when ctx is done are closed forcibly.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.m.Lock()
+	s.closed = true
+
+	if s.listener != nil {
+		s.listener.Close()
+	}
+
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.m.Unlock()
+
+	done := make(chan struct{})
+
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.m.Lock()
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.m.Unlock()
+
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,55 @@
+package listener
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPanicClosesConnection(t *testing.T) {
+	server := &Server{
+		Handler: func(ctx context.Context, conn net.Conn) {
+			line, _ := bufio.NewReader(conn).ReadString('\n')
+			if line == "panic\n" {
+				panic("handler failed")
+			}
+
+			io.WriteString(conn, "ok\n")
+		},
+		Log: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	go server.Serve(l)
+	defer server.Shutdown(context.Background())
+
+	// The connection that panicked is closed
+	conn, err := net.Dial("tcp", l.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	_, err = io.WriteString(conn, "panic\n")
+	assert.NoError(t, err)
+
+	_, err = bufio.NewReader(conn).ReadString('\n')
+	assert.ErrorIs(t, err, io.EOF)
+
+	// The server keeps serving others
+	other, err := net.Dial("tcp", l.Addr().String())
+	assert.NoError(t, err)
+	defer other.Close()
+
+	_, err = io.WriteString(other, "hello\n")
+	assert.NoError(t, err)
+
+	reply, err := bufio.NewReader(other).ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "ok\n", reply)
+}
@@ -0,0 +1,91 @@
+// Package logging configures the slog logger of lru-api.
+// Records logged with a context carry the ID of the request they belong to.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Formats supported by New
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID carried by ctx, empty if there is none
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+
+	return id
+}
+
+// New creates a logger writing records of at least level to w as text or JSON
+func New(w io.Writer, format string, level slog.Leveler) (*slog.Logger, error) {
+	options := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+
+	switch strings.ToLower(format) {
+	case FormatText, "":
+		handler = slog.NewTextHandler(w, options)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, options)
+	default:
+		return nil, fmt.Errorf("log format %q is not text or json", format)
+	}
+
+	return slog.New(NewHandler(handler)), nil
+}
+
+// ParseLevel parses DEBUG, INFO, WARN or ERROR, in any case and optionally with an offset such as WARN+2
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("log level %q is not DEBUG, INFO, WARN or ERROR", s)
+	}
+
+	return level, nil
+}
+
+// contextHandler adds the request ID of the context to records
+type contextHandler struct {
+	slog.Handler
+}
+
+// NewHandler wraps h so records logged with a context carrying a request ID have a request_id attribute
+func NewHandler(h slog.Handler) slog.Handler {
+	if _, ok := h.(contextHandler); ok {
+		return h
+	}
+
+	return contextHandler{h}
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		record = record.Clone()
+		record.AddAttrs(slog.String("request_id", id))
+	}
+
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
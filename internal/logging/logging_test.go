@@ -0,0 +1,82 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		format string
+		line   string
+		err    bool
+	}{
+		{format: "text", line: `level=WARN msg=message key=value request_id=req-1`},
+		{format: "", line: `level=WARN msg=message key=value request_id=req-1`},
+		{format: "JSON", line: `"level":"WARN","msg":"message","key":"value","request_id":"req-1"}`},
+		{format: "xml", err: true},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+
+		log, err := New(&buf, test.format, slog.LevelWarn)
+		if test.err {
+			assert.Error(t, err, test.format)
+
+			continue
+		}
+
+		assert.NoError(t, err, test.format)
+
+		ctx := WithRequestID(context.Background(), "req-1")
+
+		log.InfoContext(ctx, "filtered")
+		log.With("key", "value").WarnContext(ctx, "message")
+		log.Warn("without context")
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		assert.Len(t, lines, 2, test.format)
+		assert.Contains(t, lines[0], test.line, test.format)
+		assert.NotContains(t, lines[1], "request_id", test.format)
+	}
+}
+
+func TestNewHandler(t *testing.T) {
+	handler := NewHandler(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	// Wrapping twice does not log the request ID twice
+	assert.Equal(t, handler, NewHandler(handler))
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		s     string
+		level slog.Level
+		err   bool
+	}{
+		{s: "debug", level: slog.LevelDebug},
+		{s: "INFO", level: slog.LevelInfo},
+		{s: "Warn", level: slog.LevelWarn},
+		{s: "ERROR", level: slog.LevelError},
+		{s: "WARN+2", level: slog.LevelWarn + 2},
+		{s: "off", err: true},
+	}
+
+	for _, test := range tests {
+		level, err := ParseLevel(test.s)
+		if test.err {
+			assert.Error(t, err, test.s)
+
+			continue
+		}
+
+		assert.NoError(t, err, test.s)
+		assert.Equal(t, test.level, level, test.s)
+	}
+}
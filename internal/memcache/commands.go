@@ -0,0 +1,954 @@
+package memcache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skantay/lru-api/internal/cache"
+)
+
+// Version reported by the version and stats commands
+const serverVersion = "1.6.0-lru-api"
+
+// exptime values up to 30 days are relative to now, larger values are absolute unix timestamps
+const maxRelativeExptime = 60 * 60 * 24 * 30
+
+const (
+	replyError         = "ERROR\r\n"
+	replyBadFormat     = "CLIENT_ERROR bad command line format\r\n"
+	replyInvalidNumber = "CLIENT_ERROR invalid numeric delta argument\r\n"
+	replyNonNumeric    = "CLIENT_ERROR cannot increment or decrement non-numeric value\r\n"
+	replyTooLarge      = "SERVER_ERROR object too large for cache\r\n"
+)
+
+// Outcomes of storage commands
+const (
+	stored    = "STORED"
+	notStored = "NOT_STORED"
+	exists    = "EXISTS"
+	notFound  = "NOT_FOUND"
+)
+
+// item is stored instead of a plain string when a client sets non-zero flags,
+// so the flags survive a round trip
+type item struct {
+	Value string `json:"value"`
+	Flags uint32 `json:"flags"`
+}
+
+// expiration converts memcached exptime into a TTL for Put.
+// exptime up to 30 days is relative to now, larger values are absolute unix timestamps.
+// In memcached 0 means "never expires"; every node/item of LRU cache expires, so the default TTL is applied.
+// expired is true if the item expires immediately: negative exptime or an absolute time in the past.
+func expiration(exptime int64, now time.Time) (ttl time.Duration, expired bool) {
+	switch {
+	case exptime < 0:
+		return 0, true
+	case exptime == 0:
+		return 0, false
+	case exptime <= maxRelativeExptime:
+		return time.Duration(exptime) * time.Second, false
+	}
+
+	ttl = time.Unix(exptime, 0).Sub(now)
+	if ttl <= 0 {
+		return 0, true
+	}
+
+	return ttl, false
+}
+
+func (s *Server) execute(ctx context.Context, c *conn, fields []string) error {
+	switch name, args := fields[0], fields[1:]; name {
+	case "get", "gets":
+		s.get(ctx, c, args, name == "gets")
+	case "set", "add", "replace", "cas":
+		return s.storage(ctx, c, name, args)
+	case "delete":
+		s.delete(ctx, c, args)
+	case "incr", "decr":
+		s.incr(ctx, c, args, name == "decr")
+	case "touch":
+		s.touch(ctx, c, args)
+	case "flush_all":
+		s.flushAll(ctx, c, args)
+	case "stats":
+		s.writeStats(ctx, c)
+	case "version":
+		c.w.WriteString("VERSION " + serverVersion + "\r\n")
+	case "verbosity":
+		reply(c, args, "OK")
+	case "quit":
+		c.quit = true
+	case "mg":
+		s.metaGet(ctx, c, args)
+	case "ms":
+		return s.metaSet(ctx, c, args)
+	case "md":
+		s.metaDelete(ctx, c, args)
+	case "ma":
+		s.metaArithmetic(ctx, c, args)
+	case "mn":
+		c.w.WriteString("MN\r\n")
+	default:
+		c.w.WriteString(replyError)
+	}
+
+	return nil
+}
+
+// reply writes a reply of a classic command unless the last argument is noreply
+func reply(c *conn, args []string, message string) {
+	if len(args) > 0 && args[len(args)-1] == "noreply" {
+		return
+	}
+
+	c.w.WriteString(message + "\r\n")
+}
+
+// get implements get|gets <key>*
+func (s *Server) get(ctx context.Context, c *conn, keys []string, withCAS bool) {
+	if len(keys) == 0 {
+		c.w.WriteString(replyError)
+
+		return
+	}
+
+	for _, key := range keys {
+		s.stats.cmdGet.Add(1)
+
+		value, expiresAt, err := s.cache.Get(ctx, key)
+		if err != nil {
+			if !errors.Is(err, cache.ErrKeyDoesNotExist) {
+				s.log.Error(err.Error())
+			}
+
+			s.stats.getMisses.Add(1)
+
+			continue
+		}
+
+		s.stats.getHits.Add(1)
+
+		data, flags := decode(value)
+
+		if withCAS {
+			fmt.Fprintf(c.w, "VALUE %s %d %d %d\r\n", key, flags, len(data), casUnique(data, flags, expiresAt))
+		} else {
+			fmt.Fprintf(c.w, "VALUE %s %d %d\r\n", key, flags, len(data))
+		}
+
+		c.w.WriteString(data)
+		c.w.WriteString("\r\n")
+	}
+
+	c.w.WriteString("END\r\n")
+}
+
+// storage implements set|add|replace <key> <flags> <exptime> <bytes> [noreply]
+// and cas <key> <flags> <exptime> <bytes> <cas unique> [noreply]
+func (s *Server) storage(ctx context.Context, c *conn, name string, args []string) error {
+	required := 4
+	if name == "cas" {
+		required = 5
+	}
+
+	if len(args) < required || len(args) > required+1 {
+		c.w.WriteString(replyError)
+
+		return nil
+	}
+
+	flags, errFlags := strconv.ParseUint(args[1], 10, 32)
+	exptime, errExptime := strconv.ParseInt(args[2], 10, 64)
+	size, errSize := strconv.Atoi(args[3])
+
+	if errFlags != nil || errExptime != nil || errSize != nil || size < 0 || !validKey(args[0]) {
+		c.w.WriteString(replyBadFormat)
+
+		// The data block cannot be skipped without a valid size
+		if errSize == nil && size >= 0 && size <= maxValueLength {
+			_, err := c.readData(size)
+
+			return err
+		}
+
+		return nil
+	}
+
+	if size > maxValueLength {
+		c.w.WriteString(replyTooLarge)
+
+		// The data block is not read, so the connection is closed
+		return errValueTooLarge
+	}
+
+	data, err := c.readData(size)
+	if err != nil {
+		return err
+	}
+
+	var unique uint64
+
+	if name == "cas" {
+		unique, err = strconv.ParseUint(args[4], 10, 64)
+		if err != nil {
+			c.w.WriteString(replyBadFormat)
+
+			return nil
+		}
+	}
+
+	s.stats.cmdSet.Add(1)
+
+	mode := name
+	if name == "cas" {
+		mode = "set"
+	}
+
+	result, err := s.store(ctx, args[0], mode, newValue(string(data), uint32(flags)), exptime, name == "cas", unique)
+	if err != nil {
+		c.w.WriteString("SERVER_ERROR " + err.Error() + "\r\n")
+
+		return nil
+	}
+
+	reply(c, args[required:], result)
+
+	return nil
+}
+
+// store writes a value according to the mode: set, add (only if missing), replace (only if present),
+// append or prepend (to an existing value). If checkCAS is true, the current value must match casUnique.
+func (s *Server) store(ctx context.Context, key, mode string, value interface{}, exptime int64, checkCAS bool, unique uint64) (string, error) {
+	ttl, expired := expiration(exptime, time.Now())
+
+	result := notStored
+
+	err := s.cache.Update(ctx, key, func(current interface{}, expiresAt time.Time, ok bool) (interface{}, time.Duration, bool) {
+		switch {
+		case checkCAS && !ok:
+			result = notFound
+
+			return nil, 0, false
+		case checkCAS:
+			data, flags := decode(current)
+			if casUnique(data, flags, expiresAt) != unique {
+				result = exists
+
+				return nil, 0, false
+			}
+		case mode == "add" && ok, mode == "replace" && !ok:
+			return nil, 0, false
+		case mode == "append" || mode == "prepend":
+			if !ok {
+				return nil, 0, false
+			}
+
+			data, flags := decode(current)
+			added, _ := decode(value)
+
+			if mode == "append" {
+				value = newValue(data+added, flags)
+			} else {
+				value = newValue(added+data, flags)
+			}
+
+			// Appending keeps the expiration time of the item
+			ttl = cache.KeepTTL
+		}
+
+		result = stored
+
+		return value, ttl, !expired
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// The item expires immediately, so it is removed instead of being stored
+	if expired && result == stored {
+		if _, err := s.cache.Evict(ctx, key); err != nil && !errors.Is(err, cache.ErrKeyDoesNotExist) {
+			return "", err
+		}
+	}
+
+	return result, nil
+}
+
+// delete implements delete <key> [noreply]
+func (s *Server) delete(ctx context.Context, c *conn, args []string) {
+	if len(args) < 1 || len(args) > 2 {
+		c.w.WriteString(replyError)
+
+		return
+	}
+
+	if _, err := s.cache.Evict(ctx, args[0]); err != nil {
+		if errors.Is(err, cache.ErrKeyDoesNotExist) {
+			reply(c, args[1:], notFound)
+
+			return
+		}
+
+		c.w.WriteString("SERVER_ERROR " + err.Error() + "\r\n")
+
+		return
+	}
+
+	reply(c, args[1:], "DELETED")
+}
+
+// incr implements incr|decr <key> <value> [noreply].
+// Decrementing below 0 results in 0, incrementing wraps around at 64 bits, as in memcached.
+func (s *Server) incr(ctx context.Context, c *conn, args []string, decr bool) {
+	if len(args) < 2 || len(args) > 3 {
+		c.w.WriteString(replyError)
+
+		return
+	}
+
+	delta, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		c.w.WriteString(replyInvalidNumber)
+
+		return
+	}
+
+	result, found, numeric, err := s.add(ctx, args[0], delta, decr, nil)
+	switch {
+	case err != nil:
+		c.w.WriteString("SERVER_ERROR " + err.Error() + "\r\n")
+	case !found:
+		reply(c, args[2:], notFound)
+	case !numeric:
+		c.w.WriteString(replyNonNumeric)
+	default:
+		reply(c, args[2:], strconv.FormatUint(result, 10))
+	}
+}
+
+// autovivify describes an item created by add if the key is missing
+type autovivify struct {
+	initial uint64
+	exptime int64
+}
+
+// add atomically increments or decrements a numeric value keeping its expiration time.
+// If the key is missing and create is set, the item is created with the initial value.
+func (s *Server) add(ctx context.Context, key string, delta uint64, decr bool, create *autovivify) (result uint64, found, numeric bool, err error) {
+	err = s.cache.Update(ctx, key, func(value interface{}, _ time.Time, ok bool) (interface{}, time.Duration, bool) {
+		if !ok {
+			if create == nil {
+				return nil, 0, false
+			}
+
+			found, numeric = true, true
+			result = create.initial
+
+			ttl, _ := expiration(create.exptime, time.Now())
+
+			return strconv.FormatUint(result, 10), ttl, true
+		}
+
+		found = true
+
+		data, flags := decode(value)
+
+		n, err := strconv.ParseUint(data, 10, 64)
+		if err != nil {
+			return nil, 0, false
+		}
+
+		numeric = true
+
+		switch {
+		case !decr:
+			result = n + delta
+		case delta > n:
+			result = 0
+		default:
+			result = n - delta
+		}
+
+		return newValue(strconv.FormatUint(result, 10), flags), cache.KeepTTL, true
+	})
+
+	return
+}
+
+// touch implements touch <key> <exptime> [noreply]
+func (s *Server) touch(ctx context.Context, c *conn, args []string) {
+	if len(args) < 2 || len(args) > 3 {
+		c.w.WriteString(replyError)
+
+		return
+	}
+
+	exptime, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		c.w.WriteString(replyBadFormat)
+
+		return
+	}
+
+	s.stats.cmdTouch.Add(1)
+
+	found, err := s.setExpiration(ctx, args[0], exptime)
+	if err != nil {
+		c.w.WriteString("SERVER_ERROR " + err.Error() + "\r\n")
+
+		return
+	}
+
+	if !found {
+		reply(c, args[2:], notFound)
+
+		return
+	}
+
+	reply(c, args[2:], "TOUCHED")
+}
+
+// setExpiration changes the expiration time of an existing item
+func (s *Server) setExpiration(ctx context.Context, key string, exptime int64) (found bool, err error) {
+	ttl, expired := expiration(exptime, time.Now())
+
+	err = s.cache.Update(ctx, key, func(value interface{}, _ time.Time, ok bool) (interface{}, time.Duration, bool) {
+		found = ok
+
+		return value, ttl, ok && !expired
+	})
+	if err != nil || !found || !expired {
+		return found, err
+	}
+
+	if _, err := s.cache.Evict(ctx, key); err != nil && !errors.Is(err, cache.ErrKeyDoesNotExist) {
+		return found, err
+	}
+
+	return found, nil
+}
+
+// scheduleFlush flushes the cache after delay, replacing a flush scheduled before as memcached does.
+// The flush is audited with the actor of ctx, it is not cancelled with ctx.
+func (s *Server) scheduleFlush(ctx context.Context, delay time.Duration) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	if s.flush != nil {
+		s.flush.Stop()
+	}
+
+	var timer *time.Timer
+
+	timer = time.AfterFunc(delay, func() {
+		s.m.Lock()
+		current := s.flush == timer
+		if current {
+			s.flush = nil
+		}
+		s.m.Unlock()
+
+		// Replaced or stopped by Shutdown while it was firing
+		if !current {
+			return
+		}
+
+		if err := s.cache.EvictAll(context.WithoutCancel(ctx)); err != nil {
+			s.log.Error(err.Error())
+		}
+	})
+
+	s.flush = timer
+}
+
+// flushAll implements flush_all [delay] [noreply]
+func (s *Server) flushAll(ctx context.Context, c *conn, args []string) {
+	var delay int64
+
+	if len(args) > 0 && args[0] != "noreply" {
+		var err error
+
+		delay, err = strconv.ParseInt(args[0], 10, 64)
+		if err != nil || delay < 0 {
+			c.w.WriteString(replyBadFormat)
+
+			return
+		}
+	}
+
+	s.stats.cmdFlush.Add(1)
+
+	if delay > 0 {
+		s.scheduleFlush(ctx, time.Duration(delay)*time.Second)
+
+		reply(c, args, "OK")
+
+		return
+	}
+
+	if err := s.cache.EvictAll(ctx); err != nil {
+		c.w.WriteString("SERVER_ERROR " + err.Error() + "\r\n")
+
+		return
+	}
+
+	reply(c, args, "OK")
+}
+
+func (s *Server) writeStats(ctx context.Context, c *conn) {
+	keys, _, err := s.cache.GetAll(ctx)
+	if err != nil {
+		c.w.WriteString("SERVER_ERROR " + err.Error() + "\r\n")
+
+		return
+	}
+
+	now := time.Now()
+
+	stats := []struct {
+		name  string
+		value interface{}
+	}{
+		{"pid", os.Getpid()},
+		{"uptime", int64(now.Sub(s.started).Seconds())},
+		{"time", now.Unix()},
+		{"version", serverVersion},
+		{"curr_connections", s.stats.currConnections.Load()},
+		{"total_connections", s.stats.totalConnections.Load()},
+		{"cmd_get", s.stats.cmdGet.Load()},
+		{"cmd_set", s.stats.cmdSet.Load()},
+		{"cmd_flush", s.stats.cmdFlush.Load()},
+		{"cmd_touch", s.stats.cmdTouch.Load()},
+		{"get_hits", s.stats.getHits.Load()},
+		{"get_misses", s.stats.getMisses.Load()},
+		{"curr_items", len(keys)},
+	}
+
+	for _, stat := range stats {
+		fmt.Fprintf(c.w, "STAT %s %v\r\n", stat.name, stat.value)
+	}
+
+	c.w.WriteString("END\r\n")
+}
+
+// newValue returns the value stored in the cache for data and client flags
+func newValue(data string, flags uint32) interface{} {
+	if flags == 0 {
+		return data
+	}
+
+	return item{Value: data, Flags: flags}
+}
+
+// decode converts a cached value to data and client flags.
+// Values stored through the HTTP API are encoded as JSON, unless they are strings, numbers or blobs.
+func decode(value interface{}) (string, uint32) {
+	switch v := value.(type) {
+	case item:
+		return v.Value, v.Flags
+	case string:
+		return v, 0
+	case []byte:
+		return string(v), 0
+	case cache.Blob:
+		return string(v.Data), 0
+	case int:
+		return strconv.Itoa(v), 0
+	case int64:
+		return strconv.FormatInt(v, 10), 0
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), 0
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value), 0
+	}
+
+	return string(data), 0
+}
+
+// casUnique derives the CAS value from item contents, any change of the item changes it
+func casUnique(data string, flags uint32, expiresAt time.Time) uint64 {
+	h := fnv.New64a()
+
+	fmt.Fprintf(h, "%d:%d:", flags, expiresAt.UnixNano())
+	h.Write([]byte(data))
+
+	// 0 means "no CAS" in the meta protocol
+	if sum := h.Sum64(); sum != 0 {
+		return sum
+	}
+
+	return math.MaxUint64
+}
+
+// metaFlags are the flags of a meta command, tokens are the flag values, e.g. T30 is flags['T'] = "30"
+type metaFlags map[byte]string
+
+func parseMetaFlags(args []string) (metaFlags, bool) {
+	flags := make(metaFlags, len(args))
+
+	for _, arg := range args {
+		if arg == "" {
+			return nil, false
+		}
+
+		flags[arg[0]] = arg[1:]
+	}
+
+	return flags, true
+}
+
+func (f metaFlags) has(flag byte) bool {
+	_, ok := f[flag]
+
+	return ok
+}
+
+// returned builds the flags echoed back to the client, O (opaque) and k (key) are always echoed
+func (f metaFlags) returned(key string, extra ...string) string {
+	returned := extra
+
+	if f.has('k') {
+		returned = append(returned, "k"+key)
+	}
+
+	if f.has('O') {
+		returned = append(returned, "O"+f['O'])
+	}
+
+	if len(returned) == 0 {
+		return ""
+	}
+
+	return " " + strings.Join(returned, " ")
+}
+
+// metaGet implements mg <key> <flags>*.
+// Supported flags: v (value), t (remaining TTL), f (client flags), s (size), c (CAS), k (key),
+// O (opaque), q (no reply on miss), T (update TTL).
+func (s *Server) metaGet(ctx context.Context, c *conn, args []string) {
+	if len(args) == 0 || !validKey(args[0]) {
+		c.w.WriteString(replyBadFormat)
+
+		return
+	}
+
+	key := args[0]
+
+	flags, ok := parseMetaFlags(args[1:])
+	if !ok {
+		c.w.WriteString(replyBadFormat)
+
+		return
+	}
+
+	s.stats.cmdGet.Add(1)
+
+	if exptime, ok := flags['T']; ok {
+		n, err := strconv.ParseInt(exptime, 10, 64)
+		if err != nil {
+			c.w.WriteString(replyBadFormat)
+
+			return
+		}
+
+		if _, err := s.setExpiration(ctx, key, n); err != nil {
+			c.w.WriteString("SERVER_ERROR " + err.Error() + "\r\n")
+
+			return
+		}
+	}
+
+	value, expiresAt, err := s.cache.Get(ctx, key)
+	if err != nil {
+		s.stats.getMisses.Add(1)
+
+		if !flags.has('q') {
+			c.w.WriteString("EN\r\n")
+		}
+
+		return
+	}
+
+	s.stats.getHits.Add(1)
+
+	data, clientFlags := decode(value)
+
+	var extra []string
+
+	for _, flag := range []byte{'t', 'f', 's', 'c'} {
+		if !flags.has(flag) {
+			continue
+		}
+
+		switch flag {
+		case 't':
+			extra = append(extra, "t"+strconv.FormatInt(int64(math.Ceil(time.Until(expiresAt).Seconds())), 10))
+		case 'f':
+			extra = append(extra, "f"+strconv.FormatUint(uint64(clientFlags), 10))
+		case 's':
+			extra = append(extra, "s"+strconv.Itoa(len(data)))
+		case 'c':
+			extra = append(extra, "c"+strconv.FormatUint(casUnique(data, clientFlags, expiresAt), 10))
+		}
+	}
+
+	if !flags.has('v') {
+		c.w.WriteString("HD" + flags.returned(key, extra...) + "\r\n")
+
+		return
+	}
+
+	c.w.WriteString("VA " + strconv.Itoa(len(data)) + flags.returned(key, extra...) + "\r\n")
+	c.w.WriteString(data)
+	c.w.WriteString("\r\n")
+}
+
+// metaSet implements ms <key> <datalen> <flags>*.
+// Supported flags: T (TTL), F (client flags), C (compare CAS), q (no reply on success), O, k and
+// M (mode): S set, E add, R replace, A append, P prepend.
+func (s *Server) metaSet(ctx context.Context, c *conn, args []string) error {
+	if len(args) < 2 {
+		c.w.WriteString(replyBadFormat)
+
+		return nil
+	}
+
+	size, err := strconv.Atoi(args[1])
+	if err != nil || size < 0 {
+		c.w.WriteString(replyBadFormat)
+
+		return nil
+	}
+
+	if size > maxValueLength {
+		c.w.WriteString(replyTooLarge)
+
+		// The data block is not read, so the connection is closed
+		return errValueTooLarge
+	}
+
+	data, err := c.readData(size)
+	if err != nil {
+		return err
+	}
+
+	key := args[0]
+
+	flags, ok := parseMetaFlags(args[2:])
+	if !ok || !validKey(key) {
+		c.w.WriteString(replyBadFormat)
+
+		return nil
+	}
+
+	var (
+		exptime     int64
+		clientFlags uint64
+		unique      uint64
+	)
+
+	if v, ok := flags['T']; ok {
+		exptime, err = strconv.ParseInt(v, 10, 64)
+	}
+
+	if v, ok := flags['F']; ok && err == nil {
+		clientFlags, err = strconv.ParseUint(v, 10, 32)
+	}
+
+	if v, ok := flags['C']; ok && err == nil {
+		unique, err = strconv.ParseUint(v, 10, 64)
+	}
+
+	if err != nil {
+		c.w.WriteString(replyBadFormat)
+
+		return nil
+	}
+
+	modes := map[string]string{"": "set", "S": "set", "s": "set", "E": "add", "e": "add", "R": "replace", "r": "replace", "A": "append", "a": "append", "P": "prepend", "p": "prepend"}
+
+	mode, ok := modes[flags['M']]
+	if !ok {
+		c.w.WriteString("CLIENT_ERROR invalid mode for ms\r\n")
+
+		return nil
+	}
+
+	s.stats.cmdSet.Add(1)
+
+	result, err := s.store(ctx, key, mode, newValue(string(data), uint32(clientFlags)), exptime, flags.has('C'), unique)
+	if err != nil {
+		c.w.WriteString("SERVER_ERROR " + err.Error() + "\r\n")
+
+		return nil
+	}
+
+	codes := map[string]string{stored: "HD", notStored: "NS", exists: "EX", notFound: "NF"}
+
+	if result == stored && flags.has('q') {
+		return nil
+	}
+
+	c.w.WriteString(codes[result] + flags.returned(key) + "\r\n")
+
+	return nil
+}
+
+// metaDelete implements md <key> <flags>*. Supported flags: C (compare CAS), q, O and k.
+func (s *Server) metaDelete(ctx context.Context, c *conn, args []string) {
+	if len(args) == 0 || !validKey(args[0]) {
+		c.w.WriteString(replyBadFormat)
+
+		return
+	}
+
+	key := args[0]
+
+	flags, ok := parseMetaFlags(args[1:])
+	if !ok {
+		c.w.WriteString(replyBadFormat)
+
+		return
+	}
+
+	if v, ok := flags['C']; ok {
+		unique, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			c.w.WriteString(replyBadFormat)
+
+			return
+		}
+
+		var matched, found bool
+
+		err = s.cache.Update(ctx, key, func(value interface{}, expiresAt time.Time, ok bool) (interface{}, time.Duration, bool) {
+			data, clientFlags := decode(value)
+			found, matched = ok, ok && casUnique(data, clientFlags, expiresAt) == unique
+
+			return nil, 0, false
+		})
+		if err != nil {
+			c.w.WriteString("SERVER_ERROR " + err.Error() + "\r\n")
+
+			return
+		}
+
+		if found && !matched {
+			c.w.WriteString("EX" + flags.returned(key) + "\r\n")
+
+			return
+		}
+	}
+
+	if _, err := s.cache.Evict(ctx, key); err != nil {
+		if errors.Is(err, cache.ErrKeyDoesNotExist) {
+			c.w.WriteString("NF" + flags.returned(key) + "\r\n")
+
+			return
+		}
+
+		c.w.WriteString("SERVER_ERROR " + err.Error() + "\r\n")
+
+		return
+	}
+
+	if !flags.has('q') {
+		c.w.WriteString("HD" + flags.returned(key) + "\r\n")
+	}
+}
+
+// metaArithmetic implements ma <key> <flags>*.
+// Supported flags: D (delta, default 1), M (mode): I or + increment, D or - decrement,
+// N (create with this TTL if missing), J (initial value when created), v (return value), q, O and k.
+func (s *Server) metaArithmetic(ctx context.Context, c *conn, args []string) {
+	if len(args) == 0 || !validKey(args[0]) {
+		c.w.WriteString(replyBadFormat)
+
+		return
+	}
+
+	key := args[0]
+
+	flags, ok := parseMetaFlags(args[1:])
+	if !ok {
+		c.w.WriteString(replyBadFormat)
+
+		return
+	}
+
+	delta := uint64(1)
+
+	var (
+		err    error
+		create *autovivify
+	)
+
+	if v, ok := flags['D']; ok {
+		delta, err = strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			c.w.WriteString(replyInvalidNumber)
+
+			return
+		}
+	}
+
+	if v, ok := flags['N']; ok {
+		create = &autovivify{}
+
+		create.exptime, err = strconv.ParseInt(v, 10, 64)
+		if err == nil && flags.has('J') {
+			create.initial, err = strconv.ParseUint(flags['J'], 10, 64)
+		}
+
+		if err != nil {
+			c.w.WriteString(replyBadFormat)
+
+			return
+		}
+	}
+
+	var decr bool
+
+	switch flags['M'] {
+	case "", "I", "i", "+":
+	case "D", "d", "-":
+		decr = true
+	default:
+		c.w.WriteString("CLIENT_ERROR invalid mode for ma\r\n")
+
+		return
+	}
+
+	result, found, numeric, err := s.add(ctx, key, delta, decr, create)
+	switch {
+	case err != nil:
+		c.w.WriteString("SERVER_ERROR " + err.Error() + "\r\n")
+	case !found:
+		c.w.WriteString("NF" + flags.returned(key) + "\r\n")
+	case !numeric:
+		c.w.WriteString(replyNonNumeric)
+	case flags.has('v'):
+		number := strconv.FormatUint(result, 10)
+
+		c.w.WriteString("VA " + strconv.Itoa(len(number)) + flags.returned(key) + "\r\n" + number + "\r\n")
+	case !flags.has('q'):
+		c.w.WriteString("HD" + flags.returned(key) + "\r\n")
+	}
+}
@@ -0,0 +1,244 @@
+// Package memcache provides a TCP listener speaking the memcached ASCII protocol,
+// including the meta commands, on top of the LRU cache.
+package memcache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/skantay/lru-api/internal/audit"
+	"github.com/skantay/lru-api/internal/cache"
+	"github.com/skantay/lru-api/internal/listener"
+)
+
+const (
+	// Longest command line, memcached keys are limited to 250 bytes
+	maxLineLength = 2048
+	// Largest value accepted, same as memcached default item size limit
+	maxValueLength = 1 << 20
+	// Longest key allowed by the protocol
+	maxKeyLength = 250
+)
+
+var (
+	errLineTooLong   = errors.New("line too long")
+	errBadDataChunk  = errors.New("bad data chunk")
+	errValueTooLarge = errors.New("value too large")
+)
+
+// Cache is the subset of LRUCache used by the listener
+type Cache interface {
+	Get(ctx context.Context, key string) (value interface{}, expiresAt time.Time, err error)
+	GetAll(ctx context.Context) (keys []string, values []interface{}, err error)
+	Evict(ctx context.Context, key string) (value interface{}, err error)
+	EvictAll(ctx context.Context) error
+	Update(ctx context.Context, key string, fn cache.UpdateFunc) error
+}
+
+// Config of the memcached listener
+type Config struct {
+	Addr string
+	// MaxConns limits concurrent client connections, 0 means no limit
+	MaxConns int
+	// IdleTimeout closes connections that did not send a command for this long, 0 means no timeout
+	IdleTimeout time.Duration
+}
+
+// Server is a memcached protocol listener
+type Server struct {
+	cache  Cache
+	log    *slog.Logger
+	config Config
+
+	listener *listener.Server
+	started  time.Time
+
+	// flush is the pending delayed flush_all, none are scheduled once closed
+	m      sync.Mutex
+	flush  *time.Timer
+	closed bool
+
+	stats stats
+}
+
+type stats struct {
+	currConnections  atomic.Int64
+	totalConnections atomic.Int64
+	cmdGet           atomic.Int64
+	cmdSet           atomic.Int64
+	cmdTouch         atomic.Int64
+	cmdFlush         atomic.Int64
+	getHits          atomic.Int64
+	getMisses        atomic.Int64
+}
+
+// New creates a new memcached listener backed by the cache.
+func New(cache Cache, log *slog.Logger, config Config) *Server {
+	s := &Server{
+		cache:  cache,
+		log:    log,
+		config: config,
+	}
+
+	s.listener = &listener.Server{
+		Addr:     config.Addr,
+		Handler:  s.serve,
+		MaxConns: config.MaxConns,
+		Reject: func(conn net.Conn) {
+			conn.SetWriteDeadline(time.Now().Add(time.Second))
+			io.WriteString(conn, "SERVER_ERROR too many open connections\r\n")
+		},
+		Log: log,
+	}
+
+	return s
+}
+
+// ListenAndServe serves clients until Shutdown, then it returns listener.ErrServerClosed.
+func (s *Server) ListenAndServe() error {
+	s.started = time.Now()
+
+	return s.listener.ListenAndServe()
+}
+
+// Serve serves clients accepted on l until Shutdown.
+func (s *Server) Serve(l net.Listener) error {
+	s.started = time.Now()
+
+	return s.listener.Serve(l)
+}
+
+// Shutdown stops accepting clients and waits for open connections to finish their current command.
+// A pending delayed flush_all is cancelled.
+func (s *Server) Shutdown(ctx context.Context) error {
+	err := s.listener.Shutdown(ctx)
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.closed = true
+
+	if s.flush != nil {
+		s.flush.Stop()
+		s.flush = nil
+	}
+
+	return err
+}
+
+// conn is the state of a single client connection
+type conn struct {
+	r    *bufio.Reader
+	w    *bufio.Writer
+	quit bool
+}
+
+func (s *Server) serve(ctx context.Context, netConn net.Conn) {
+	s.stats.currConnections.Add(1)
+	s.stats.totalConnections.Add(1)
+	defer s.stats.currConnections.Add(-1)
+
+	// Unblock a pending read on shutdown, the current command is completed first
+	stop := context.AfterFunc(ctx, func() {
+		netConn.SetReadDeadline(time.Now())
+	})
+	defer stop()
+
+	// Writes are audited as the client's, there is no principal to record
+	ctx = audit.WithActor(ctx, "memcache", netConn.RemoteAddr().String())
+
+	c := &conn{
+		r: bufio.NewReaderSize(netConn, maxLineLength),
+		w: bufio.NewWriter(netConn),
+	}
+
+	for !c.quit {
+		if s.config.IdleTimeout > 0 && ctx.Err() == nil {
+			netConn.SetReadDeadline(time.Now().Add(s.config.IdleTimeout))
+		}
+
+		line, err := c.readLine()
+		if err != nil {
+			if errors.Is(err, errLineTooLong) {
+				c.w.WriteString("CLIENT_ERROR line too long\r\n")
+				c.w.Flush()
+			} else if !errors.Is(err, io.EOF) && !errors.Is(err, os.ErrDeadlineExceeded) {
+				s.log.Debug("memcache read failed", "remote", netConn.RemoteAddr().String(), "error", err.Error())
+			}
+
+			return
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			c.w.WriteString("ERROR\r\n")
+		} else if err := s.execute(context.WithoutCancel(ctx), c, fields); err != nil {
+			// Data block could not be read, the connection is out of sync
+			if errors.Is(err, errBadDataChunk) {
+				c.w.WriteString("CLIENT_ERROR bad data chunk\r\n")
+				c.w.Flush()
+			}
+
+			return
+		}
+
+		// Replies to pipelined commands are flushed together
+		if c.r.Buffered() == 0 || c.quit {
+			if err := c.w.Flush(); err != nil {
+				s.log.Debug("memcache write failed", "remote", netConn.RemoteAddr().String(), "error", err.Error())
+
+				return
+			}
+		}
+	}
+}
+
+func (c *conn) readLine() (string, error) {
+	line, err := c.r.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		return "", errLineTooLong
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+// readData reads a data block of size bytes terminated by CRLF
+func (c *conn) readData(size int) ([]byte, error) {
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return nil, err
+	}
+
+	if data[size] != '\r' || data[size+1] != '\n' {
+		return nil, errBadDataChunk
+	}
+
+	return data[:size], nil
+}
+
+func validKey(key string) bool {
+	if len(key) == 0 || len(key) > maxKeyLength {
+		return false
+	}
+
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,165 @@
+package memcache
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skantay/lru-api/internal/cache"
+	"github.com/skantay/lru-api/internal/cache/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpiration(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name            string
+		exptime         int64
+		expectedTTL     time.Duration
+		expectedExpired bool
+	}{
+		{"#1: zero applies default TTL", 0, 0, false},
+		{"#2: negative expires immediately", -1, 0, true},
+		{"#3: relative", 60, time.Minute, false},
+		{"#4: longest relative", maxRelativeExptime, maxRelativeExptime * time.Second, false},
+		{"#5: absolute in the future", 1700000060, time.Minute, false},
+		{"#6: absolute in the past", 1600000000, 0, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ttl, expired := expiration(test.exptime, now)
+			assert.Equal(t, test.expectedTTL, ttl)
+			assert.Equal(t, test.expectedExpired, expired)
+		})
+	}
+}
+
+func TestCommands(t *testing.T) {
+	c, err := cache.New(10, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	server := New(c, slog.New(slog.NewTextHandler(io.Discard, nil)), Config{})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	go server.Serve(l)
+	defer server.Shutdown(context.Background())
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+
+	tests := []struct {
+		command  string
+		expected string
+	}{
+		{"get a\r\n", "END\r\n"},
+		{"replace a 0 0 1\r\nx\r\n", "NOT_STORED\r\n"},
+		{"add a 5 0 1\r\nx\r\n", "STORED\r\n"},
+		{"add a 0 0 1\r\ny\r\n", "NOT_STORED\r\n"},
+		{"get a b\r\n", "VALUE a 5 1\r\nx\r\nEND\r\n"},
+		{"set n 0 100 2\r\n10\r\n", "STORED\r\n"},
+		{"incr n 5\r\n", "15\r\n"},
+		{"decr n 20\r\n", "0\r\n"},
+		{"incr a 1\r\n", replyNonNumeric},
+		{"touch n 10\r\n", "TOUCHED\r\n"},
+		{"touch z 10\r\n", "NOT_FOUND\r\n"},
+		{"set e 0 -1 1\r\nx\r\n", "STORED\r\n"},
+		{"get e\r\n", "END\r\n"},
+		{"delete n noreply\r\ndelete n\r\n", "NOT_FOUND\r\n"},
+		{"mn\r\n", "MN\r\n"},
+		{"ms m 2 T60 F3 Oabc\r\nhi\r\n", "HD Oabc\r\n"},
+		{"mg m v f s k\r\n", "VA 2 f3 s2 km\r\nhi\r\n"},
+		{"mg z v\r\n", "EN\r\n"},
+		{"mg z v q\r\nmn\r\n", "MN\r\n"},
+		{"ms m 1 ME\r\nx\r\n", "NS\r\n"},
+		{"ma c N0 J5 v\r\n", "VA 1\r\n5\r\n"},
+		{"ma c MD D2\r\n", "HD\r\n"},
+		{"mg c v\r\n", "VA 1\r\n3\r\n"},
+		{"md c\r\n", "HD\r\n"},
+		{"md c\r\n", "NF\r\n"},
+		{"flush_all\r\n", "OK\r\n"},
+		{"get a m\r\n", "END\r\n"},
+		{"bogus\r\n", "ERROR\r\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(strings.TrimSpace(test.command), func(t *testing.T) {
+			_, err := io.WriteString(conn, test.command)
+			assert.NoError(t, err)
+
+			reply := make([]byte, len(test.expected))
+			_, err = io.ReadFull(r, reply)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, string(reply))
+		})
+	}
+}
+
+func TestCAS(t *testing.T) {
+	c, err := cache.New(10, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	server := New(c, slog.New(slog.NewTextHandler(io.Discard, nil)), Config{})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	go server.Serve(l)
+	defer server.Shutdown(context.Background())
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+
+	io.WriteString(conn, "set a 0 0 1\r\nx\r\ngets a\r\n")
+
+	line, _ := r.ReadString('\n')
+	assert.Equal(t, "STORED\r\n", line)
+
+	line, _ = r.ReadString('\n')
+	fields := strings.Fields(line)
+	assert.Len(t, fields, 5)
+	r.ReadString('\n')
+	r.ReadString('\n')
+
+	io.WriteString(conn, "cas a 0 0 1 1\r\ny\r\n")
+	line, _ = r.ReadString('\n')
+	assert.Equal(t, "EXISTS\r\n", line)
+
+	io.WriteString(conn, "cas a 0 0 1 "+fields[4]+"\r\ny\r\n")
+	line, _ = r.ReadString('\n')
+	assert.Equal(t, "STORED\r\n", line)
+}
+
+func TestDelayedFlushCancelledOnShutdown(t *testing.T) {
+	c, err := cache.New(10, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	assert.NoError(t, c.Put(context.Background(), "a", "1", 0))
+
+	server := New(c, slog.New(slog.NewTextHandler(io.Discard, nil)), Config{})
+
+	server.scheduleFlush(context.Background(), 50*time.Millisecond)
+	assert.NoError(t, server.Shutdown(context.Background()))
+
+	// Flushes are not scheduled after Shutdown either
+	server.scheduleFlush(context.Background(), time.Millisecond)
+
+	time.Sleep(100 * time.Millisecond)
+
+	_, _, err = c.Get(context.Background(), "a")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// cacheCollector reads cache.Stats on scrape, so the cache does not depend on Prometheus
+type cacheCollector struct {
+	source StatsSource
+
+	hits      *prometheus.Desc
+	misses    *prometheus.Desc
+	evictions *prometheus.Desc
+	entries   *prometheus.Desc
+	capacity  *prometheus.Desc
+	bytes     *prometheus.Desc
+}
+
+func newCacheCollector(source StatsSource) *cacheCollector {
+	name := func(name string) string {
+		return prometheus.BuildFQName(namespace, "cache", name)
+	}
+
+	return &cacheCollector{
+		source:    source,
+		hits:      prometheus.NewDesc(name("hits_total"), "Lookups that found a node/item.", nil, nil),
+		misses:    prometheus.NewDesc(name("misses_total"), "Lookups that did not find a node/item or found it expired.", nil, nil),
+		evictions: prometheus.NewDesc(name("evictions_total"), "Nodes/items removed from the cache by reason.", []string{"reason"}, nil),
+		entries:   prometheus.NewDesc(name("entries"), "Nodes/items in the cache, expired ones are counted until they are found.", nil, nil),
+		capacity:  prometheus.NewDesc(name("capacity"), "Maximum number of nodes/items in the cache.", nil, nil),
+		bytes:     prometheus.NewDesc(name("bytes"), "Estimated size of keys and values in the cache.", nil, nil),
+	}
+}
+
+func (c *cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.evictions
+	ch <- c.entries
+	ch <- c.capacity
+	ch <- c.bytes
+}
+
+func (c *cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.source.Stats()
+
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(stats.Misses))
+
+	for reason, count := range stats.Evictions {
+		ch <- prometheus.MustNewConstMetric(c.evictions, prometheus.CounterValue, float64(count), string(reason))
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.entries, prometheus.GaugeValue, float64(stats.Len))
+	ch <- prometheus.MustNewConstMetric(c.capacity, prometheus.GaugeValue, float64(stats.Cap))
+	ch <- prometheus.MustNewConstMetric(c.bytes, prometheus.GaugeValue, float64(stats.Bytes))
+}
@@ -0,0 +1,93 @@
+// Package metrics exposes metrics of the cache and the HTTP API in Prometheus text exposition format.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/skantay/lru-api/internal/cache"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "lru"
+
+// StatsSource is a cache that reports its counters
+type StatsSource interface {
+	Stats() cache.Stats
+}
+
+// Metrics holds the collectors of lru-api and serves them on /metrics
+type Metrics struct {
+	registry *prometheus.Registry
+	handler  http.Handler
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	cacheDuration   *prometheus.HistogramVec
+}
+
+// New creates metrics registered in a dedicated registry together with Go runtime and process metrics.
+func New() *Metrics {
+	registry := prometheus.NewRegistry()
+
+	m := &Metrics{
+		registry: registry,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "HTTP requests by method, route pattern and status code.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of HTTP handlers by method and route pattern.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		cacheDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "cache",
+			Name:      "operation_duration_seconds",
+			Help:      "Latency of cache operations including the wait for the lock.",
+			Buckets:   []float64{.000001, .000005, .00001, .00005, .0001, .0005, .001, .005, .01, .05, .1},
+		}, []string{"operation"}),
+	}
+
+	registry.MustRegister(
+		m.requests,
+		m.requestDuration,
+		m.cacheDuration,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	m.handler = promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+
+	return m
+}
+
+// RegisterCache exports counters and size of the cache, they are read on every scrape.
+func (m *Metrics) RegisterCache(source StatsSource) {
+	m.registry.MustRegister(newCacheCollector(source))
+}
+
+// ObserveCacheOperation records the latency of a cache operation, it is passed to cache.WithObserver
+func (m *Metrics) ObserveCacheOperation(operation string, duration time.Duration) {
+	m.cacheDuration.WithLabelValues(operation).Observe(duration.Seconds())
+}
+
+// ObserveRequest records a handled HTTP request
+func (m *Metrics) ObserveRequest(method, route string, status int, duration time.Duration) {
+	m.requests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	m.requestDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+// ServeHTTP serves the metrics in Prometheus text exposition format
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.handler.ServeHTTP(w, r)
+}
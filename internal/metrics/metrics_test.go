@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skantay/lru-api/internal/api"
+	"github.com/skantay/lru-api/internal/cache"
+	"github.com/skantay/lru-api/internal/cache/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	m := New()
+
+	c, err := cache.New(1, time.Minute, &mocks.Logger{}, cache.WithObserver(m.ObserveCacheOperation))
+	assert.NoError(t, err)
+
+	m.RegisterCache(c)
+
+	handler := api.New(c, slog.New(slog.NewTextHandler(io.Discard, nil)), api.WithMetrics(m))
+
+	requests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{method: http.MethodPost, path: "/api/lru", body: `{"key": "key 1", "value": "value 1"}`},
+		{method: http.MethodGet, path: "/api/lru/key%201"},
+		{method: http.MethodGet, path: "/api/lru/missing"},
+		{method: http.MethodPost, path: "/api/lru", body: `{"key": "key 2", "value": "value 2"}`},
+		{method: http.MethodGet, path: "/unknown"},
+	}
+
+	for _, request := range requests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(request.method, request.path, strings.NewReader(request.body)))
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/plain")
+
+	body := w.Body.String()
+
+	for _, line := range []string{
+		`lru_cache_hits_total 1`,
+		`lru_cache_misses_total 1`,
+		`lru_cache_evictions_total{reason="capacity"} 1`,
+		`lru_cache_evictions_total{reason="expired"} 0`,
+		`lru_cache_entries 1`,
+		`lru_cache_capacity 1`,
+		`lru_cache_bytes 12`,
+		`lru_cache_operation_duration_seconds_count{operation="put"} 2`,
+		`lru_cache_operation_duration_seconds_count{operation="get"} 2`,
+		`lru_http_requests_total{method="POST",route="/api/lru",status="201"} 2`,
+		`lru_http_requests_total{method="GET",route="/api/lru/{key}",status="200"} 1`,
+		`lru_http_requests_total{method="GET",route="/api/lru/{key}",status="404"} 1`,
+		`lru_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`lru_http_request_duration_seconds_count{method="GET",route="/api/lru/{key}"} 2`,
+	} {
+		assert.Contains(t, body, line+"\n")
+	}
+}
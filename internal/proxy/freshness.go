@@ -0,0 +1,170 @@
+package proxy
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Statuses cacheable by default (RFC 9110, section 15.1), others are never stored
+var cacheableStatus = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusPermanentRedirect:    true,
+	http.StatusNotFound:             true,
+	http.StatusMethodNotAllowed:     true,
+	http.StatusGone:                 true,
+	http.StatusRequestURITooLong:    true,
+	http.StatusNotImplemented:       true,
+}
+
+// cacheControl holds the directives of Cache-Control headers, names are lower case
+type cacheControl map[string]string
+
+func parseCacheControl(header http.Header) cacheControl {
+	cc := cacheControl{}
+
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if name == "" {
+				continue
+			}
+
+			cc[strings.ToLower(name)] = strings.Trim(arg, `"`)
+		}
+	}
+
+	return cc
+}
+
+func (cc cacheControl) has(name string) bool {
+	_, ok := cc[name]
+
+	return ok
+}
+
+// seconds returns the delta-seconds argument of a directive
+func (cc cacheControl) seconds(name string) (time.Duration, bool) {
+	arg, ok := cc[name]
+	if !ok {
+		return 0, false
+	}
+
+	seconds, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+
+	return time.Duration(seconds) * time.Second, true
+}
+
+// lifetime is the freshness lifetime of a response in a shared cache: s-maxage, max-age or Expires minus Date.
+// Responses without any of them are not stored, the proxy does not guess heuristic lifetimes.
+func lifetime(header http.Header, now time.Time) (time.Duration, bool) {
+	cc := parseCacheControl(header)
+
+	if d, ok := cc.seconds("s-maxage"); ok {
+		return d, true
+	}
+
+	if d, ok := cc.seconds("max-age"); ok {
+		return d, true
+	}
+
+	if header.Get("Expires") == "" {
+		return 0, false
+	}
+
+	// An invalid Expires means already expired
+	expires, err := http.ParseTime(header.Get("Expires"))
+	if err != nil {
+		return 0, true
+	}
+
+	return expires.Sub(date(header, now)), true
+}
+
+// age is the age of a response when it is received, the larger of Age and the time since Date
+func age(header http.Header, now time.Time) time.Duration {
+	var d time.Duration
+
+	if seconds, err := strconv.ParseInt(header.Get("Age"), 10, 64); err == nil && seconds > 0 {
+		d = time.Duration(seconds) * time.Second
+	}
+
+	return max(d, now.Sub(date(header, now)))
+}
+
+func date(header http.Header, now time.Time) time.Time {
+	if t, err := http.ParseTime(header.Get("Date")); err == nil {
+		return t
+	}
+
+	return now
+}
+
+// storable returns how long a response to r stays fresh, false if a shared cache must not store it
+func storable(r *http.Request, status int, header http.Header, now time.Time) (time.Duration, bool) {
+	if !cacheableStatus[status] {
+		return 0, false
+	}
+
+	cc := parseCacheControl(header)
+
+	// no-cache responses could be stored and revalidated, the proxy does not revalidate
+	if cc.has("no-store") || cc.has("private") || cc.has("no-cache") {
+		return 0, false
+	}
+
+	if parseCacheControl(r.Header).has("no-store") {
+		return 0, false
+	}
+
+	// Responses varying on everything never match another request
+	for _, name := range varyNames(header) {
+		if name == "*" {
+			return 0, false
+		}
+	}
+
+	// Cookies of one client must not be replayed to others
+	if header.Get("Set-Cookie") != "" {
+		return 0, false
+	}
+
+	if r.Header.Get("Authorization") != "" && !cc.has("public") && !cc.has("s-maxage") && !cc.has("must-revalidate") {
+		return 0, false
+	}
+
+	d, ok := lifetime(header, now)
+	if !ok {
+		return 0, false
+	}
+
+	ttl := d - age(header, now)
+	if ttl <= 0 {
+		return 0, false
+	}
+
+	return ttl, true
+}
+
+// varyNames returns the canonical names of the request headers a response varies on
+func varyNames(header http.Header) []string {
+	var names []string
+
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+
+	return names
+}
@@ -0,0 +1,474 @@
+// Package proxy provides a caching reverse proxy in front of an upstream HTTP service.
+// Responses are stored in the LRU cache as long as their Cache-Control, Expires and Vary allow,
+// concurrent misses of a URL are coalesced into a single upstream request.
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skantay/lru-api/internal/audit"
+	"github.com/skantay/lru-api/internal/cache"
+)
+
+// Cache is the subset of LRUCache used by the proxy
+type Cache interface {
+	Put(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+	Get(ctx context.Context, key string) (value interface{}, expiresAt time.Time, err error)
+	Evict(ctx context.Context, key string) (value interface{}, err error)
+}
+
+// Config of the proxy
+type Config struct {
+	// Upstream is the service requests are forwarded to, its path prefixes the paths of requests
+	Upstream *url.URL
+	// KeyPrefix is prepended to cache keys, so responses do not collide with keys written through the API
+	KeyPrefix string
+	// MaxBodySize is the largest body stored, larger responses are streamed to the client only
+	MaxBodySize int64
+	// Timeout of upstream requests, 0 means no timeout
+	Timeout time.Duration
+	// Transport of upstream requests, http.DefaultTransport if nil
+	Transport http.RoundTripper
+}
+
+// Stored responses are blobs of this media type, so they are exported and imported like other binary values
+const responseContentType = "message/http"
+
+// Values of the X-Cache header
+const (
+	cacheHit  = "HIT"
+	cacheMiss = "MISS"
+)
+
+// Hop-by-hop headers (RFC 9110, section 7.6.1) are not forwarded in either direction
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// Proxy is a caching reverse proxy
+type Proxy struct {
+	cache     Cache
+	log       *slog.Logger
+	config    Config
+	transport http.RoundTripper
+
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// flight is an upstream request other requests of the same URL wait for
+type flight struct {
+	done chan struct{}
+	once sync.Once
+	// response is nil if it may not be shared, waiters then forward their requests themselves
+	response *response
+}
+
+// response is a buffered upstream response
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+	// varied holds the request headers the response was fetched with
+	varied http.Header
+}
+
+// New creates a new proxy storing responses in the cache.
+func New(cache Cache, log *slog.Logger, config Config) *Proxy {
+	transport := config.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	return &Proxy{
+		cache:     cache,
+		log:       log,
+		config:    config,
+		transport: transport,
+		flights:   make(map[string]*flight),
+	}
+}
+
+// ServeHTTP answers GET and HEAD from the cache or the upstream, other methods are forwarded
+// and invalidate the stored responses of their URL.
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Stored and invalidated responses are audited as the client's
+	r = r.WithContext(audit.WithActor(r.Context(), "proxy", r.RemoteAddr))
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		p.forwardUnsafe(w, r)
+
+		return
+	}
+
+	base := p.baseKey(r.Method, r.URL)
+	cc := parseCacheControl(r.Header)
+
+	// Requests with credentials are not answered with responses of others, no-cache and no-store skip the cache
+	if r.Header.Get("Authorization") != "" || cc.has("no-cache") || cc.has("no-store") {
+		p.fetch(w, r, base, nil)
+
+		return
+	}
+
+	if p.serveStored(w, r, base) {
+		return
+	}
+
+	p.mu.Lock()
+	f, ok := p.flights[base]
+	if !ok {
+		f = &flight{done: make(chan struct{})}
+		p.flights[base] = f
+	}
+	p.mu.Unlock()
+
+	if !ok {
+		defer p.land(base, f)
+
+		p.fetch(w, r, base, f)
+
+		return
+	}
+
+	select {
+	case <-f.done:
+	case <-r.Context().Done():
+		return
+	}
+
+	if f.response != nil && p.variantKey(base, varyNames(f.response.header), r.Header) == p.variantKey(base, varyNames(f.response.header), f.response.varied) {
+		p.write(w, r, f.response, cacheHit)
+
+		return
+	}
+
+	p.fetch(w, r, base, nil)
+}
+
+// land releases the requests waiting for f, its response must be set before
+func (p *Proxy) land(base string, f *flight) {
+	f.once.Do(func() {
+		p.mu.Lock()
+		delete(p.flights, base)
+		p.mu.Unlock()
+
+		close(f.done)
+	})
+}
+
+// serveStored answers with a stored response of the variant of r, false if there is none
+func (p *Proxy) serveStored(w http.ResponseWriter, r *http.Request, base string) bool {
+	index, _, err := p.cache.Get(r.Context(), base+varyIndexSuffix)
+	if err != nil {
+		return false
+	}
+
+	names, ok := index.(string)
+	if !ok {
+		return false
+	}
+
+	value, expiresAt, err := p.cache.Get(r.Context(), p.variantKey(base, splitNames(names), r.Header))
+	if err != nil {
+		return false
+	}
+
+	blob, ok := value.(cache.Blob)
+	if !ok || blob.ContentType != responseContentType {
+		return false
+	}
+
+	stored, err := decodeResponse(blob.Data)
+	if err != nil {
+		p.log.Warn("stored response is not readable", "key", base, "error", err.Error())
+
+		return false
+	}
+
+	// The age is the lifetime less the time left, so it grows while the response is stored
+	now := time.Now()
+	if d, ok := lifetime(stored.header, now); ok {
+		stored.header.Set("Age", strconv.FormatInt(int64(max(d-expiresAt.Sub(now), 0)/time.Second), 10))
+	}
+
+	p.write(w, r, stored, cacheHit)
+
+	return true
+}
+
+// fetch forwards r and stores the response if it is storable, f is shared with waiting requests if not nil
+func (p *Proxy) fetch(w http.ResponseWriter, r *http.Request, base string, f *flight) {
+	// The request of a flight is not cancelled with its client, waiting requests need the response too
+	ctx := r.Context()
+	if f != nil {
+		ctx = context.WithoutCancel(ctx)
+	}
+
+	upstream, cancel, err := p.roundTrip(ctx, r)
+	if err != nil {
+		p.upstreamError(w, r, err)
+
+		return
+	}
+	defer cancel()
+	defer upstream.Body.Close()
+
+	removeHopHeaders(upstream.Header)
+
+	now := time.Now()
+
+	ttl, ok := storable(r, upstream.StatusCode, upstream.Header, now)
+	if !ok {
+		p.stream(w, upstream, nil)
+
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(upstream.Body, p.config.MaxBodySize+1))
+	if err != nil {
+		p.upstreamError(w, r, err)
+
+		return
+	}
+
+	if int64(len(body)) > p.config.MaxBodySize {
+		p.log.Debug("response is too large to store", "key", base, "max", p.config.MaxBodySize)
+
+		p.stream(w, upstream, body)
+
+		return
+	}
+
+	if upstream.Header.Get("Date") == "" {
+		upstream.Header.Set("Date", now.UTC().Format(http.TimeFormat))
+	}
+
+	stored := &response{
+		status: upstream.StatusCode,
+		header: upstream.Header,
+		body:   body,
+		varied: r.Header,
+	}
+
+	p.store(r.Context(), base, stored, ttl)
+
+	// Waiting requests are answered while the response is written to this client
+	if f != nil {
+		f.response = stored
+		p.land(base, f)
+	}
+
+	p.write(w, r, stored, cacheMiss)
+}
+
+// store writes the variant and the index of the header names it varies on
+func (p *Proxy) store(ctx context.Context, base string, stored *response, ttl time.Duration) {
+	ctx = context.WithoutCancel(ctx)
+	names := varyNames(stored.header)
+
+	value := cache.Blob{ContentType: responseContentType, Data: encodeResponse(stored)}
+
+	if err := p.cache.Put(ctx, p.variantKey(base, names, stored.varied), value, ttl); err != nil {
+		p.log.Warn("response not stored", "key", base, "error", err.Error())
+
+		return
+	}
+
+	if err := p.cache.Put(ctx, base+varyIndexSuffix, strings.Join(names, ","), ttl); err != nil {
+		p.log.Warn("response not stored", "key", base, "error", err.Error())
+	}
+}
+
+// forwardUnsafe forwards a request changing the resource, successful ones evict its stored responses
+func (p *Proxy) forwardUnsafe(w http.ResponseWriter, r *http.Request) {
+	upstream, cancel, err := p.roundTrip(r.Context(), r)
+	if err != nil {
+		p.upstreamError(w, r, err)
+
+		return
+	}
+	defer cancel()
+	defer upstream.Body.Close()
+
+	removeHopHeaders(upstream.Header)
+
+	if upstream.StatusCode < http.StatusBadRequest {
+		// Variants are reached through the index only, evicting it is enough
+		for _, method := range []string{http.MethodGet, http.MethodHead} {
+			if _, err := p.cache.Evict(context.WithoutCancel(r.Context()), p.baseKey(method, r.URL)+varyIndexSuffix); err != nil && !errors.Is(err, cache.ErrKeyDoesNotExist) {
+				p.log.Warn("stored response not evicted", "key", p.baseKey(method, r.URL), "error", err.Error())
+			}
+		}
+	}
+
+	p.stream(w, upstream, nil)
+}
+
+// roundTrip sends r to the upstream, cancel must be called once the body is read
+func (p *Proxy) roundTrip(ctx context.Context, r *http.Request) (*http.Response, context.CancelFunc, error) {
+	cancel := context.CancelFunc(func() {})
+	if p.config.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, p.config.Timeout)
+	}
+
+	out := r.Clone(ctx)
+	out.RequestURI = ""
+
+	if r.ContentLength == 0 {
+		out.Body = nil
+	}
+
+	pr := &httputil.ProxyRequest{In: r, Out: out}
+	pr.SetURL(p.config.Upstream)
+	pr.SetXForwarded()
+
+	removeHopHeaders(out.Header)
+
+	resp, err := p.transport.RoundTrip(out)
+	if err != nil {
+		cancel()
+
+		return nil, nil, err
+	}
+
+	return resp, cancel, nil
+}
+
+func (p *Proxy) upstreamError(w http.ResponseWriter, r *http.Request, err error) {
+	p.log.Warn("upstream request failed", "method", r.Method, "url", r.URL.String(), "error", err.Error())
+
+	status := http.StatusBadGateway
+	if errors.Is(err, context.DeadlineExceeded) {
+		status = http.StatusGatewayTimeout
+	}
+
+	w.Header().Set("X-Cache", cacheMiss)
+	http.Error(w, http.StatusText(status), status)
+}
+
+// stream answers with an upstream response that is not stored, head is the part of the body read already
+func (p *Proxy) stream(w http.ResponseWriter, upstream *http.Response, head []byte) {
+	copyHeader(w.Header(), upstream.Header)
+	w.Header().Set("X-Cache", cacheMiss)
+	w.WriteHeader(upstream.StatusCode)
+
+	if _, err := io.Copy(w, io.MultiReader(bytes.NewReader(head), upstream.Body)); err != nil {
+		p.log.Debug("response not streamed", "error", err.Error())
+	}
+}
+
+func (p *Proxy) write(w http.ResponseWriter, r *http.Request, stored *response, xCache string) {
+	copyHeader(w.Header(), stored.header)
+	w.Header().Set("X-Cache", xCache)
+
+	if r.Method != http.MethodHead {
+		w.Header().Set("Content-Length", strconv.Itoa(len(stored.body)))
+	}
+
+	w.WriteHeader(stored.status)
+	w.Write(stored.body)
+}
+
+// The index of a URL holds the names of the headers its responses vary on
+const varyIndexSuffix = "\nVary"
+
+// baseKey identifies the responses of a method and URL, the upstream is the same for all of them
+func (p *Proxy) baseKey(method string, u *url.URL) string {
+	return p.config.KeyPrefix + method + " " + u.RequestURI()
+}
+
+// variantKey identifies the response matching the values of the varied headers.
+// Lines of the headers contain ": ", so variants never collide with the index.
+func (p *Proxy) variantKey(base string, names []string, header http.Header) string {
+	names = slices.Clone(names)
+	slices.Sort(names)
+
+	var b strings.Builder
+
+	b.WriteString(base)
+
+	for _, name := range slices.Compact(names) {
+		fmt.Fprintf(&b, "\n%s: %s", name, strings.Join(header.Values(name), ", "))
+	}
+
+	return b.String()
+}
+
+func splitNames(names string) []string {
+	if names == "" {
+		return nil
+	}
+
+	return strings.Split(names, ",")
+}
+
+func removeHopHeaders(header http.Header) {
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			header.Del(strings.TrimSpace(name))
+		}
+	}
+
+	for _, name := range hopHeaders {
+		header.Del(name)
+	}
+}
+
+func copyHeader(dst, src http.Header) {
+	for name, values := range src {
+		dst[name] = append([]string(nil), values...)
+	}
+}
+
+// encodeResponse writes a response as an HTTP/1.1 message
+func encodeResponse(stored *response) []byte {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "HTTP/1.1 %03d %s\r\n", stored.status, http.StatusText(stored.status))
+	stored.header.WriteSubset(&buf, map[string]bool{"Content-Length": true})
+	fmt.Fprintf(&buf, "Content-Length: %d\r\n\r\n", len(stored.body))
+	buf.Write(stored.body)
+
+	return buf.Bytes()
+}
+
+// decodeResponse reads a response written by encodeResponse, HEAD responses are stored with an empty body
+func decodeResponse(data []byte) (*response, error) {
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), &http.Request{Method: http.MethodGet})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Header.Del("Content-Length")
+
+	return &response{status: resp.StatusCode, header: resp.Header, body: body}, nil
+}
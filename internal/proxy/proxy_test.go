@@ -0,0 +1,305 @@
+package proxy
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/skantay/lru-api/internal/cache"
+	"github.com/skantay/lru-api/internal/cache/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestProxy(t *testing.T, upstream http.Handler) *httptest.Server {
+	c, err := cache.New(100, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	u := httptest.NewServer(upstream)
+	t.Cleanup(u.Close)
+
+	upstreamURL, err := url.Parse(u.URL)
+	assert.NoError(t, err)
+
+	p := httptest.NewServer(New(c, slog.New(slog.NewTextHandler(io.Discard, nil)), Config{
+		Upstream:    upstreamURL,
+		KeyPrefix:   "proxy:",
+		MaxBodySize: 16,
+		Timeout:     time.Second,
+	}))
+	t.Cleanup(p.Close)
+
+	return p
+}
+
+func do(t *testing.T, method, target string, header map[string]string) (*http.Response, string) {
+	r, err := http.NewRequest(method, target, nil)
+	assert.NoError(t, err)
+
+	for name, value := range header {
+		r.Header.Set(name, value)
+	}
+
+	resp, err := http.DefaultClient.Do(r)
+	assert.NoError(t, err)
+
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	return resp, string(body)
+}
+
+func TestProxy(t *testing.T) {
+	var requests atomic.Int64
+
+	p := newTestProxy(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+
+		switch r.URL.Path {
+		case "/max-age":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/s-maxage":
+			w.Header().Set("Cache-Control", "max-age=0, s-maxage=60")
+		case "/expires":
+			w.Header().Set("Expires", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+		case "/aged":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Age", "30")
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store")
+		case "/private":
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		case "/vary":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Vary", "Accept-Language")
+			w.Write([]byte(r.Header.Get("Accept-Language") + " "))
+		case "/vary-all":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Vary", "*")
+		case "/large":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Write([]byte(strings.Repeat("x", 32)))
+		case "/missing":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.WriteHeader(http.StatusNotFound)
+		case "/error":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+
+		w.Write([]byte(strconv.FormatInt(n, 10)))
+	}))
+
+	tests := []struct {
+		name   string
+		path   string
+		header map[string]string
+		cached bool
+	}{
+		{name: "max-age", path: "/max-age", cached: true},
+		{name: "s-maxage", path: "/s-maxage", cached: true},
+		{name: "expires", path: "/expires", cached: true},
+		{name: "query is a part of the key", path: "/max-age?page=2", cached: true},
+		{name: "not found", path: "/missing", cached: true},
+		{name: "server error", path: "/error"},
+		{name: "no-store", path: "/no-store"},
+		{name: "private", path: "/private"},
+		{name: "no freshness", path: "/none"},
+		{name: "vary on everything", path: "/vary-all"},
+		{name: "too large", path: "/large"},
+		{name: "credentials", path: "/max-age?auth", header: map[string]string{"Authorization": "Bearer token"}},
+	}
+
+	for _, test := range tests {
+		first, firstBody := do(t, http.MethodGet, p.URL+test.path, test.header)
+		second, secondBody := do(t, http.MethodGet, p.URL+test.path, test.header)
+
+		assert.Equal(t, "MISS", first.Header.Get("X-Cache"), test.name)
+		assert.Equal(t, first.StatusCode, second.StatusCode, test.name)
+
+		if test.cached {
+			assert.Equal(t, "HIT", second.Header.Get("X-Cache"), test.name)
+			assert.Equal(t, firstBody, secondBody, test.name)
+			assert.Equal(t, first.Header.Get("Cache-Control"), second.Header.Get("Cache-Control"), test.name)
+			assert.NotEmpty(t, second.Header.Get("Age"), test.name)
+		} else {
+			assert.Equal(t, "MISS", second.Header.Get("X-Cache"), test.name)
+			assert.NotEqual(t, firstBody, secondBody, test.name)
+		}
+	}
+
+	// The age of the upstream counts, the stored response is 30 seconds old already
+	do(t, http.MethodGet, p.URL+"/aged", nil)
+	resp, _ := do(t, http.MethodGet, p.URL+"/aged", nil)
+	assert.Equal(t, "HIT", resp.Header.Get("X-Cache"))
+	assert.Contains(t, []string{"30", "31"}, resp.Header.Get("Age"))
+
+	// Variants are stored by the values of the varied headers
+	_, en := do(t, http.MethodGet, p.URL+"/vary", map[string]string{"Accept-Language": "en"})
+	_, de := do(t, http.MethodGet, p.URL+"/vary", map[string]string{"Accept-Language": "de"})
+	assert.NotEqual(t, en, de)
+
+	resp, body := do(t, http.MethodGet, p.URL+"/vary", map[string]string{"Accept-Language": "en"})
+	assert.Equal(t, "HIT", resp.Header.Get("X-Cache"))
+	assert.Equal(t, en, body)
+
+	resp, body = do(t, http.MethodGet, p.URL+"/vary", map[string]string{"Accept-Language": "de"})
+	assert.Equal(t, "HIT", resp.Header.Get("X-Cache"))
+	assert.Equal(t, de, body)
+
+	// HEAD is stored apart from GET
+	resp, _ = do(t, http.MethodHead, p.URL+"/max-age", nil)
+	assert.Equal(t, "MISS", resp.Header.Get("X-Cache"))
+
+	resp, body = do(t, http.MethodHead, p.URL+"/max-age", nil)
+	assert.Equal(t, "HIT", resp.Header.Get("X-Cache"))
+	assert.Empty(t, body)
+
+	// no-cache requests go to the upstream and refresh the stored response
+	_, stored := do(t, http.MethodGet, p.URL+"/max-age", nil)
+	resp, refreshed := do(t, http.MethodGet, p.URL+"/max-age", map[string]string{"Cache-Control": "no-cache"})
+	assert.Equal(t, "MISS", resp.Header.Get("X-Cache"))
+	assert.NotEqual(t, stored, refreshed)
+
+	_, body = do(t, http.MethodGet, p.URL+"/max-age", nil)
+	assert.Equal(t, refreshed, body)
+
+	// Writes invalidate the stored responses of their URL
+	resp, _ = do(t, http.MethodPost, p.URL+"/max-age", nil)
+	assert.Equal(t, "MISS", resp.Header.Get("X-Cache"))
+
+	resp, body = do(t, http.MethodGet, p.URL+"/max-age", nil)
+	assert.Equal(t, "MISS", resp.Header.Get("X-Cache"))
+	assert.NotEqual(t, refreshed, body)
+}
+
+func TestProxyCoalescing(t *testing.T) {
+	var requests atomic.Int64
+
+	release := make(chan struct{})
+
+	p := newTestProxy(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		<-release
+
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("value"))
+	}))
+
+	const clients = 10
+
+	var wg sync.WaitGroup
+
+	results := make(chan string, clients)
+
+	for range clients {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			resp, body := do(t, http.MethodGet, p.URL+"/slow", nil)
+			results <- resp.Header.Get("X-Cache") + " " + body
+		}()
+	}
+
+	// The requests wait for the first one
+	assert.Eventually(t, func() bool { return requests.Load() == 1 }, time.Second, time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	wg.Wait()
+	close(results)
+
+	misses := 0
+
+	for result := range results {
+		if result == "MISS value" {
+			misses++
+		} else {
+			assert.Equal(t, "HIT value", result)
+		}
+	}
+
+	assert.Equal(t, 1, misses)
+	assert.Equal(t, int64(1), requests.Load())
+}
+
+func TestProxyUpstreamErrors(t *testing.T) {
+	p := newTestProxy(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(2 * time.Second)
+	}))
+
+	resp, _ := do(t, http.MethodGet, p.URL+"/slow", nil)
+	assert.Equal(t, http.StatusGatewayTimeout, resp.StatusCode)
+
+	c, err := cache.New(10, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	upstreamURL, err := url.Parse(closed.URL)
+	assert.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	New(c, slog.New(slog.NewTextHandler(io.Discard, nil)), Config{Upstream: upstreamURL}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+}
+
+func TestStorable(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		status  int
+		request map[string]string
+		header  map[string]string
+		ttl     time.Duration
+		ok      bool
+	}{
+		{name: "max-age", status: 200, header: map[string]string{"Cache-Control": "public, max-age=60"}, ttl: time.Minute, ok: true},
+		{name: "s-maxage wins", status: 200, header: map[string]string{"Cache-Control": "max-age=60, s-maxage=10"}, ttl: 10 * time.Second, ok: true},
+		{name: "quoted", status: 200, header: map[string]string{"Cache-Control": `max-age="60"`}, ttl: time.Minute, ok: true},
+		{name: "age", status: 200, header: map[string]string{"Cache-Control": "max-age=60", "Age": "20"}, ttl: 40 * time.Second, ok: true},
+		{name: "expires", status: 200, header: map[string]string{
+			"Date":    now.Add(-10 * time.Second).UTC().Format(http.TimeFormat),
+			"Expires": now.Add(50 * time.Second).UTC().Format(http.TimeFormat),
+		}, ttl: 50 * time.Second, ok: true},
+		{name: "invalid expires", status: 200, header: map[string]string{"Expires": "0"}},
+		{name: "stale", status: 200, header: map[string]string{"Cache-Control": "max-age=60", "Age": "60"}},
+		{name: "no freshness", status: 200},
+		{name: "no-cache", status: 200, header: map[string]string{"Cache-Control": "no-cache, max-age=60"}},
+		{name: "set-cookie", status: 200, header: map[string]string{"Cache-Control": "max-age=60", "Set-Cookie": "a=b"}},
+		{name: "uncacheable status", status: 201, header: map[string]string{"Cache-Control": "max-age=60"}},
+		{name: "request no-store", status: 200, request: map[string]string{"Cache-Control": "no-store"}, header: map[string]string{"Cache-Control": "max-age=60"}},
+		{name: "authorization", status: 200, request: map[string]string{"Authorization": "Basic a"}, header: map[string]string{"Cache-Control": "max-age=60"}},
+		{name: "authorization public", status: 200, request: map[string]string{"Authorization": "Basic a"}, header: map[string]string{"Cache-Control": "public, max-age=60"}, ttl: time.Minute, ok: true},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		for name, value := range test.request {
+			r.Header.Set(name, value)
+		}
+
+		header := http.Header{}
+		for name, value := range test.header {
+			header.Set(name, value)
+		}
+
+		ttl, ok := storable(r, test.status, header, now)
+
+		assert.Equal(t, test.ok, ok, test.name)
+		assert.InDelta(t, test.ttl, ttl, float64(time.Second), test.name)
+	}
+}
@@ -0,0 +1,384 @@
+// Package quota limits the number of entries and bytes each tenant stores in the cache.
+// Keys are charged to the tenant that wrote them and released when they leave the cache.
+// Releases follow cache events asynchronously, so usage is approximate for a short while after a removal.
+// Charges are reconciled with the content of the cache periodically, so events lost by the tracker do not make usage drift.
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skantay/lru-api/internal/cache"
+)
+
+// ErrExceeded is returned by Reserve when the write would exceed the quota of the tenant
+var ErrExceeded = errors.New("quota exceeded")
+
+// Limits of a tenant, zero means unlimited
+type Limits struct {
+	Entries int
+	Bytes   uint64
+}
+
+// Usage of a tenant
+type Usage struct {
+	Entries int
+	Bytes   uint64
+}
+
+// Config of the tracker
+type Config struct {
+	// Default limits of tenants not listed in Tenants
+	Default Limits
+	// Tenants are limits of specific tenants
+	Tenants map[string]Limits
+	// ReconcileInterval between checks of the charges against the cache, 0 is a minute
+	ReconcileInterval time.Duration
+}
+
+const (
+	defaultReconcileInterval = time.Minute
+	// Keys collected by a reconciliation per lock of the cache
+	reconcileBatchSize = 1000
+)
+
+// ParseTenants parses tenant:entries:bytes entries, e.g. "team-a:1000:1048576"
+func ParseTenants(entries []string) (map[string]Limits, error) {
+	tenants := make(map[string]Limits, len(entries))
+
+	for _, entry := range entries {
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		if len(parts) != 3 || parts[0] == "" {
+			return nil, fmt.Errorf("quota %q is not tenant:entries:bytes", entry)
+		}
+
+		entries, err := strconv.Atoi(parts[1])
+		if err != nil || entries < 0 {
+			return nil, fmt.Errorf("quota %q: invalid entries", entry)
+		}
+
+		bytes, err := strconv.ParseUint(parts[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("quota %q: invalid bytes", entry)
+		}
+
+		tenants[parts[0]] = Limits{Entries: entries, Bytes: bytes}
+	}
+
+	return tenants, nil
+}
+
+// Source publishes change events of the cache and lets its content be scanned
+type Source interface {
+	Subscribe(filter cache.Filter, lastEventID uint64) *cache.Subscription
+	LastEventID() uint64
+	Scan(ctx context.Context, batchSize int, fn func(entries []cache.Entry) error) error
+}
+
+type charge struct {
+	tenant string
+	size   uint64
+	// seq orders changes of charges, reconciliation leaves the ones changed while the cache was scanned
+	seq uint64
+	// after is the last event published when the key was reserved, events up to it are of previous writes
+	after uint64
+}
+
+// Tracker accounts entries and bytes stored by tenants
+type Tracker struct {
+	config Config
+
+	mu      sync.Mutex
+	source  Source
+	seq     uint64
+	charges map[string]charge
+	usage   map[string]Usage
+}
+
+// New creates a tracker without charges
+func New(config Config) *Tracker {
+	return &Tracker{
+		config:  config,
+		charges: make(map[string]charge),
+		usage:   make(map[string]Usage),
+	}
+}
+
+// Reserve charges the tenant for storing size bytes under key.
+// A previous charge of the key, of any tenant, is replaced.
+// rollback undoes the reservation if the write fails, restoring the previous charge, unless the key was charged again meanwhile.
+func (t *Tracker) Reserve(tenant, key string, size uint64) (rollback func(), err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	usage := t.usage[tenant]
+
+	previous, ok := t.charges[key]
+
+	if ok && previous.tenant == tenant {
+		usage.Bytes -= previous.size
+	} else {
+		usage.Entries++
+	}
+
+	usage.Bytes += size
+
+	limits := t.limits(tenant)
+
+	if limits.Entries > 0 && usage.Entries > limits.Entries {
+		return nil, fmt.Errorf("%w: tenant %s may store %d entries", ErrExceeded, tenant, limits.Entries)
+	}
+
+	if limits.Bytes > 0 && usage.Bytes > limits.Bytes {
+		return nil, fmt.Errorf("%w: tenant %s may store %d bytes", ErrExceeded, tenant, limits.Bytes)
+	}
+
+	if ok && previous.tenant != tenant {
+		t.release(key)
+	}
+
+	var after uint64
+	if t.source != nil {
+		after = t.source.LastEventID()
+	}
+
+	t.seq++
+	t.charges[key] = charge{tenant: tenant, size: size, seq: t.seq, after: after}
+	t.usage[tenant] = usage
+
+	seq := t.seq
+
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		if current, charged := t.charges[key]; !charged || current.seq != seq {
+			return
+		}
+
+		t.release(key)
+
+		if ok {
+			t.restore(key, previous)
+		}
+	}, nil
+}
+
+// Release drops the charge of key
+func (t *Tracker) Release(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.release(key)
+}
+
+// Usage returns what the tenant stores
+func (t *Tracker) Usage(tenant string) Usage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.usage[tenant]
+}
+
+// Follow releases keys evicted, expired or flushed from the cache until ctx is done.
+// Keys overwritten through other protocols stay charged to their tenant with the new size.
+// Charges are reconciled with the cache every ReconcileInterval and whenever the tracker falls behind.
+func (t *Tracker) Follow(ctx context.Context, source Source, log *slog.Logger) {
+	t.mu.Lock()
+	t.source = source
+	t.mu.Unlock()
+
+	reconcile := make(chan struct{}, 1)
+
+	go t.reconcileEvery(ctx, source, reconcile, log)
+
+	var lastEventID uint64
+
+	for {
+		sub := source.Subscribe(cache.Filter{}, lastEventID)
+
+		lastEventID = t.follow(ctx, sub, lastEventID)
+
+		sub.Close()
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Events are replayed from the backlog, unless too many were published meanwhile
+		log.Warn("quota tracker fell behind cache events, resubscribing", "error", sub.Err())
+
+		// Events missing from the backlog are lost, the cache tells what is left
+		select {
+		case reconcile <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (t *Tracker) follow(ctx context.Context, sub *cache.Subscription, lastEventID uint64) uint64 {
+	for {
+		select {
+		case <-ctx.Done():
+			return lastEventID
+		case e, ok := <-sub.C:
+			if !ok {
+				return lastEventID
+			}
+
+			lastEventID = e.ID
+
+			t.apply(e)
+		}
+	}
+}
+
+func (t *Tracker) apply(e cache.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if e.Type == cache.EventFlush {
+		for key, charge := range t.charges {
+			if charge.after < e.ID {
+				t.release(key)
+			}
+		}
+
+		return
+	}
+
+	// Events delivered late may be of a value the key had before it was reserved again
+	previous, ok := t.charges[e.Key]
+	if !ok || e.ID <= previous.after {
+		return
+	}
+
+	switch e.Type {
+	case cache.EventEvict, cache.EventExpire:
+		t.release(e.Key)
+	case cache.EventPut, cache.EventUpdate:
+		t.resize(e.Key, cache.SizeOf(e.Key, e.Value))
+	}
+}
+
+// reconcileEvery reconciles charges every ReconcileInterval and when triggered, until ctx is done
+func (t *Tracker) reconcileEvery(ctx context.Context, source Source, trigger <-chan struct{}, log *slog.Logger) {
+	interval := t.config.ReconcileInterval
+	if interval <= 0 {
+		interval = defaultReconcileInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-trigger:
+		}
+
+		if err := t.reconcile(ctx, source); err != nil && ctx.Err() == nil {
+			log.Warn("quota reconciliation failed", "error", err.Error())
+		}
+	}
+}
+
+// reconcile releases charged keys that are no longer in the cache and updates the sizes of the others.
+// Charges changed while the cache is scanned are left to the events.
+func (t *Tracker) reconcile(ctx context.Context, source Source) error {
+	t.mu.Lock()
+	start := t.seq
+	t.mu.Unlock()
+
+	sizes := make(map[string]uint64)
+
+	err := source.Scan(ctx, reconcileBatchSize, func(entries []cache.Entry) error {
+		for _, entry := range entries {
+			sizes[entry.Key] = cache.SizeOf(entry.Key, entry.Value)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for key, charge := range t.charges {
+		if charge.seq > start {
+			continue
+		}
+
+		if size, ok := sizes[key]; ok {
+			t.resize(key, size)
+		} else {
+			t.release(key)
+		}
+	}
+
+	return nil
+}
+
+// resize charges the tenant of key for its new size, it must be called with t.mu held
+func (t *Tracker) resize(key string, size uint64) {
+	previous := t.charges[key]
+	if previous.size == size {
+		return
+	}
+
+	usage := t.usage[previous.tenant]
+	usage.Bytes = usage.Bytes - previous.size + size
+
+	t.seq++
+	t.charges[key] = charge{tenant: previous.tenant, size: size, seq: t.seq, after: previous.after}
+	t.usage[previous.tenant] = usage
+}
+
+// restore charges key as it was before a reservation rolled back, it must be called with t.mu held
+func (t *Tracker) restore(key string, previous charge) {
+	usage := t.usage[previous.tenant]
+	usage.Entries++
+	usage.Bytes += previous.size
+
+	t.seq++
+	t.charges[key] = charge{tenant: previous.tenant, size: previous.size, seq: t.seq, after: previous.after}
+	t.usage[previous.tenant] = usage
+}
+
+// release must be called with t.mu held
+func (t *Tracker) release(key string) {
+	previous, ok := t.charges[key]
+	if !ok {
+		return
+	}
+
+	delete(t.charges, key)
+
+	usage := t.usage[previous.tenant]
+	usage.Entries--
+	usage.Bytes -= previous.size
+
+	if usage.Entries == 0 {
+		delete(t.usage, previous.tenant)
+	} else {
+		t.usage[previous.tenant] = usage
+	}
+}
+
+func (t *Tracker) limits(tenant string) Limits {
+	if limits, ok := t.config.Tenants[tenant]; ok {
+		return limits
+	}
+
+	return t.config.Default
+}
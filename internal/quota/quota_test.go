@@ -0,0 +1,184 @@
+package quota
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/skantay/lru-api/internal/cache"
+	"github.com/skantay/lru-api/internal/cache/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReserve(t *testing.T) {
+	tracker := New(Config{
+		Default: Limits{Entries: 2, Bytes: 100},
+		Tenants: map[string]Limits{"big": {Entries: 10}},
+	})
+
+	assert.NoError(t, reserve(tracker, "alice", "a", 10))
+	assert.NoError(t, reserve(tracker, "alice", "b", 10))
+	assert.ErrorIs(t, reserve(tracker, "alice", "c", 10), ErrExceeded)
+
+	// Overwriting a key does not take another entry
+	assert.NoError(t, reserve(tracker, "alice", "b", 90))
+	assert.ErrorIs(t, reserve(tracker, "alice", "b", 91), ErrExceeded)
+	assert.Equal(t, Usage{Entries: 2, Bytes: 100}, tracker.Usage("alice"))
+
+	for _, key := range []string{"c", "d", "e"} {
+		assert.NoError(t, reserve(tracker, "big", key, 1000))
+	}
+
+	// A key written by another tenant is charged to it
+	assert.NoError(t, reserve(tracker, "big", "a", 1))
+	assert.Equal(t, Usage{Entries: 1, Bytes: 90}, tracker.Usage("alice"))
+	assert.Equal(t, Usage{Entries: 4, Bytes: 3001}, tracker.Usage("big"))
+
+	tracker.Release("b")
+	assert.Equal(t, Usage{}, tracker.Usage("alice"))
+}
+
+func TestRollback(t *testing.T) {
+	tracker := New(Config{})
+
+	assert.NoError(t, reserve(tracker, "alice", "a", 10))
+
+	// A failed overwrite restores the previous charge
+	rollback, err := tracker.Reserve("alice", "a", 50)
+	assert.NoError(t, err)
+
+	rollback()
+	assert.Equal(t, Usage{Entries: 1, Bytes: 10}, tracker.Usage("alice"))
+
+	rollback, err = tracker.Reserve("bob", "a", 20)
+	assert.NoError(t, err)
+
+	rollback()
+	assert.Equal(t, Usage{Entries: 1, Bytes: 10}, tracker.Usage("alice"))
+	assert.Equal(t, Usage{}, tracker.Usage("bob"))
+
+	// A failed create drops the charge
+	rollback, err = tracker.Reserve("alice", "b", 10)
+	assert.NoError(t, err)
+
+	rollback()
+	assert.Equal(t, Usage{Entries: 1, Bytes: 10}, tracker.Usage("alice"))
+
+	// A key charged again meanwhile is left to the newer write
+	rollback, err = tracker.Reserve("alice", "a", 30)
+	assert.NoError(t, err)
+	assert.NoError(t, reserve(tracker, "bob", "a", 40))
+
+	rollback()
+	assert.Equal(t, Usage{}, tracker.Usage("alice"))
+	assert.Equal(t, Usage{Entries: 1, Bytes: 40}, tracker.Usage("bob"))
+}
+
+// reserve reserves without keeping the rollback
+func reserve(tracker *Tracker, tenant, key string, size uint64) error {
+	_, err := tracker.Reserve(tenant, key, size)
+
+	return err
+}
+
+func TestParseTenants(t *testing.T) {
+	tenants, err := ParseTenants([]string{"team-a:1000:1048576", " team-b:0:10 "})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]Limits{
+		"team-a": {Entries: 1000, Bytes: 1048576},
+		"team-b": {Entries: 0, Bytes: 10},
+	}, tenants)
+
+	for _, entry := range []string{"team-a", "team-a:1", ":1:1", "team-a:-1:1", "team-a:1:x"} {
+		_, err := ParseTenants([]string{entry})
+		assert.Error(t, err, entry)
+	}
+}
+
+func TestFollow(t *testing.T) {
+	c, err := cache.New(2, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	tracker := New(Config{Default: Limits{Entries: 10}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go tracker.Follow(ctx, c, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	// Let the tracker subscribe before changing the cache
+	time.Sleep(10 * time.Millisecond)
+
+	for _, key := range []string{"a", "b"} {
+		assert.NoError(t, reserve(tracker, "alice", key, cache.SizeOf(key, "value")))
+		assert.NoError(t, c.Put(ctx, key, "value", 0))
+	}
+
+	// Overwritten through another protocol
+	assert.NoError(t, c.Put(ctx, "b", "longer value", 0))
+
+	assert.Eventually(t, func() bool {
+		return tracker.Usage("alice") == Usage{Entries: 2, Bytes: cache.SizeOf("a", "value") + cache.SizeOf("b", "longer value")}
+	}, time.Second, time.Millisecond)
+
+	// Evicted by capacity
+	assert.NoError(t, c.Put(ctx, "c", "value", 0))
+
+	assert.Eventually(t, func() bool {
+		return tracker.Usage("alice") == Usage{Entries: 1, Bytes: cache.SizeOf("b", "longer value")}
+	}, time.Second, time.Millisecond)
+
+	assert.NoError(t, c.EvictAll(ctx))
+
+	assert.Eventually(t, func() bool {
+		return tracker.Usage("alice") == Usage{}
+	}, time.Second, time.Millisecond)
+}
+
+func TestLateEvents(t *testing.T) {
+	c, err := cache.New(10, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	tracker := New(Config{})
+	tracker.source = c
+
+	ctx := context.Background()
+
+	sub := c.Subscribe(cache.Filter{}, 0)
+	defer sub.Close()
+
+	assert.NoError(t, c.Put(ctx, "a", "value", 0))
+	_, err = c.Evict(ctx, "a")
+	assert.NoError(t, err)
+	assert.NoError(t, c.EvictAll(ctx))
+
+	// The key is reserved again before the events of its previous value are delivered
+	assert.NoError(t, reserve(tracker, "alice", "a", cache.SizeOf("a", "new value")))
+	assert.NoError(t, c.Put(ctx, "a", "new value", 0))
+
+	for range 4 {
+		tracker.apply(<-sub.C)
+	}
+
+	assert.Equal(t, Usage{Entries: 1, Bytes: cache.SizeOf("a", "new value")}, tracker.Usage("alice"))
+}
+
+func TestReconcile(t *testing.T) {
+	c, err := cache.New(10, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	tracker := New(Config{})
+	ctx := context.Background()
+
+	for _, key := range []string{"a", "b"} {
+		assert.NoError(t, reserve(tracker, "alice", key, cache.SizeOf(key, "value")))
+	}
+
+	// b was never stored, a was overwritten without the tracker seeing it
+	assert.NoError(t, c.Put(ctx, "a", "longer value", 0))
+
+	assert.NoError(t, tracker.reconcile(ctx, c))
+	assert.Equal(t, Usage{Entries: 1, Bytes: cache.SizeOf("a", "longer value")}, tracker.Usage("alice"))
+}
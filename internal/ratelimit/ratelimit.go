@@ -0,0 +1,128 @@
+// Package ratelimit limits the request rate of clients with a token bucket per client.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// How often buckets of idle clients are dropped
+const sweepInterval = time.Minute
+
+// Limit of a single client
+type Limit struct {
+	// Rate of requests per second the bucket is refilled with
+	Rate float64
+	// Burst is the size of the bucket, requests a client may make at once.
+	// If it is not set, it is Rate rounded up.
+	Burst int
+}
+
+// Result of a request
+type Result struct {
+	Allowed bool
+	// Limit is the size of the bucket
+	Limit int
+	// Remaining requests the client may make at once
+	Remaining int
+	// Reset is the time until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed, zero if the request is allowed
+	RetryAfter time.Duration
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// Limiter keeps a token bucket per client
+type Limiter struct {
+	limit Limit
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+
+	now func() time.Time
+}
+
+// New creates a limiter allowing every client limit.Rate requests per second with bursts of limit.Burst.
+func New(limit Limit) *Limiter {
+	if limit.Burst < 1 {
+		limit.Burst = max(int(math.Ceil(limit.Rate)), 1)
+	}
+
+	return &Limiter{
+		limit:   limit,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token from the bucket of the client if there is one
+func (l *Limiter) Allow(client string) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(rate.Limit(l.limit.Rate), l.limit.Burst)}
+		l.buckets[client] = b
+	}
+
+	b.lastSeen = now
+
+	result := Result{Allowed: true, Limit: l.limit.Burst}
+
+	reservation := b.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); !reservation.OK() || delay > 0 {
+		reservation.CancelAt(now)
+
+		result.Allowed = false
+		result.RetryAfter = delay
+	}
+
+	tokens := b.limiter.TokensAt(now)
+	if tokens > 0 {
+		result.Remaining = int(tokens)
+	}
+
+	result.Reset = l.refill(float64(l.limit.Burst) - tokens)
+
+	return result
+}
+
+// refill returns the time it takes to refill tokens
+func (l *Limiter) refill(tokens float64) time.Duration {
+	if tokens <= 0 || l.limit.Rate <= 0 {
+		return 0
+	}
+
+	return time.Duration(tokens / l.limit.Rate * float64(time.Second))
+}
+
+// sweep drops buckets that have been refilled since they were last used, they are equal to new ones.
+// It must be called with l.mu held.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval || l.limit.Rate <= 0 {
+		return
+	}
+
+	l.lastSweep = now
+
+	full := l.refill(float64(l.limit.Burst))
+
+	for client, b := range l.buckets {
+		if now.Sub(b.lastSeen) > full {
+			delete(l.buckets, client)
+		}
+	}
+}
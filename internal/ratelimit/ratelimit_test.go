@@ -0,0 +1,59 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllow(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	limiter := New(Limit{Rate: 2, Burst: 3})
+	limiter.now = func() time.Time { return now }
+
+	for remaining := 2; remaining >= 0; remaining-- {
+		result := limiter.Allow("alice")
+
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 3, result.Limit)
+		assert.Equal(t, remaining, result.Remaining)
+	}
+
+	result := limiter.Allow("alice")
+
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+	assert.Equal(t, 500*time.Millisecond, result.RetryAfter)
+	assert.Equal(t, 1500*time.Millisecond, result.Reset)
+
+	// Buckets are per client
+	assert.True(t, limiter.Allow("bob").Allowed)
+
+	now = now.Add(500 * time.Millisecond)
+
+	assert.True(t, limiter.Allow("alice").Allowed)
+	assert.False(t, limiter.Allow("alice").Allowed)
+}
+
+func TestBurst(t *testing.T) {
+	assert.Equal(t, 3, New(Limit{Rate: 2.5}).limit.Burst)
+	assert.Equal(t, 1, New(Limit{Rate: 0.1}).limit.Burst)
+}
+
+func TestSweep(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	limiter := New(Limit{Rate: 1, Burst: 10})
+	limiter.now = func() time.Time { return now }
+
+	limiter.Allow("alice")
+	limiter.Allow("bob")
+
+	now = now.Add(sweepInterval)
+	limiter.Allow("bob")
+
+	assert.Len(t, limiter.buckets, 1)
+	assert.Contains(t, limiter.buckets, "bob")
+}
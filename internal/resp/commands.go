@@ -0,0 +1,632 @@
+package resp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skantay/lru-api/internal/cache"
+	"github.com/skantay/lru-api/internal/glob"
+)
+
+// Version reported by HELLO and INFO
+const serverVersion = "7.0.0-lru-api"
+
+const (
+	errSyntax     = "ERR syntax error"
+	errNotInteger = "ERR value is not an integer or out of range"
+	errExpire     = "ERR invalid expire time in 'set' command"
+)
+
+type command struct {
+	// arity is the exact number of arguments including the command name,
+	// a negative arity is the minimum number of arguments
+	arity   int
+	handler func(s *Server, ctx context.Context, c *conn, args []string)
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"PING":    {-1, (*Server).ping},
+		"ECHO":    {2, (*Server).echo},
+		"HELLO":   {-1, (*Server).hello},
+		"QUIT":    {-1, (*Server).quit},
+		"SELECT":  {2, (*Server).selectDB},
+		"CLIENT":  {-2, (*Server).client},
+		"COMMAND": {-1, (*Server).command},
+		"INFO":    {-1, (*Server).info},
+		"GET":     {2, (*Server).get},
+		"SET":     {-3, (*Server).set},
+		"DEL":     {-2, (*Server).del},
+		"EXISTS":  {-2, (*Server).exists},
+		"TTL":     {2, (*Server).ttl},
+		"EXPIRE":  {3, (*Server).expire},
+		"FLUSHDB": {-1, (*Server).flushDB},
+		"KEYS":    {2, (*Server).keys},
+		"SCAN":    {-2, (*Server).scan},
+		"MGET":    {-2, (*Server).mget},
+		"MSET":    {-3, (*Server).mset},
+		"INCR":    {2, (*Server).incr},
+	}
+}
+
+func (s *Server) ping(_ context.Context, c *conn, args []string) {
+	switch len(args) {
+	case 0:
+		c.w.simple("PONG")
+	case 1:
+		c.w.bulk(args[0])
+	default:
+		c.w.error("ERR wrong number of arguments for 'ping' command")
+	}
+}
+
+func (s *Server) echo(_ context.Context, c *conn, args []string) {
+	c.w.bulk(args[0])
+}
+
+// hello switches the protocol version and replies with server properties
+func (s *Server) hello(_ context.Context, c *conn, args []string) {
+	if len(args) > 0 {
+		proto, err := strconv.Atoi(args[0])
+		if err != nil || proto < 2 || proto > 3 {
+			c.w.error("NOPROTO unsupported protocol version")
+
+			return
+		}
+
+		c.w.proto = proto
+	}
+
+	c.w.mapHeader(6)
+	c.w.bulk("server")
+	c.w.bulk("redis")
+	c.w.bulk("version")
+	c.w.bulk(serverVersion)
+	c.w.bulk("proto")
+	c.w.integer(int64(c.w.proto))
+	c.w.bulk("mode")
+	c.w.bulk("standalone")
+	c.w.bulk("role")
+	c.w.bulk("master")
+	c.w.bulk("modules")
+	c.w.array(0)
+}
+
+func (s *Server) quit(_ context.Context, c *conn, _ []string) {
+	c.w.simple("OK")
+	c.quit = true
+}
+
+// selectDB accepts only database 0, clients often select it explicitly
+func (s *Server) selectDB(_ context.Context, c *conn, args []string) {
+	if args[0] != "0" {
+		c.w.error("ERR DB index is out of range")
+
+		return
+	}
+
+	c.w.simple("OK")
+}
+
+// client accepts CLIENT SETNAME/SETINFO sent by client libraries on connect and ignores them
+func (s *Server) client(_ context.Context, c *conn, args []string) {
+	switch strings.ToUpper(args[0]) {
+	case "SETNAME", "SETINFO":
+		c.w.simple("OK")
+	default:
+		c.w.error("ERR unknown subcommand '" + args[0] + "'")
+	}
+}
+
+// command replies with an empty command table, redis-cli requests it on startup
+func (s *Server) command(_ context.Context, c *conn, _ []string) {
+	c.w.array(0)
+}
+
+func (s *Server) info(ctx context.Context, c *conn, _ []string) {
+	keys, _, err := s.cache.GetAll(ctx)
+	if err != nil {
+		c.w.error("ERR " + err.Error())
+
+		return
+	}
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Server\r\nredis_version:%s\r\nprocess_id:%d\r\nuptime_in_seconds:%d\r\n",
+		serverVersion, os.Getpid(), int64(time.Since(s.started).Seconds()))
+	fmt.Fprintf(&b, "\r\n# Clients\r\nconnected_clients:%d\r\n", s.connected.Load())
+	fmt.Fprintf(&b, "\r\n# Stats\r\ntotal_connections_received:%d\r\ntotal_commands_processed:%d\r\n",
+		s.totalConns.Load(), s.totalCommands.Load())
+	fmt.Fprintf(&b, "\r\n# Keyspace\r\ndb0:keys=%d,expires=%d\r\n", len(keys), len(keys))
+
+	c.w.bulk(b.String())
+}
+
+func (s *Server) get(ctx context.Context, c *conn, args []string) {
+	value, _, err := s.cache.Get(ctx, args[0])
+	if err != nil {
+		s.replyError(c, err)
+
+		return
+	}
+
+	c.w.bulk(format(value))
+}
+
+// set implements SET key value [NX | XX] [GET] [EX seconds | PX milliseconds | KEEPTTL]
+func (s *Server) set(ctx context.Context, c *conn, args []string) {
+	key, value := args[0], args[1]
+
+	var (
+		nx, xx, get, keepTTL bool
+		ttl                  time.Duration
+	)
+
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GET":
+			get = true
+		case "KEEPTTL":
+			keepTTL = true
+		case "EX", "PX":
+			if i+1 == len(args) || ttl != 0 {
+				c.w.error(errSyntax)
+
+				return
+			}
+
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				c.w.error(errNotInteger)
+
+				return
+			}
+
+			if n <= 0 {
+				c.w.error(errExpire)
+
+				return
+			}
+
+			unit := time.Second
+			if strings.ToUpper(args[i]) == "PX" {
+				unit = time.Millisecond
+			}
+
+			if n > math.MaxInt64/int64(unit) {
+				c.w.error(errExpire)
+
+				return
+			}
+
+			ttl = time.Duration(n) * unit
+			i++
+		default:
+			c.w.error(errSyntax)
+
+			return
+		}
+	}
+
+	if (nx && xx) || (keepTTL && ttl != 0) {
+		c.w.error(errSyntax)
+
+		return
+	}
+
+	if keepTTL {
+		ttl = cache.KeepTTL
+	}
+
+	var (
+		stored   bool
+		previous interface{}
+		existed  bool
+	)
+
+	err := s.cache.Update(ctx, key, func(old interface{}, _ time.Time, ok bool) (interface{}, time.Duration, bool) {
+		previous, existed = old, ok
+
+		if (nx && ok) || (xx && !ok) {
+			return nil, 0, false
+		}
+
+		stored = true
+
+		return value, ttl, true
+	})
+	if err != nil {
+		s.replyError(c, err)
+
+		return
+	}
+
+	switch {
+	case get && existed:
+		c.w.bulk(format(previous))
+	case get, !stored:
+		c.w.null()
+	default:
+		c.w.simple("OK")
+	}
+}
+
+func (s *Server) del(ctx context.Context, c *conn, args []string) {
+	var deleted int64
+
+	for _, key := range args {
+		if _, err := s.cache.Evict(ctx, key); err != nil {
+			if errors.Is(err, cache.ErrKeyDoesNotExist) {
+				continue
+			}
+
+			s.replyError(c, err)
+
+			return
+		}
+
+		deleted++
+	}
+
+	c.w.integer(deleted)
+}
+
+func (s *Server) exists(ctx context.Context, c *conn, args []string) {
+	var found int64
+
+	for _, key := range args {
+		_, ok, err := s.peek(ctx, key)
+		if err != nil {
+			s.replyError(c, err)
+
+			return
+		}
+
+		if ok {
+			found++
+		}
+	}
+
+	c.w.integer(found)
+}
+
+// ttl replies with the remaining time to live in seconds, or -2 if the key does not exist.
+// Every node/item of LRU cache expires, so -1 is never returned.
+func (s *Server) ttl(ctx context.Context, c *conn, args []string) {
+	expiresAt, ok, err := s.peek(ctx, args[0])
+	if err != nil {
+		s.replyError(c, err)
+
+		return
+	}
+
+	if !ok {
+		c.w.integer(-2)
+
+		return
+	}
+
+	c.w.integer(int64(math.Round(time.Until(expiresAt).Seconds())))
+}
+
+func (s *Server) expire(ctx context.Context, c *conn, args []string) {
+	seconds, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		c.w.error(errNotInteger)
+
+		return
+	}
+
+	if seconds <= 0 {
+		if _, err := s.cache.Evict(ctx, args[0]); err != nil {
+			if errors.Is(err, cache.ErrKeyDoesNotExist) {
+				c.w.integer(0)
+
+				return
+			}
+
+			s.replyError(c, err)
+
+			return
+		}
+
+		c.w.integer(1)
+
+		return
+	}
+
+	if seconds > math.MaxInt64/int64(time.Second) {
+		c.w.error("ERR invalid expire time in 'expire' command")
+
+		return
+	}
+
+	var found bool
+
+	err = s.cache.Update(ctx, args[0], func(value interface{}, _ time.Time, ok bool) (interface{}, time.Duration, bool) {
+		found = ok
+
+		return value, time.Duration(seconds) * time.Second, ok
+	})
+	if err != nil {
+		s.replyError(c, err)
+
+		return
+	}
+
+	if found {
+		c.w.integer(1)
+	} else {
+		c.w.integer(0)
+	}
+}
+
+// flushDB accepts the ASYNC and SYNC modifiers, flushing is always synchronous
+func (s *Server) flushDB(ctx context.Context, c *conn, args []string) {
+	if len(args) > 1 {
+		c.w.error(errSyntax)
+
+		return
+	}
+
+	if err := s.cache.EvictAll(ctx); err != nil {
+		s.replyError(c, err)
+
+		return
+	}
+
+	c.w.simple("OK")
+}
+
+func (s *Server) keys(ctx context.Context, c *conn, args []string) {
+	keys, err := s.matchingKeys(ctx, args[0])
+	if err != nil {
+		s.replyError(c, err)
+
+		return
+	}
+
+	c.w.bulks(keys)
+}
+
+// scan implements SCAN cursor [MATCH pattern] [COUNT count].
+// The cursor is an offset into the sorted list of keys, so keys added or removed between calls
+// may be skipped or returned twice, the same guarantee Redis gives for keys modified during a scan.
+func (s *Server) scan(ctx context.Context, c *conn, args []string) {
+	cursor, err := strconv.Atoi(args[0])
+	if err != nil || cursor < 0 {
+		c.w.error("ERR invalid cursor")
+
+		return
+	}
+
+	pattern, count := "*", 10
+
+	for i := 1; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			c.w.error(errSyntax)
+
+			return
+		}
+
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			pattern = args[i+1]
+		case "COUNT":
+			count, err = strconv.Atoi(args[i+1])
+			if err != nil || count < 1 {
+				c.w.error(errSyntax)
+
+				return
+			}
+		default:
+			c.w.error(errSyntax)
+
+			return
+		}
+	}
+
+	keys, _, err := s.cache.GetAll(ctx)
+	if err != nil {
+		s.replyError(c, err)
+
+		return
+	}
+
+	sort.Strings(keys)
+
+	var page []string
+
+	next := cursor
+	for ; next < len(keys) && next < cursor+count; next++ {
+		if glob.Match(pattern, keys[next]) {
+			page = append(page, keys[next])
+		}
+	}
+
+	if next >= len(keys) {
+		next = 0
+	}
+
+	c.w.array(2)
+	c.w.bulk(strconv.Itoa(next))
+	c.w.bulks(page)
+}
+
+func (s *Server) mget(ctx context.Context, c *conn, args []string) {
+	c.w.array(len(args))
+
+	for _, key := range args {
+		value, _, err := s.cache.Get(ctx, key)
+		if err != nil {
+			c.w.null()
+
+			continue
+		}
+
+		c.w.bulk(format(value))
+	}
+}
+
+func (s *Server) mset(ctx context.Context, c *conn, args []string) {
+	if len(args)%2 != 0 {
+		c.w.error("ERR wrong number of arguments for 'mset' command")
+
+		return
+	}
+
+	for i := 0; i < len(args); i += 2 {
+		if err := s.cache.Put(ctx, args[i], args[i+1], 0); err != nil {
+			s.replyError(c, err)
+
+			return
+		}
+	}
+
+	c.w.simple("OK")
+}
+
+// incr increments an integer value, a missing key is created with the default TTL,
+// an existing one keeps its expiration time
+func (s *Server) incr(ctx context.Context, c *conn, args []string) {
+	var (
+		result int64
+		failed bool
+	)
+
+	err := s.cache.Update(ctx, args[0], func(value interface{}, _ time.Time, ok bool) (interface{}, time.Duration, bool) {
+		if !ok {
+			result = 1
+
+			return result, 0, true
+		}
+
+		n, valid := toInt(value)
+		if !valid || n == math.MaxInt64 {
+			failed = true
+
+			return nil, 0, false
+		}
+
+		result = n + 1
+
+		return result, cache.KeepTTL, true
+	})
+	if err != nil {
+		s.replyError(c, err)
+
+		return
+	}
+
+	if failed {
+		c.w.error(errNotInteger)
+
+		return
+	}
+
+	c.w.integer(result)
+}
+
+// peek looks a key up without moving it to the front of LRU cache
+func (s *Server) peek(ctx context.Context, key string) (expiresAt time.Time, found bool, err error) {
+	err = s.cache.Update(ctx, key, func(_ interface{}, exp time.Time, ok bool) (interface{}, time.Duration, bool) {
+		expiresAt, found = exp, ok
+
+		return nil, 0, false
+	})
+
+	return
+}
+
+func (s *Server) matchingKeys(ctx context.Context, pattern string) ([]string, error) {
+	keys, _, err := s.cache.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := keys[:0]
+
+	for _, key := range keys {
+		if glob.Match(pattern, key) {
+			matched = append(matched, key)
+		}
+	}
+
+	sort.Strings(matched)
+
+	return matched, nil
+}
+
+func (s *Server) replyError(c *conn, err error) {
+	if errors.Is(err, cache.ErrKeyDoesNotExist) {
+		c.w.null()
+
+		return
+	}
+
+	s.log.Error(err.Error())
+	c.w.error("ERR " + err.Error())
+}
+
+// format converts a cached value to a bulk string.
+// Values stored through RESP are strings, values stored through the HTTP API are encoded as JSON,
+// except for blobs, which are returned verbatim.
+func format(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case cache.Blob:
+		return string(v.Data)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+
+	return string(data)
+}
+
+func toInt(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case float64:
+		if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+			return 0, false
+		}
+
+		return int64(v), true
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+
+		return n, err == nil
+	}
+
+	return 0, false
+}
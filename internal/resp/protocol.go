@@ -0,0 +1,169 @@
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Largest bulk string accepted from clients
+	maxBulkLength = 16 << 20
+	// Largest number of arguments of a single command
+	maxArgs = 1 << 16
+	// Largest command, the bulk strings of its arguments together
+	maxCommandLength = 64 << 20
+	// Longest inline command
+	maxInlineLength = 64 << 10
+)
+
+var errProtocol = errors.New("Protocol error")
+
+// reader parses commands sent by clients.
+// Clients normally send arrays of bulk strings, inline commands (telnet) are supported as well.
+type reader struct {
+	r *bufio.Reader
+}
+
+// readCommand reads the next command, an empty command is returned for empty inline lines
+func (r *reader) readCommand() ([]string, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) == 0 || line[0] != '*' {
+		return strings.Fields(line), nil
+	}
+
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n > maxArgs {
+		return nil, fmt.Errorf("%w: invalid multibulk length", errProtocol)
+	}
+
+	// Null (*-1) and empty arrays are ignored like empty inline lines, as Redis does
+	if n <= 0 {
+		return nil, nil
+	}
+
+	args := make([]string, 0, n)
+	total := 0
+
+	for i := 0; i < n; i++ {
+		line, err := r.readLine()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(line) == 0 || line[0] != '$' {
+			return nil, fmt.Errorf("%w: expected '$', got '%.1s'", errProtocol, line)
+		}
+
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 || size > maxBulkLength {
+			return nil, fmt.Errorf("%w: invalid bulk length", errProtocol)
+		}
+
+		if total += size; total > maxCommandLength {
+			return nil, fmt.Errorf("%w: too big multibulk request", errProtocol)
+		}
+
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r.r, data); err != nil {
+			return nil, err
+		}
+
+		if data[size] != '\r' || data[size+1] != '\n' {
+			return nil, fmt.Errorf("%w: bulk string is not terminated by CRLF", errProtocol)
+		}
+
+		args = append(args, string(data[:size]))
+	}
+
+	return args, nil
+}
+
+func (r *reader) readLine() (string, error) {
+	line, err := r.r.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) || len(line) > maxInlineLength {
+		return "", fmt.Errorf("%w: too big inline request", errProtocol)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+// writer encodes replies in RESP2 or RESP3, depending on the protocol negotiated with HELLO
+type writer struct {
+	w     *bufio.Writer
+	proto int
+}
+
+func (w *writer) simple(s string) {
+	w.w.WriteByte('+')
+	w.w.WriteString(s)
+	w.w.WriteString("\r\n")
+}
+
+func (w *writer) error(s string) {
+	w.w.WriteByte('-')
+	w.w.WriteString(s)
+	w.w.WriteString("\r\n")
+}
+
+func (w *writer) integer(n int64) {
+	w.w.WriteByte(':')
+	w.w.WriteString(strconv.FormatInt(n, 10))
+	w.w.WriteString("\r\n")
+}
+
+func (w *writer) bulk(s string) {
+	w.w.WriteByte('$')
+	w.w.WriteString(strconv.Itoa(len(s)))
+	w.w.WriteString("\r\n")
+	w.w.WriteString(s)
+	w.w.WriteString("\r\n")
+}
+
+func (w *writer) null() {
+	if w.proto == 3 {
+		w.w.WriteString("_\r\n")
+
+		return
+	}
+
+	w.w.WriteString("$-1\r\n")
+}
+
+func (w *writer) array(n int) {
+	w.w.WriteByte('*')
+	w.w.WriteString(strconv.Itoa(n))
+	w.w.WriteString("\r\n")
+}
+
+// mapHeader starts a map of n pairs, in RESP2 it is a flat array of 2*n elements
+func (w *writer) mapHeader(n int) {
+	if w.proto == 3 {
+		w.w.WriteByte('%')
+		w.w.WriteString(strconv.Itoa(n))
+		w.w.WriteString("\r\n")
+
+		return
+	}
+
+	w.array(n * 2)
+}
+
+func (w *writer) bulks(values []string) {
+	w.array(len(values))
+
+	for _, v := range values {
+		w.bulk(v)
+	}
+}
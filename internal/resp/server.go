@@ -0,0 +1,178 @@
+// Package resp provides a TCP listener speaking the Redis serialization protocol (RESP2 and RESP3).
+// A subset of Redis commands is mapped onto the LRU cache, so redis-cli and Redis client libraries
+// can talk to lru-api directly.
+package resp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/skantay/lru-api/internal/audit"
+	"github.com/skantay/lru-api/internal/cache"
+	"github.com/skantay/lru-api/internal/listener"
+)
+
+// Cache is the subset of LRUCache used by the listener
+type Cache interface {
+	Put(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+	Get(ctx context.Context, key string) (value interface{}, expiresAt time.Time, err error)
+	GetAll(ctx context.Context) (keys []string, values []interface{}, err error)
+	Evict(ctx context.Context, key string) (value interface{}, err error)
+	EvictAll(ctx context.Context) error
+	Update(ctx context.Context, key string, fn cache.UpdateFunc) error
+}
+
+// Config of the RESP listener
+type Config struct {
+	Addr string
+	// MaxConns limits concurrent client connections, 0 means no limit
+	MaxConns int
+	// IdleTimeout closes connections that did not send a command for this long, 0 means no timeout
+	IdleTimeout time.Duration
+}
+
+// Server is a RESP listener
+type Server struct {
+	cache  Cache
+	log    *slog.Logger
+	config Config
+
+	listener *listener.Server
+	started  time.Time
+
+	connected     atomic.Int64
+	totalConns    atomic.Int64
+	totalCommands atomic.Int64
+}
+
+// New creates a new RESP listener backed by the cache.
+func New(cache Cache, log *slog.Logger, config Config) *Server {
+	s := &Server{
+		cache:  cache,
+		log:    log,
+		config: config,
+	}
+
+	s.listener = &listener.Server{
+		Addr:     config.Addr,
+		Handler:  s.serve,
+		MaxConns: config.MaxConns,
+		Reject: func(conn net.Conn) {
+			conn.SetWriteDeadline(time.Now().Add(time.Second))
+			io.WriteString(conn, "-ERR max number of clients reached\r\n")
+		},
+		Log: log,
+	}
+
+	return s
+}
+
+// ListenAndServe serves clients until Shutdown, then it returns listener.ErrServerClosed.
+func (s *Server) ListenAndServe() error {
+	s.started = time.Now()
+
+	return s.listener.ListenAndServe()
+}
+
+// Serve serves clients accepted on l until Shutdown.
+func (s *Server) Serve(l net.Listener) error {
+	s.started = time.Now()
+
+	return s.listener.Serve(l)
+}
+
+// Shutdown stops accepting clients and waits for open connections to finish their current command.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.listener.Shutdown(ctx)
+}
+
+// conn is the state of a single client connection
+type conn struct {
+	r    reader
+	w    writer
+	quit bool
+}
+
+func (s *Server) serve(ctx context.Context, netConn net.Conn) {
+	s.connected.Add(1)
+	s.totalConns.Add(1)
+	defer s.connected.Add(-1)
+
+	// Unblock a pending read on shutdown, the current command is completed first
+	stop := context.AfterFunc(ctx, func() {
+		netConn.SetReadDeadline(time.Now())
+	})
+	defer stop()
+
+	// Writes are audited as the client's, there is no principal to record
+	ctx = audit.WithActor(ctx, "resp", netConn.RemoteAddr().String())
+
+	c := &conn{
+		r: reader{r: bufio.NewReaderSize(netConn, maxInlineLength)},
+		w: writer{w: bufio.NewWriter(netConn), proto: 2},
+	}
+
+	for !c.quit {
+		if s.config.IdleTimeout > 0 && ctx.Err() == nil {
+			netConn.SetReadDeadline(time.Now().Add(s.config.IdleTimeout))
+		}
+
+		args, err := c.r.readCommand()
+		if err != nil {
+			if errors.Is(err, errProtocol) {
+				c.w.error("ERR " + err.Error())
+				c.w.w.Flush()
+			} else if !errors.Is(err, io.EOF) && !errors.Is(err, os.ErrDeadlineExceeded) {
+				s.log.Debug("resp read failed", "remote", netConn.RemoteAddr().String(), "error", err.Error())
+			}
+
+			return
+		}
+
+		if len(args) == 0 {
+			continue
+		}
+
+		s.totalCommands.Add(1)
+		s.execute(ctx, c, args)
+
+		// Replies to pipelined commands are flushed together
+		if c.r.r.Buffered() == 0 || c.quit {
+			if err := c.w.w.Flush(); err != nil {
+				s.log.Debug("resp write failed", "remote", netConn.RemoteAddr().String(), "error", err.Error())
+
+				return
+			}
+		}
+	}
+}
+
+func (s *Server) execute(ctx context.Context, c *conn, args []string) {
+	name := strings.ToUpper(args[0])
+
+	cmd, ok := commands[name]
+	if !ok {
+		c.w.error("ERR unknown command '" + args[0] + "'")
+
+		return
+	}
+
+	if (cmd.arity > 0 && len(args) != cmd.arity) || (cmd.arity < 0 && len(args) < -cmd.arity) {
+		c.w.error("ERR wrong number of arguments for '" + strings.ToLower(name) + "' command")
+
+		return
+	}
+
+	// A command that has been read is completed even if shutdown has started
+	ctx = context.WithoutCancel(ctx)
+
+	cmd.handler(s, ctx, c, args[1:])
+}
@@ -0,0 +1,185 @@
+package resp
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skantay/lru-api/internal/audit"
+	"github.com/skantay/lru-api/internal/cache"
+	"github.com/skantay/lru-api/internal/cache/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestServer(t *testing.T) net.Conn {
+	c, err := cache.New(10, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	server := New(c, slog.New(slog.NewTextHandler(io.Discard, nil)), Config{MaxConns: 1})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	go server.Serve(l)
+
+	t.Cleanup(func() {
+		server.Shutdown(context.Background())
+	})
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	assert.NoError(t, err)
+
+	t.Cleanup(func() {
+		conn.Close()
+	})
+
+	return conn
+}
+
+// encode encodes a command as an array of bulk strings
+func encode(args ...string) string {
+	var b strings.Builder
+
+	w := writer{w: bufio.NewWriter(&b)}
+	w.bulks(args)
+	w.w.Flush()
+
+	return b.String()
+}
+
+func TestCommands(t *testing.T) {
+	conn := newTestServer(t)
+	r := bufio.NewReader(conn)
+
+	tests := []struct {
+		command  []string
+		expected string
+	}{
+		{[]string{"PING"}, "+PONG\r\n"},
+		{[]string{"GET", "a"}, "$-1\r\n"},
+		{[]string{"SET", "a", "1", "XX"}, "$-1\r\n"},
+		{[]string{"SET", "a", "1", "NX", "EX", "100"}, "+OK\r\n"},
+		{[]string{"SET", "a", "2", "NX"}, "$-1\r\n"},
+		{[]string{"TTL", "a"}, ":100\r\n"},
+		{[]string{"INCR", "a"}, ":2\r\n"},
+		{[]string{"TTL", "a"}, ":100\r\n"},
+		{[]string{"SET", "a", "x", "GET"}, "$1\r\n2\r\n"},
+		{[]string{"INCR", "a"}, "-" + errNotInteger + "\r\n"},
+		{[]string{"MSET", "b", "1", "c", "2"}, "+OK\r\n"},
+		{[]string{"MGET", "a", "b", "z"}, "*3\r\n$1\r\nx\r\n$1\r\n1\r\n$-1\r\n"},
+		{[]string{"EXISTS", "a", "b", "z"}, ":2\r\n"},
+		{[]string{"KEYS", "[ab]"}, "*2\r\n$1\r\na\r\n$1\r\nb\r\n"},
+		{[]string{"SCAN", "0", "COUNT", "2"}, "*2\r\n$1\r\n2\r\n*2\r\n$1\r\na\r\n$1\r\nb\r\n"},
+		{[]string{"SCAN", "2", "COUNT", "2"}, "*2\r\n$1\r\n0\r\n*1\r\n$1\r\nc\r\n"},
+		{[]string{"EXPIRE", "c", "0"}, ":1\r\n"},
+		{[]string{"EXPIRE", "c", "10"}, ":0\r\n"},
+		{[]string{"DEL", "a", "b", "c"}, ":2\r\n"},
+		{[]string{"TTL", "a"}, ":-2\r\n"},
+		{[]string{"FLUSHDB"}, "+OK\r\n"},
+		{[]string{"HELLO", "3"}, "%6\r\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(strings.Join(test.command, " "), func(t *testing.T) {
+			_, err := io.WriteString(conn, encode(test.command...))
+			assert.NoError(t, err)
+
+			reply := make([]byte, len(test.expected))
+			_, err = io.ReadFull(r, reply)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, string(reply))
+		})
+	}
+}
+
+func TestPipelining(t *testing.T) {
+	conn := newTestServer(t)
+
+	_, err := io.WriteString(conn, encode("SET", "a", "1")+encode("INCR", "a")+"GET a\r\n")
+	assert.NoError(t, err)
+
+	expected := "+OK\r\n:2\r\n$1\r\n2\r\n"
+
+	reply := make([]byte, len(expected))
+	_, err = io.ReadFull(conn, reply)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(reply))
+}
+
+func TestEmptyMultibulk(t *testing.T) {
+	conn := newTestServer(t)
+
+	// Null and empty arrays are skipped, the connection serves the next command
+	_, err := io.WriteString(conn, "*-1\r\n*0\r\n*-100\r\n"+encode("PING"))
+	assert.NoError(t, err)
+
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "+PONG\r\n", reply)
+}
+
+func TestMaxConnections(t *testing.T) {
+	conn := newTestServer(t)
+
+	_, err := io.WriteString(conn, encode("PING"))
+	assert.NoError(t, err)
+
+	_, err = bufio.NewReader(conn).ReadString('\n')
+	assert.NoError(t, err)
+
+	second, err := net.Dial("tcp", conn.RemoteAddr().String())
+	assert.NoError(t, err)
+	defer second.Close()
+
+	reply, err := bufio.NewReader(second).ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "-ERR max number of clients reached\r\n", reply)
+}
+
+func TestAudit(t *testing.T) {
+	auditLog, err := audit.Open(audit.Config{Path: filepath.Join(t.TempDir(), "audit.log")})
+	assert.NoError(t, err)
+
+	defer auditLog.Close()
+
+	c, err := cache.New(10, time.Minute, &mocks.Logger{}, cache.WithRecorder(auditLog))
+	assert.NoError(t, err)
+
+	server := New(c, slog.New(slog.NewTextHandler(io.Discard, nil)), Config{})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	go server.Serve(l)
+	defer server.Shutdown(context.Background())
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+
+	for _, command := range [][]string{{"SET", "a", "1"}, {"EXISTS", "a"}, {"FLUSHDB"}} {
+		_, err := io.WriteString(conn, encode(command...))
+		assert.NoError(t, err)
+
+		_, err = r.ReadString('\n')
+		assert.NoError(t, err)
+	}
+
+	records, err := auditLog.Query(audit.Filter{Listener: "resp"})
+	assert.NoError(t, err)
+
+	if assert.Len(t, records, 2) {
+		assert.Equal(t, audit.ActionCreate, records[0].Action)
+		assert.Equal(t, "a", records[0].Key)
+		assert.Equal(t, "127.0.0.1", records[0].ClientIP)
+		assert.Equal(t, audit.ActionFlush, records[1].Action)
+	}
+}
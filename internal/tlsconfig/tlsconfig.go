@@ -0,0 +1,258 @@
+// Package tlsconfig builds the TLS configuration of the HTTP server.
+// The certificate and the client CA are reloaded without a restart, new handshakes use the new files
+// while established connections are kept.
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client authentication modes of mutual TLS
+const (
+	// ClientAuthRequire rejects handshakes without a client certificate signed by the client CA
+	ClientAuthRequire = "require"
+	// ClientAuthOptional verifies client certificates if they are sent, clients may authenticate otherwise
+	ClientAuthOptional = "optional"
+)
+
+// Config of TLS
+type Config struct {
+	CertFile string
+	KeyFile  string
+
+	// MinVersion is "1.2" or "1.3", "1.2" if empty
+	MinVersion string
+	// CipherSuites are names of TLS 1.2 cipher suites, e.g. TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256.
+	// Go's defaults are used if empty, TLS 1.3 suites are not configurable.
+	CipherSuites []string
+
+	// ClientCAFile enables mutual TLS, client certificates must be signed by one of its PEM encoded CAs
+	ClientCAFile string
+	// ClientAuth is ClientAuthRequire or ClientAuthOptional, ClientAuthRequire if empty
+	ClientAuth string
+}
+
+type state struct {
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  []time.Time
+}
+
+// Reloader serves the current certificate and client CA to handshakes
+type Reloader struct {
+	config Config
+	base   *tls.Config
+
+	mu    sync.RWMutex
+	state state
+}
+
+// New loads the files of config.
+func New(config Config) (*Reloader, error) {
+	base := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ClientAuth: tls.NoClientCert,
+	}
+
+	switch config.MinVersion {
+	case "", "1.2":
+	case "1.3":
+		base.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("unsupported TLS version %q", config.MinVersion)
+	}
+
+	if len(config.CipherSuites) > 0 {
+		suites, err := cipherSuites(config.CipherSuites)
+		if err != nil {
+			return nil, err
+		}
+
+		base.CipherSuites = suites
+	}
+
+	if config.ClientCAFile != "" {
+		switch config.ClientAuth {
+		case "", ClientAuthRequire:
+			base.ClientAuth = tls.RequireAndVerifyClientCert
+		case ClientAuthOptional:
+			base.ClientAuth = tls.VerifyClientCertIfGiven
+		default:
+			return nil, fmt.Errorf("unknown client auth %q", config.ClientAuth)
+		}
+	}
+
+	r := &Reloader{
+		config: config,
+		base:   base,
+	}
+
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// TLSConfig returns the configuration of the server, it always uses the current files
+func (r *Reloader) TLSConfig() *tls.Config {
+	config := r.base.Clone()
+	config.GetCertificate = r.certificate
+	config.GetConfigForClient = r.configForClient
+
+	return config
+}
+
+func (r *Reloader) certificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.state.cert, nil
+}
+
+func (r *Reloader) configForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	r.mu.RLock()
+	state := r.state
+	r.mu.RUnlock()
+
+	config := r.base.Clone()
+	config.Certificates = []tls.Certificate{*state.cert}
+	config.ClientCAs = state.clientCAs
+
+	return config, nil
+}
+
+// Reload reads the files again, the previous ones are kept if they are invalid
+func (r *Reloader) Reload() error {
+	modTimes, err := r.modTimes()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.config.CertFile, r.config.KeyFile)
+	if err != nil {
+		return err
+	}
+
+	var clientCAs *x509.CertPool
+
+	if r.config.ClientCAFile != "" {
+		data, err := os.ReadFile(r.config.ClientCAFile)
+		if err != nil {
+			return err
+		}
+
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(data) {
+			return fmt.Errorf("client CA file %s contains no certificates", r.config.ClientCAFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.state = state{cert: &cert, clientCAs: clientCAs, modTimes: modTimes}
+	r.mu.Unlock()
+
+	return nil
+}
+
+// Watch reloads the files when they change until ctx is done.
+// Files are polled, so replacing them through a symlink, as Kubernetes does with secrets, is noticed too.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration, log *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		modTimes, err := r.modTimes()
+		if err != nil {
+			log.Warn("failed to check TLS files", "error", err.Error())
+
+			continue
+		}
+
+		r.mu.RLock()
+		changed := !equal(modTimes, r.state.modTimes)
+		r.mu.RUnlock()
+
+		if !changed {
+			continue
+		}
+
+		if err := r.Reload(); err != nil {
+			log.Error("failed to reload TLS files", "error", err.Error())
+
+			continue
+		}
+
+		log.Info("TLS files reloaded")
+	}
+}
+
+func (r *Reloader) modTimes() ([]time.Time, error) {
+	files := []string{r.config.CertFile, r.config.KeyFile}
+	if r.config.ClientCAFile != "" {
+		files = append(files, r.config.ClientCAFile)
+	}
+
+	modTimes := make([]time.Time, len(files))
+
+	for i, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+
+		modTimes[i] = info.ModTime()
+	}
+
+	return modTimes, nil
+}
+
+func equal(a, b []time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// cipherSuites resolves names of secure cipher suites, insecure ones are rejected
+func cipherSuites(names []string) ([]uint16, error) {
+	known := make(map[string]uint16)
+
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+
+	for _, name := range names {
+		id, ok := known[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,251 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type certificate struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+// issue creates a certificate signed by parent, a self-signed CA if parent is nil
+func issue(t *testing.T, name string, serial int64, parent *certificate) *certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	} else {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	assert.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+
+	return &certificate{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+func (c *certificate) write(t *testing.T, dir string) (certFile, keyFile string) {
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	assert.NoError(t, err)
+
+	certFile = filepath.Join(dir, "tls.crt")
+	keyFile = filepath.Join(dir, "tls.key")
+
+	assert.NoError(t, os.WriteFile(certFile, c.pem, 0o600))
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+
+	return certFile, keyFile
+}
+
+func (c *certificate) tls() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.cert.Raw}, PrivateKey: c.key}
+}
+
+func start(t *testing.T, reloader *Reloader) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) > 0 {
+			io.WriteString(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+		}
+	}))
+	server.TLS = reloader.TLSConfig()
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func newClient(ca *certificate, cert *certificate) *http.Client {
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	config := &tls.Config{RootCAs: roots}
+	if cert != nil {
+		// Sent even if the server does not accept its CA
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			c := cert.tls()
+
+			return &c, nil
+		}
+	}
+
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := issue(t, "ca", 1, nil)
+
+	certFile, keyFile := issue(t, "server", 2, ca).write(t, dir)
+
+	reloader, err := New(Config{CertFile: certFile, KeyFile: keyFile})
+	assert.NoError(t, err)
+
+	server := start(t, reloader)
+
+	serial := func(client *http.Client) int64 {
+		response, err := client.Get(server.URL)
+		if !assert.NoError(t, err) {
+			return 0
+		}
+		defer response.Body.Close()
+
+		return response.TLS.PeerCertificates[0].SerialNumber.Int64()
+	}
+
+	client := newClient(ca, nil)
+	assert.Equal(t, int64(2), serial(client))
+
+	issue(t, "server", 3, ca).write(t, dir)
+	assert.NoError(t, reloader.Reload())
+
+	// The established connection is kept, new connections get the new certificate
+	assert.Equal(t, int64(2), serial(client))
+	assert.Equal(t, int64(3), serial(newClient(ca, nil)))
+
+	// Invalid files do not replace the current certificate
+	assert.NoError(t, os.WriteFile(keyFile, []byte("garbage"), 0o600))
+	assert.Error(t, reloader.Reload())
+	assert.Equal(t, int64(3), serial(newClient(ca, nil)))
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	ca := issue(t, "ca", 1, nil)
+
+	certFile, keyFile := issue(t, "server", 2, ca).write(t, dir)
+
+	reloader, err := New(Config{CertFile: certFile, KeyFile: keyFile})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go reloader.Watch(ctx, 5*time.Millisecond, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	issue(t, "server", 3, ca).write(t, dir)
+
+	// Make sure the modification time changes on filesystems with coarse timestamps
+	later := time.Now().Add(time.Second)
+	assert.NoError(t, os.Chtimes(certFile, later, later))
+
+	assert.Eventually(t, func() bool {
+		cert, _ := reloader.certificate(nil)
+
+		return cert.Leaf != nil && cert.Leaf.SerialNumber.Int64() == 3
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := issue(t, "ca", 1, nil)
+	otherCA := issue(t, "other-ca", 2, nil)
+
+	certFile, keyFile := issue(t, "server", 3, ca).write(t, dir)
+
+	caFile := filepath.Join(dir, "ca.crt")
+	assert.NoError(t, os.WriteFile(caFile, ca.pem, 0o600))
+
+	for _, test := range []struct {
+		clientAuth string
+		cert       *certificate
+		ok         bool
+		subject    string
+	}{
+		{clientAuth: ClientAuthRequire, cert: issue(t, "deploy", 4, ca), ok: true, subject: "deploy"},
+		{clientAuth: ClientAuthRequire, cert: nil, ok: false},
+		{clientAuth: ClientAuthRequire, cert: issue(t, "mallory", 5, otherCA), ok: false},
+		{clientAuth: ClientAuthOptional, cert: nil, ok: true},
+		{clientAuth: ClientAuthOptional, cert: issue(t, "mallory", 6, otherCA), ok: false},
+	} {
+		reloader, err := New(Config{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile, ClientAuth: test.clientAuth})
+		assert.NoError(t, err)
+
+		server := start(t, reloader)
+
+		response, err := newClient(ca, test.cert).Get(server.URL)
+		if !test.ok {
+			assert.Error(t, err)
+
+			continue
+		}
+
+		if assert.NoError(t, err) {
+			body, _ := io.ReadAll(response.Body)
+			response.Body.Close()
+
+			assert.Equal(t, test.subject, string(body))
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := issue(t, "server", 1, nil).write(t, dir)
+
+	reloader, err := New(Config{
+		CertFile:     certFile,
+		KeyFile:      keyFile,
+		MinVersion:   "1.3",
+		CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"},
+	})
+	assert.NoError(t, err)
+
+	config := reloader.TLSConfig()
+	assert.Equal(t, uint16(tls.VersionTLS13), config.MinVersion)
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}, config.CipherSuites)
+
+	for _, config := range []Config{
+		{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.1"},
+		{CertFile: certFile, KeyFile: keyFile, CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}},
+		{CertFile: certFile, KeyFile: keyFile, ClientCAFile: certFile, ClientAuth: "sometimes"},
+		{CertFile: certFile, KeyFile: keyFile, ClientCAFile: keyFile},
+		{CertFile: filepath.Join(dir, "missing"), KeyFile: keyFile},
+	} {
+		_, err := New(config)
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,121 @@
+// Package tracing configures OpenTelemetry tracing of lru-api.
+// Spans are exported through OTLP/gRPC, written to stdout, or not recorded at all.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// Exporters supported by New
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// Config of tracing
+type Config struct {
+	// Exporter is one of ExporterNone, ExporterOTLP and ExporterStdout
+	Exporter string
+	// Endpoint is host:port of the OTLP/gRPC collector.
+	// If empty, OTEL_EXPORTER_OTLP_ENDPOINT or localhost:4317 is used.
+	Endpoint string
+	// Insecure disables TLS towards the collector
+	Insecure bool
+	// SampleRatio of traces started by lru-api, traces continued from a traceparent follow the parent's decision
+	SampleRatio float64
+	ServiceName string
+
+	// Stdout receives spans of ExporterStdout, os.Stdout if nil
+	Stdout io.Writer
+}
+
+// Provider creates tracers and flushes spans on Shutdown
+type Provider interface {
+	trace.TracerProvider
+	Shutdown(ctx context.Context) error
+}
+
+// noopProvider is used when tracing is disabled, spans are not recorded
+type noopProvider struct {
+	noop.TracerProvider
+}
+
+func (noopProvider) Shutdown(context.Context) error {
+	return nil
+}
+
+// New creates a tracer provider exporting spans as configured.
+func New(ctx context.Context, config Config) (Provider, error) {
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+
+	switch config.Exporter {
+	case ExporterNone, "":
+		return noopProvider{}, nil
+	case ExporterOTLP:
+		var opts []otlptracegrpc.Option
+
+		if config.Endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(config.Endpoint))
+		}
+
+		if config.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+
+		exporter, err = otlptracegrpc.New(ctx, opts...)
+	case ExporterStdout:
+		out := config.Stdout
+		if out == nil {
+			out = os.Stdout
+		}
+
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(out))
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", config.Exporter)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Attributes from OTEL_RESOURCE_ATTRIBUTES and OTEL_SERVICE_NAME take precedence
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(config.ServiceName)),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewProvider(exporter, res, config.SampleRatio), nil
+}
+
+// NewProvider creates a tracer provider with the exporter, e.g. tracetest.InMemoryExporter in tests.
+func NewProvider(exporter sdktrace.SpanExporter, res *resource.Resource, sampleRatio float64) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+}
+
+// Propagator reads and writes W3C traceparent, tracestate and baggage headers
+func Propagator() propagation.TextMapPropagator {
+	return propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+}
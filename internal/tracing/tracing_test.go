@@ -0,0 +1,103 @@
+package tracing_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/skantay/lru-api/internal/api"
+	"github.com/skantay/lru-api/internal/cache"
+	"github.com/skantay/lru-api/internal/cache/mocks"
+	"github.com/skantay/lru-api/internal/tracing"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestRequestTrace(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := tracing.NewProvider(exporter, resource.Empty(), 1)
+
+	c, err := cache.New(10, time.Minute, &mocks.Logger{}, cache.WithTracerProvider(provider))
+	assert.NoError(t, err)
+
+	assert.NoError(t, c.Put(context.Background(), "key/1", "value 1", 0))
+
+	handler := api.New(c, slog.New(slog.NewTextHandler(io.Discard, nil)), api.WithTracerProvider(provider))
+
+	request := httptest.NewRequest(http.MethodGet, "/api/lru/key%2F1", nil)
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, request)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	assert.NoError(t, provider.ForceFlush(context.Background()))
+
+	spans := map[string]tracetest.SpanStub{}
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+
+	server, ok := spans["GET /api/lru/{key}"]
+	if !assert.True(t, ok, "server span") {
+		return
+	}
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	parentID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+
+	assert.Equal(t, trace.SpanKindServer, server.SpanKind)
+	assert.Equal(t, traceID, server.SpanContext.TraceID())
+	assert.Equal(t, parentID, server.Parent.SpanID())
+	assert.Contains(t, server.Attributes, attribute.Int("http.response.status_code", http.StatusOK))
+	assert.Contains(t, server.Attributes, attribute.String("http.route", "/api/lru/{key}"))
+
+	get, ok := spans["LRUCache.Get"]
+	if assert.True(t, ok, "cache span") {
+		assert.Equal(t, server.SpanContext.SpanID(), get.Parent.SpanID())
+		assert.Contains(t, get.Attributes, attribute.String("cache.key", "key/1"))
+		assert.Contains(t, get.Attributes, attribute.Bool("cache.hit", true))
+	}
+
+	marshal, ok := spans["json.Marshal"]
+	if assert.True(t, ok, "json span") {
+		assert.Equal(t, server.SpanContext.SpanID(), marshal.Parent.SpanID())
+	}
+}
+
+func TestNew(t *testing.T) {
+	provider, err := tracing.New(context.Background(), tracing.Config{Exporter: tracing.ExporterNone})
+	assert.NoError(t, err)
+
+	_, span := provider.Tracer("test").Start(context.Background(), "span")
+	assert.False(t, span.IsRecording())
+	span.End()
+
+	var out bytes.Buffer
+
+	provider, err = tracing.New(context.Background(), tracing.Config{
+		Exporter:    tracing.ExporterStdout,
+		SampleRatio: 1,
+		ServiceName: "lru-api",
+		Stdout:      &out,
+	})
+	assert.NoError(t, err)
+
+	_, span = provider.Tracer("test").Start(context.Background(), "span")
+	span.End()
+
+	assert.NoError(t, provider.Shutdown(context.Background()))
+	assert.Contains(t, out.String(), `"Name":"span"`)
+	assert.Contains(t, out.String(), `"Value":"lru-api"`)
+
+	_, err = tracing.New(context.Background(), tracing.Config{Exporter: "zipkin"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,92 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const ndjsonContentType = "application/x-ndjson"
+
+// ImportError is a line rejected by Import, Code is the code of the problem a single write of it would get
+type ImportError struct {
+	Line   int    `json:"line"`
+	Key    string `json:"key,omitempty"`
+	Code   string `json:"code"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// ImportResult reports the entries imported and the lines rejected by Import
+type ImportResult struct {
+	Imported int           `json:"imported"`
+	Errors   []ImportError `json:"errors"`
+}
+
+// Export writes every node/item the client may get to w as NDJSON, returning the number of entries.
+// Lines have the key, value and absolute expires_at of an entry, Import reads them back.
+// The export is only retried until the server starts sending it.
+func (c *Client) Export(ctx context.Context, w io.Writer) (int, error) {
+	response, err := c.retrying(ctx, true, func() (*http.Response, error) {
+		return c.send(ctx, http.MethodGet, "/api/export", nil, http.Header{"Accept": {ndjsonContentType}})
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return 0, newError(response)
+	}
+
+	counter := &lineCounter{w: w}
+
+	_, err = io.Copy(counter, response.Body)
+
+	return counter.lines, err
+}
+
+// Import loads NDJSON written by Export, keeping absolute expiration times.
+// Lines without expires_at expire after ttl, the default TTL of the server is applied if ttl == 0.
+// Rejected lines are reported in the result, the other lines are imported.
+// The body is streamed, so the import is not retried.
+func (c *Client) Import(ctx context.Context, r io.Reader, ttl time.Duration) (*ImportResult, error) {
+	path := "/api/import"
+	if ttl > 0 {
+		path += "?" + url.Values{"ttl": {ttl.String()}}.Encode()
+	}
+
+	response, err := c.send(ctx, http.MethodPost, path, r, http.Header{"Content-Type": {ndjsonContentType}})
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, newError(response)
+	}
+
+	var result ImportResult
+
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// lineCounter counts the lines written through it
+type lineCounter struct {
+	w     io.Writer
+	lines int
+}
+
+func (l *lineCounter) Write(p []byte) (int, error) {
+	n, err := l.w.Write(p)
+	l.lines += bytes.Count(p[:n], []byte{'\n'})
+
+	return n, err
+}
@@ -0,0 +1,477 @@
+// Package client provides a typed Go client for the lru-api HTTP API.
+// Client implements the same method set as the in-process cache,
+// so a remote lru-api can be used wherever the cache is used.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrKeyDoesNotExist is returned when the key was not found
+var ErrKeyDoesNotExist = errors.New("lru-api: key does not exist")
+
+// Error is returned for responses with an unexpected status code
+type Error struct {
+	StatusCode int
+	Body       string
+
+	// Code, Detail and RequestID are set if the server answered with problem details
+	Code      string
+	Detail    string
+	RequestID string
+
+	retryAfter string
+}
+
+func (e *Error) Error() string {
+	if e.Code != "" {
+		if e.Detail == "" {
+			return fmt.Sprintf("lru-api: %s (status %d)", e.Code, e.StatusCode)
+		}
+
+		return fmt.Sprintf("lru-api: %s (status %d): %s", e.Code, e.StatusCode, e.Detail)
+	}
+
+	if e.Body == "" {
+		return fmt.Sprintf("lru-api: unexpected status %d", e.StatusCode)
+	}
+
+	return fmt.Sprintf("lru-api: unexpected status %d: %s", e.StatusCode, e.Body)
+}
+
+// AuthFunc adds credentials to an outgoing request
+type AuthFunc func(r *http.Request) error
+
+// Retry configures retries of failed requests.
+// Reads are retried on network errors, 429 and 5xx responses with exponential backoff and jitter,
+// Retry-After sent by the server is honored. Writes are only retried on 429, as they are rejected before they run.
+type Retry struct {
+	// MaxAttempts including the first one, 1 disables retries
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	// Writes retries Put, Evict and EvictAll on network errors and 5xx too.
+	// A write may have been applied before it failed, so e.g. a retried Evict may return ErrKeyDoesNotExist for a key it deleted.
+	Writes bool
+}
+
+// Client is a client of lru-api
+type Client struct {
+	baseURL *url.URL
+	http    *http.Client
+	auth    AuthFunc
+	retry   Retry
+	timeout time.Duration
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient replaces the pooled HTTP client created by New
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.http = httpClient
+	}
+}
+
+// WithTimeout limits every attempt of a request, the context passed to a method limits the request as a whole
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithRetry configures retries, by default requests are attempted 3 times
+func WithRetry(retry Retry) Option {
+	return func(c *Client) {
+		c.retry = retry
+	}
+}
+
+// WithAuth sets a function that adds credentials to every request
+func WithAuth(auth AuthFunc) Option {
+	return func(c *Client) {
+		c.auth = auth
+	}
+}
+
+// WithBearerToken authenticates requests with the Authorization: Bearer header
+func WithBearerToken(token string) Option {
+	return WithAuth(func(r *http.Request) error {
+		r.Header.Set("Authorization", "Bearer "+token)
+
+		return nil
+	})
+}
+
+// WithAPIKey authenticates requests with the X-API-Key header
+func WithAPIKey(key string) Option {
+	return WithAuth(func(r *http.Request) error {
+		r.Header.Set("X-API-Key", key)
+
+		return nil
+	})
+}
+
+// New creates a client of lru-api listening on baseURL, e.g. http://localhost:8080.
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("lru-api: unsupported scheme %q", u.Scheme)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = 100
+	transport.MaxIdleConnsPerHost = 100
+
+	c := &Client{
+		baseURL: u,
+		http:    &http.Client{Transport: transport},
+		retry: Retry{
+			MaxAttempts: 3,
+			MinBackoff:  100 * time.Millisecond,
+			MaxBackoff:  2 * time.Second,
+		},
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c, nil
+}
+
+type putRequest struct {
+	Key        string      `json:"key"`
+	Value      interface{} `json:"value"`
+	TTLSeconds uint        `json:"ttl_seconds"`
+}
+
+type getResponse struct {
+	Key       string      `json:"key"`
+	Value     interface{} `json:"value"`
+	ExpiresAt int64       `json:"expires_at"`
+}
+
+type getAllResponse struct {
+	Keys   []string      `json:"keys"`
+	Values []interface{} `json:"values"`
+}
+
+// Put inserts or updates a node/item.
+// If ttl == 0, then default TTL of the server is applied, a TTL below a second is rounded up to a second.
+func (c *Client) Put(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	if ttl < 0 {
+		return fmt.Errorf("lru-api: negative ttl %v", ttl)
+	}
+
+	body, err := json.Marshal(putRequest{
+		Key:        key,
+		Value:      value,
+		TTLSeconds: uint(math.Ceil(ttl.Seconds())),
+	})
+	if err != nil {
+		return err
+	}
+
+	response, err := c.do(ctx, http.MethodPost, "/api/lru", body)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusCreated {
+		return newError(response)
+	}
+
+	return nil
+}
+
+// Get retrieves a node/item by a specific key.
+// If node/item was not found, then it returns ErrKeyDoesNotExist
+func (c *Client) Get(ctx context.Context, key string) (value interface{}, expiresAt time.Time, err error) {
+	response, err := c.do(ctx, http.MethodGet, "/api/lru/"+url.PathEscape(key), nil)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, time.Time{}, ErrKeyDoesNotExist
+	default:
+		return nil, time.Time{}, newError(response)
+	}
+
+	var decoded getResponse
+
+	if err := json.NewDecoder(response.Body).Decode(&decoded); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	return decoded.Value, time.Unix(decoded.ExpiresAt, 0), nil
+}
+
+// GetAll retrieves all nodes/items from cache.
+func (c *Client) GetAll(ctx context.Context) (keys []string, values []interface{}, err error) {
+	response, err := c.do(ctx, http.MethodGet, "/api/lru", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNoContent:
+		return nil, nil, nil
+	default:
+		return nil, nil, newError(response)
+	}
+
+	var decoded getAllResponse
+
+	if err := json.NewDecoder(response.Body).Decode(&decoded); err != nil {
+		return nil, nil, err
+	}
+
+	return decoded.Keys, decoded.Values, nil
+}
+
+// Evict deletes a node/item from cache by a specific key.
+// If node/item was not found, then it returns ErrKeyDoesNotExist.
+// The HTTP API does not return the evicted value, so value is always nil.
+func (c *Client) Evict(ctx context.Context, key string) (value interface{}, err error) {
+	response, err := c.do(ctx, http.MethodDelete, "/api/lru/"+url.PathEscape(key), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusNoContent:
+		return nil, nil
+	case http.StatusNotFound:
+		return nil, ErrKeyDoesNotExist
+	}
+
+	return nil, newError(response)
+}
+
+// EvictAll flushes the cache.
+func (c *Client) EvictAll(ctx context.Context) error {
+	response, err := c.do(ctx, http.MethodDelete, "/api/lru", nil)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusNoContent {
+		return newError(response)
+	}
+
+	return nil
+}
+
+// do sends a request, retrying it according to c.retry.
+// The caller must close the body of the returned response.
+func (c *Client) do(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	read := method == http.MethodGet || method == http.MethodHead
+
+	return c.retrying(ctx, read, func() (*http.Response, error) {
+		return c.attempt(ctx, method, path, body)
+	})
+}
+
+// retrying calls send according to c.retry until it returns a response that should not be retried.
+// Unless read or c.retry.Writes is set, only 429 responses are retried.
+func (c *Client) retrying(ctx context.Context, read bool, send func() (*http.Response, error)) (*http.Response, error) {
+	attempts := c.retry.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var lastErr error
+
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			if err := sleep(ctx, c.backoff(attempt, lastErr)); err != nil {
+				return nil, err
+			}
+		}
+
+		response, err := send()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+
+			if !read && !c.retry.Writes {
+				return nil, err
+			}
+
+			lastErr = err
+
+			continue
+		}
+
+		if !retryable(response.StatusCode, read || c.retry.Writes) || attempt == attempts-1 {
+			return response, nil
+		}
+
+		apiErr := newError(response)
+		apiErr.retryAfter = response.Header.Get("Retry-After")
+		response.Body.Close()
+
+		lastErr = apiErr
+	}
+
+	return nil, lastErr
+}
+
+func (c *Client) attempt(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	// The body is read anew by every attempt
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+
+		response, err := c.send(ctx, method, path, reader, nil)
+		if err != nil {
+			cancel()
+
+			return nil, err
+		}
+
+		response.Body = &cancelBody{ReadCloser: response.Body, cancel: cancel}
+
+		return response, nil
+	}
+
+	return c.send(ctx, method, path, reader, nil)
+}
+
+// send sends a JSON body, header overrides the defaults
+func (c *Client) send(ctx context.Context, method, path string, body io.Reader, header http.Header) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, method, c.baseURL.String()+path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	request.Header.Set("Accept", "application/json")
+
+	for name, values := range header {
+		request.Header[name] = values
+	}
+
+	if c.auth != nil {
+		if err := c.auth(request); err != nil {
+			return nil, err
+		}
+	}
+
+	return c.http.Do(request)
+}
+
+// backoff returns the delay before the attempt, Retry-After of the last response takes precedence
+func (c *Client) backoff(attempt int, lastErr error) time.Duration {
+	var apiErr *Error
+	if errors.As(lastErr, &apiErr) && apiErr.retryAfter != "" {
+		if seconds, err := strconv.Atoi(apiErr.retryAfter); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+
+	delay := c.retry.MinBackoff << (attempt - 1)
+	if delay <= 0 || delay > c.retry.MaxBackoff {
+		delay = c.retry.MaxBackoff
+	}
+
+	if delay <= 0 {
+		return 0
+	}
+
+	// Full jitter spreads retries of many clients
+	return time.Duration(rand.Int63n(int64(delay)) + 1)
+}
+
+// retryable reports whether a response may be retried, 5xx only if the request is safe to repeat
+func retryable(status int, repeatable bool) bool {
+	return status == http.StatusTooManyRequests || (repeatable && status >= http.StatusInternalServerError)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// newError reads a limited part of the body for the error message
+func newError(response *http.Response) *Error {
+	data, _ := io.ReadAll(io.LimitReader(response.Body, 4096))
+
+	apiErr := &Error{
+		StatusCode: response.StatusCode,
+		Body:       strings.TrimSpace(string(data)),
+	}
+
+	if mediaType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type")); mediaType == "application/problem+json" {
+		var problem struct {
+			Code      string `json:"code"`
+			Detail    string `json:"detail"`
+			RequestID string `json:"request_id"`
+		}
+
+		if err := json.Unmarshal(data, &problem); err == nil {
+			apiErr.Code = problem.Code
+			apiErr.Detail = problem.Detail
+			apiErr.RequestID = problem.RequestID
+		}
+	}
+
+	return apiErr
+}
+
+// cancelBody cancels the per-attempt timeout once the body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	defer b.cancel()
+
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,267 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/skantay/lru-api/internal/api"
+	"github.com/skantay/lru-api/internal/cache"
+	"github.com/skantay/lru-api/internal/cache/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+// Client must be usable wherever the in-process cache is used
+var _ api.ILRUCache = (*Client)(nil)
+
+func newTestClient(t *testing.T, opts ...Option) *Client {
+	c, err := cache.New(10, time.Minute, &mocks.Logger{})
+	assert.NoError(t, err)
+
+	server := httptest.NewServer(api.New(c, slog.New(slog.NewTextHandler(io.Discard, nil))))
+	t.Cleanup(server.Close)
+
+	client, err := New(server.URL, opts...)
+	assert.NoError(t, err)
+
+	return client
+}
+
+func TestClient(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	_, _, err := client.Get(ctx, "key 1")
+	assert.ErrorIs(t, err, ErrKeyDoesNotExist)
+
+	keys, values, err := client.GetAll(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+	assert.Empty(t, values)
+
+	err = client.Put(ctx, "key 1", "value 1", time.Hour)
+	assert.NoError(t, err)
+
+	value, expiresAt, err := client.Get(ctx, "key 1")
+	assert.NoError(t, err)
+	assert.Equal(t, "value 1", value)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, 2*time.Second)
+
+	keys, values, err = client.GetAll(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"key 1"}, keys)
+	assert.Equal(t, []interface{}{"value 1"}, values)
+
+	_, err = client.Evict(ctx, "key 1")
+	assert.NoError(t, err)
+
+	_, err = client.Evict(ctx, "key 1")
+	assert.ErrorIs(t, err, ErrKeyDoesNotExist)
+
+	assert.NoError(t, client.Put(ctx, "key/2", 2, 0))
+
+	value, _, err = client.Get(ctx, "key/2")
+	assert.NoError(t, err)
+	assert.Equal(t, float64(2), value)
+
+	assert.NoError(t, client.EvictAll(ctx))
+
+	_, _, err = client.Get(ctx, "key/2")
+	assert.ErrorIs(t, err, ErrKeyDoesNotExist)
+}
+
+func TestProblem(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		key   string
+		value interface{}
+		code  string
+	}{
+		{key: "", value: 1, code: "empty_key"},
+		{key: "key", value: nil, code: "null_value"},
+	}
+
+	for _, test := range tests {
+		err := client.Put(ctx, test.key, test.value, 0)
+
+		var apiErr *Error
+		if assert.ErrorAs(t, err, &apiErr) {
+			assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+			assert.Equal(t, test.code, apiErr.Code)
+			assert.NotEmpty(t, apiErr.RequestID)
+			assert.Equal(t, "lru-api: "+test.code+" (status 400)", apiErr.Error())
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	var calls atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secret", r.Header.Get("X-API-Key"))
+
+		status := http.StatusServiceUnavailable
+		if r.URL.Path == "/api/lru/limited" {
+			status = http.StatusTooManyRequests
+		}
+
+		if calls.Add(1) < 3 {
+			w.WriteHeader(status)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name   string
+		retry  Retry
+		call   func(c *Client) error
+		calls  int32
+		status int
+	}{
+		{name: "read", retry: Retry{MaxAttempts: 3}, call: func(c *Client) error {
+			_, _, err := c.GetAll(context.Background())
+
+			return err
+		}, calls: 3},
+		{name: "read out of attempts", retry: Retry{MaxAttempts: 2}, call: func(c *Client) error {
+			_, _, err := c.GetAll(context.Background())
+
+			return err
+		}, calls: 2, status: http.StatusServiceUnavailable},
+		{name: "write is not retried", retry: Retry{MaxAttempts: 3}, call: func(c *Client) error {
+			return c.EvictAll(context.Background())
+		}, calls: 1, status: http.StatusServiceUnavailable},
+		{name: "write opted in", retry: Retry{MaxAttempts: 3, Writes: true}, call: func(c *Client) error {
+			return c.EvictAll(context.Background())
+		}, calls: 3},
+		{name: "rate limited write", retry: Retry{MaxAttempts: 3}, call: func(c *Client) error {
+			_, err := c.Evict(context.Background(), "limited")
+
+			return err
+		}, calls: 3},
+	}
+
+	for _, test := range tests {
+		calls.Store(0)
+
+		test.retry.MinBackoff, test.retry.MaxBackoff = time.Millisecond, time.Millisecond
+
+		client, err := New(server.URL, WithAPIKey("secret"), WithRetry(test.retry))
+		assert.NoError(t, err)
+
+		err = test.call(client)
+		assert.Equal(t, test.calls, calls.Load(), test.name)
+
+		var apiErr *Error
+		if test.status == 0 {
+			assert.NoError(t, err, test.name)
+		} else if assert.ErrorAs(t, err, &apiErr, test.name) {
+			assert.Equal(t, test.status, apiErr.StatusCode, test.name)
+		}
+	}
+}
+
+// TestRetryNetworkError checks a write that may have been applied is not retried
+func TestRetryNetworkError(t *testing.T) {
+	var calls atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+
+		// The connection is dropped without a response
+		conn, _, err := http.NewResponseController(w).Hijack()
+		if assert.NoError(t, err) {
+			conn.Close()
+		}
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL, WithRetry(Retry{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}))
+	assert.NoError(t, err)
+
+	_, err = client.Evict(context.Background(), "a")
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), calls.Load())
+
+	calls.Store(0)
+
+	_, _, err = client.Get(context.Background(), "a")
+	assert.Error(t, err)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestExportImport(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	assert.NoError(t, client.Put(ctx, "a", "1", time.Hour))
+	assert.NoError(t, client.Put(ctx, "b", "2", 0))
+
+	var exported bytes.Buffer
+
+	n, err := client.Export(ctx, &exported)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	assert.NoError(t, client.EvictAll(ctx))
+
+	result, err := client.Import(ctx, io.MultiReader(&exported, strings.NewReader(`{"key":"c"}`)), time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, &ImportResult{Imported: 2, Errors: []ImportError{{Line: 3, Key: "c", Code: "null_value"}}}, result)
+
+	_, expiresAt, err := client.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, 2*time.Second)
+}
+
+func TestWatch(t *testing.T) {
+	client := newTestClient(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	assert.NoError(t, client.Put(ctx, "other", 0, 0))
+
+	var events []Event
+
+	errStop := errors.New("stop")
+
+	go func() {
+		// Events are replayed from the backlog, so the subscription may start late
+		client.Put(ctx, "key 1", "value 1", 0)
+		client.Put(ctx, "key 1", "value 2", 0)
+	}()
+
+	err := client.Watch(ctx, WatchOptions{Key: "key 1"}, func(event Event) error {
+		events = append(events, event)
+		if len(events) == 2 {
+			return errStop
+		}
+
+		return nil
+	})
+	assert.ErrorIs(t, err, errStop)
+
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, "put", events[0].Type)
+		assert.Equal(t, "key 1", events[0].Key)
+		assert.Equal(t, "value 1", events[0].Value)
+		assert.Equal(t, "update", events[1].Type)
+		assert.Equal(t, "value 2", events[1].Value)
+		assert.Greater(t, events[1].ID, events[0].ID)
+	}
+}
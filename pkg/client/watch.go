@@ -0,0 +1,174 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is a change of the cache received from the watch endpoint
+type Event struct {
+	ID uint64
+	// Type is one of put, update, evict, expire and flush
+	Type      string
+	Key       string
+	Value     interface{}
+	ExpiresAt time.Time
+	Time      time.Time
+}
+
+// WatchOptions select the events to receive.
+// Key and Prefix are mutually exclusive, if neither is set all events are received.
+type WatchOptions struct {
+	Key    string
+	Prefix string
+	// LastEventID resumes the stream after the event with this ID
+	LastEventID uint64
+}
+
+type watchEvent struct {
+	Key       string      `json:"key"`
+	Value     interface{} `json:"value"`
+	ExpiresAt int64       `json:"expires_at"`
+	Time      int64       `json:"time"`
+}
+
+// Watch calls fn for every change event until ctx is done or fn returns an error.
+// If the server closes the stream, e.g. because the client fell behind,
+// Watch reconnects and resumes after the last received event.
+// WithTimeout does not apply to the stream.
+func (c *Client) Watch(ctx context.Context, opts WatchOptions, fn func(Event) error) error {
+	query := url.Values{}
+
+	if opts.Key != "" {
+		query.Set("key", opts.Key)
+	}
+
+	if opts.Prefix != "" {
+		query.Set("prefix", opts.Prefix)
+	}
+
+	path := "/api/watch"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	lastEventID := opts.LastEventID
+
+	for {
+		header := http.Header{"Accept": {"text/event-stream"}}
+		if lastEventID > 0 {
+			header.Set("Last-Event-ID", strconv.FormatUint(lastEventID, 10))
+		}
+
+		response, err := c.retrying(ctx, true, func() (*http.Response, error) {
+			return c.send(ctx, http.MethodGet, path, nil, header)
+		})
+		if err != nil {
+			return err
+		}
+
+		if response.StatusCode != http.StatusOK {
+			apiErr := newError(response)
+			response.Body.Close()
+
+			return apiErr
+		}
+
+		err = readEvents(response, func(event Event) error {
+			lastEventID = event.ID
+
+			return fn(event)
+		})
+		response.Body.Close()
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		var stopErr *stopError
+		if errors.As(err, &stopErr) {
+			return stopErr.err
+		}
+
+		// The stream ended, wait a little before reconnecting
+		if err := sleep(ctx, c.backoff(1, nil)); err != nil {
+			return err
+		}
+	}
+}
+
+// stopError wraps an error returned by the callback, so it is not mistaken for a broken stream
+type stopError struct {
+	err error
+}
+
+func (e *stopError) Error() string {
+	return e.err.Error()
+}
+
+// readEvents parses a text/event-stream body, comments and unknown fields are ignored
+func readEvents(response *http.Response, fn func(Event) error) error {
+	scanner := bufio.NewScanner(response.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16<<20)
+
+	var (
+		event Event
+		data  strings.Builder
+	)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if line == "" {
+			if data.Len() > 0 {
+				var decoded watchEvent
+
+				if err := json.Unmarshal([]byte(data.String()), &decoded); err != nil {
+					return err
+				}
+
+				event.Key = decoded.Key
+				event.Value = decoded.Value
+				event.Time = time.Unix(decoded.Time, 0)
+
+				if decoded.ExpiresAt != 0 {
+					event.ExpiresAt = time.Unix(decoded.ExpiresAt, 0)
+				}
+
+				if err := fn(event); err != nil {
+					return &stopError{err: err}
+				}
+			}
+
+			event = Event{}
+			data.Reset()
+
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "id":
+			event.ID, _ = strconv.ParseUint(value, 10, 64)
+		case "event":
+			event.Type = value
+		case "data":
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+
+			data.WriteString(value)
+		}
+	}
+
+	return scanner.Err()
+}
@@ -6,6 +6,7 @@ package config
 
 import (
 	"flag"
+	"strings"
 
 	"github.com/caarlos0/env"
 )
@@ -16,6 +17,100 @@ type Config struct {
 	CacheSize       uint   `env:"CACHE_SIZE" envDefault:"10"`
 	DefaultCacheTTL int64  `env:"DEFAULT_CACHE_TTL" envDefault:"60"`
 	LogLevel        string `env:"LOG_LEVEL" envDefault:"WARN"`
+
+	// LogFormat is text or json.
+	// Handled requests are logged at AccessLogLevel, AccessLogSampleRatio of them, server errors always.
+	LogFormat            string  `env:"LOG_FORMAT" envDefault:"text"`
+	AccessLogLevel       string  `env:"ACCESS_LOG_LEVEL" envDefault:"WARN"`
+	AccessLogSampleRatio float64 `env:"ACCESS_LOG_SAMPLE_RATIO" envDefault:"1"`
+
+	// Values longer than CacheCompressionThreshold bytes are stored compressed, 0 disables it.
+	// Responses of at least HTTPCompressionMinSize bytes are compressed, 0 disables it.
+	CacheCompressionThreshold int `env:"CACHE_COMPRESSION_THRESHOLD"`
+	HTTPCompressionMinSize    int `env:"HTTP_COMPRESSION_MIN_SIZE" envDefault:"1024"`
+
+	// Largest body of a create once decompressed, 0 is unlimited
+	HTTPMaxBodySize int64 `env:"HTTP_MAX_BODY_SIZE" envDefault:"1048576"`
+
+	// HTTP is served over TLS if TLSCertFile is set, mutual TLS is enabled by TLSClientCAFile.
+	// Files are reloaded on SIGHUP and when they change.
+	TLSCertFile       string   `env:"TLS_CERT_FILE"`
+	TLSKeyFile        string   `env:"TLS_KEY_FILE"`
+	TLSMinVersion     string   `env:"TLS_MIN_VERSION" envDefault:"1.2"`
+	TLSCipherSuites   []string `env:"TLS_CIPHER_SUITES" envSeparator:","`
+	TLSClientCAFile   string   `env:"TLS_CLIENT_CA_FILE"`
+	TLSClientAuth     string   `env:"TLS_CLIENT_AUTH" envDefault:"require"`
+	TLSReloadInterval int64    `env:"TLS_RELOAD_INTERVAL" envDefault:"10"`
+
+	// Seconds between failing readiness on SIGTERM and shutting the servers down
+	DrainDelay int64 `env:"DRAIN_DELAY" envDefault:"5"`
+
+	// gRPC server is disabled if GRPCPort is empty
+	GRPCPort string `env:"GRPC_PORT"`
+
+	// RESP (Redis protocol) listener is disabled if RESPPort is empty
+	RESPPort           string `env:"RESP_PORT"`
+	RESPMaxConnections int    `env:"RESP_MAX_CONNECTIONS" envDefault:"1000"`
+	RESPIdleTimeout    int64  `env:"RESP_IDLE_TIMEOUT" envDefault:"300"`
+
+	// Memcached protocol listener is disabled if MemcachePort is empty
+	MemcachePort           string `env:"MEMCACHE_PORT"`
+	MemcacheMaxConnections int    `env:"MEMCACHE_MAX_CONNECTIONS" envDefault:"1000"`
+	MemcacheIdleTimeout    int64  `env:"MEMCACHE_IDLE_TIMEOUT" envDefault:"300"`
+
+	// Caching proxy is disabled if ProxyPort is empty, responses of ProxyUpstream are stored under ProxyKeyPrefix
+	ProxyPort        string `env:"PROXY_PORT"`
+	ProxyUpstream    string `env:"PROXY_UPSTREAM"`
+	ProxyKeyPrefix   string `env:"PROXY_KEY_PREFIX" envDefault:"proxy:"`
+	ProxyMaxBodySize int64  `env:"PROXY_MAX_BODY_SIZE" envDefault:"1048576"`
+	ProxyTimeout     int64  `env:"PROXY_TIMEOUT" envDefault:"30"`
+
+	// Tracing exporter is one of none, otlp and stdout
+	TracingExporter    string  `env:"TRACING_EXPORTER" envDefault:"none"`
+	TracingEndpoint    string  `env:"TRACING_ENDPOINT"`
+	TracingInsecure    bool    `env:"TRACING_INSECURE"`
+	TracingSampleRatio float64 `env:"TRACING_SAMPLE_RATIO" envDefault:"1"`
+
+	// Authentication is disabled if neither API keys nor JWT keys are set.
+	// API keys are name:sha256 pairs, sha256 is the hex encoded SHA-256 of the key.
+	AuthAPIKeys         []string `env:"AUTH_API_KEYS" envSeparator:","`
+	AuthAPIKeysFile     string   `env:"AUTH_API_KEYS_FILE"`
+	AuthJWTHS256KeyFile string   `env:"AUTH_JWT_HS256_KEY_FILE"`
+	AuthJWTRS256KeyFile string   `env:"AUTH_JWT_RS256_KEY_FILE"`
+	AuthJWKSFile        string   `env:"AUTH_JWKS_FILE"`
+	AuthJWTIssuer       string   `env:"AUTH_JWT_ISSUER"`
+	AuthJWTAudience     string   `env:"AUTH_JWT_AUDIENCE"`
+
+	// The gRPC, RESP and memcached listeners are not authenticated,
+	// they are only started with authentication configured if AllowUnauthenticatedListeners is set
+	AllowUnauthenticatedListeners bool `env:"ALLOW_UNAUTHENTICATED_LISTENERS"`
+
+	// Authorization policy, every authenticated principal may do everything if empty
+	AuthzPolicyFile string `env:"AUTHZ_POLICY_FILE"`
+
+	// Requests per second of every client, 0 disables the limit.
+	// Bursts default to the rate rounded up.
+	RateLimitRead       float64 `env:"RATE_LIMIT_READ"`
+	RateLimitReadBurst  int     `env:"RATE_LIMIT_READ_BURST"`
+	RateLimitWrite      float64 `env:"RATE_LIMIT_WRITE"`
+	RateLimitWriteBurst int     `env:"RATE_LIMIT_WRITE_BURST"`
+
+	// Entries and bytes every tenant may store, 0 is unlimited.
+	// Tenants are tenant:entries:bytes overrides.
+	QuotaMaxEntries int      `env:"QUOTA_MAX_ENTRIES"`
+	QuotaMaxBytes   uint64   `env:"QUOTA_MAX_BYTES"`
+	QuotaTenants    []string `env:"QUOTA_TENANTS" envSeparator:","`
+
+	// Cache-Control of GET responses as mode[:seconds], mode is private, public or no-store.
+	// Rules are prefix=mode[:seconds] overrides for namespaces of keys.
+	HTTPCacheControl string   `env:"HTTP_CACHE_CONTROL" envDefault:"private"`
+	HTTPCacheRules   []string `env:"HTTP_CACHE_RULES" envSeparator:","`
+
+	// Audit log is disabled if AuditLogFile is empty.
+	// The file is rotated at AuditLogMaxSize bytes, AuditLogMaxFiles rotated files are kept, 0 keeps all.
+	AuditLogFile     string `env:"AUDIT_LOG_FILE"`
+	AuditLogMaxSize  int64  `env:"AUDIT_LOG_MAX_SIZE" envDefault:"10485760"`
+	AuditLogMaxFiles int    `env:"AUDIT_LOG_MAX_FILES" envDefault:"10"`
 }
 
 // LoadConfig loads the configuration from environment variables.
@@ -24,6 +119,57 @@ func LoadConfig() (*Config, error) {
 	cacheSize := flag.Uint("cache-size", 0, "Cache size")
 	defaultCacheTTL := flag.Int64("default-cache-ttl", 0, "Default cache TTL")
 	logLevel := flag.String("log-level", "", "Log level")
+	logFormat := flag.String("log-format", "", "Log format, text or json")
+	accessLogLevel := flag.String("access-log-level", "", "Level of access log lines")
+	accessLogSampleRatio := flag.Float64("access-log-sample-ratio", 0, "Ratio of requests logged")
+	cacheCompressionThreshold := flag.Int("cache-compression-threshold", 0, "Length above which values are stored compressed")
+	httpCompressionMinSize := flag.Int("http-compression-min-size", 0, "Smallest response compressed")
+	httpMaxBodySize := flag.Int64("http-max-body-size", 0, "Largest decompressed body of a create or a v2 write")
+	drainDelay := flag.Int64("drain-delay", 0, "Drain delay on SIGTERM")
+	tlsCertFile := flag.String("tls-cert-file", "", "TLS certificate file")
+	tlsKeyFile := flag.String("tls-key-file", "", "TLS key file")
+	tlsMinVersion := flag.String("tls-min-version", "", "Minimum TLS version: 1.2 or 1.3")
+	tlsCipherSuites := flag.String("tls-cipher-suites", "", "Comma separated TLS 1.2 cipher suites")
+	tlsClientCAFile := flag.String("tls-client-ca-file", "", "CA file of client certificates, enables mutual TLS")
+	tlsClientAuth := flag.String("tls-client-auth", "", "Client certificates: require or optional")
+	tlsReloadInterval := flag.Int64("tls-reload-interval", 0, "Seconds between checks of TLS files for changes")
+	grpcPort := flag.String("grpc-port", "", "gRPC port")
+	respPort := flag.String("resp-port", "", "RESP (Redis protocol) port")
+	respMaxConnections := flag.Int("resp-max-connections", 0, "RESP max client connections")
+	respIdleTimeout := flag.Int64("resp-idle-timeout", 0, "RESP client idle timeout")
+	memcachePort := flag.String("memcache-port", "", "Memcached protocol port")
+	memcacheMaxConnections := flag.Int("memcache-max-connections", 0, "Memcached max client connections")
+	memcacheIdleTimeout := flag.Int64("memcache-idle-timeout", 0, "Memcached client idle timeout")
+	proxyPort := flag.String("proxy-port", "", "Caching proxy port")
+	proxyUpstream := flag.String("proxy-upstream", "", "URL of the service behind the caching proxy")
+	proxyKeyPrefix := flag.String("proxy-key-prefix", "", "Prefix of keys of proxied responses")
+	proxyMaxBodySize := flag.Int64("proxy-max-body-size", 0, "Largest proxied response body stored")
+	proxyTimeout := flag.Int64("proxy-timeout", 0, "Seconds before upstream requests time out")
+	tracingExporter := flag.String("tracing-exporter", "", "Tracing exporter: none, otlp or stdout")
+	tracingEndpoint := flag.String("tracing-endpoint", "", "OTLP/gRPC collector endpoint")
+	tracingInsecure := flag.Bool("tracing-insecure", false, "Disable TLS towards the OTLP collector")
+	tracingSampleRatio := flag.Float64("tracing-sample-ratio", 0, "Ratio of traces sampled")
+	authAPIKeys := flag.String("auth-api-keys", "", "Comma separated name:sha256 API keys")
+	authAPIKeysFile := flag.String("auth-api-keys-file", "", "File with a name:sha256 API key per line")
+	authJWTHS256KeyFile := flag.String("auth-jwt-hs256-key-file", "", "File with the HS256 secret")
+	authJWTRS256KeyFile := flag.String("auth-jwt-rs256-key-file", "", "File with the PEM encoded RS256 public key")
+	authJWKSFile := flag.String("auth-jwks-file", "", "JWKS file")
+	authJWTIssuer := flag.String("auth-jwt-issuer", "", "Required iss claim")
+	authJWTAudience := flag.String("auth-jwt-audience", "", "Required aud claim")
+	allowUnauthenticatedListeners := flag.Bool("allow-unauthenticated-listeners", false, "Start the gRPC, RESP and memcached listeners with authentication configured")
+	authzPolicyFile := flag.String("authz-policy-file", "", "Authorization policy file")
+	rateLimitRead := flag.Float64("rate-limit-read", 0, "Read requests per second of every client")
+	rateLimitReadBurst := flag.Int("rate-limit-read-burst", 0, "Read request burst of every client")
+	rateLimitWrite := flag.Float64("rate-limit-write", 0, "Write requests per second of every client")
+	rateLimitWriteBurst := flag.Int("rate-limit-write-burst", 0, "Write request burst of every client")
+	quotaMaxEntries := flag.Int("quota-max-entries", 0, "Entries every tenant may store")
+	quotaMaxBytes := flag.Uint64("quota-max-bytes", 0, "Bytes every tenant may store")
+	quotaTenants := flag.String("quota-tenants", "", "Comma separated tenant:entries:bytes quotas")
+	httpCacheControl := flag.String("http-cache-control", "", "Cache-Control of GET responses: mode[:seconds]")
+	httpCacheRules := flag.String("http-cache-rules", "", "Comma separated prefix=mode[:seconds] Cache-Control rules")
+	auditLogFile := flag.String("audit-log-file", "", "Audit log file")
+	auditLogMaxSize := flag.Int64("audit-log-max-size", 0, "Size at which the audit log is rotated")
+	auditLogMaxFiles := flag.Int("audit-log-max-files", 0, "Rotated audit log files kept")
 
 	flag.Parse()
 
@@ -45,6 +191,159 @@ func LoadConfig() (*Config, error) {
 	if *logLevel != "" {
 		cfg.LogLevel = *logLevel
 	}
+	if *logFormat != "" {
+		cfg.LogFormat = *logFormat
+	}
+	if *accessLogLevel != "" {
+		cfg.AccessLogLevel = *accessLogLevel
+	}
+	if *accessLogSampleRatio != 0 {
+		cfg.AccessLogSampleRatio = *accessLogSampleRatio
+	}
+	if *cacheCompressionThreshold != 0 {
+		cfg.CacheCompressionThreshold = *cacheCompressionThreshold
+	}
+	if *httpCompressionMinSize != 0 {
+		cfg.HTTPCompressionMinSize = *httpCompressionMinSize
+	}
+	if *httpMaxBodySize != 0 {
+		cfg.HTTPMaxBodySize = *httpMaxBodySize
+	}
+	if *drainDelay != 0 {
+		cfg.DrainDelay = *drainDelay
+	}
+	if *tlsCertFile != "" {
+		cfg.TLSCertFile = *tlsCertFile
+	}
+	if *tlsKeyFile != "" {
+		cfg.TLSKeyFile = *tlsKeyFile
+	}
+	if *tlsMinVersion != "" {
+		cfg.TLSMinVersion = *tlsMinVersion
+	}
+	if *tlsCipherSuites != "" {
+		cfg.TLSCipherSuites = strings.Split(*tlsCipherSuites, ",")
+	}
+	if *tlsClientCAFile != "" {
+		cfg.TLSClientCAFile = *tlsClientCAFile
+	}
+	if *tlsClientAuth != "" {
+		cfg.TLSClientAuth = *tlsClientAuth
+	}
+	if *tlsReloadInterval != 0 {
+		cfg.TLSReloadInterval = *tlsReloadInterval
+	}
+	if *grpcPort != "" {
+		cfg.GRPCPort = *grpcPort
+	}
+	if *respPort != "" {
+		cfg.RESPPort = *respPort
+	}
+	if *respMaxConnections != 0 {
+		cfg.RESPMaxConnections = *respMaxConnections
+	}
+	if *respIdleTimeout != 0 {
+		cfg.RESPIdleTimeout = *respIdleTimeout
+	}
+	if *memcachePort != "" {
+		cfg.MemcachePort = *memcachePort
+	}
+	if *memcacheMaxConnections != 0 {
+		cfg.MemcacheMaxConnections = *memcacheMaxConnections
+	}
+	if *memcacheIdleTimeout != 0 {
+		cfg.MemcacheIdleTimeout = *memcacheIdleTimeout
+	}
+	if *proxyPort != "" {
+		cfg.ProxyPort = *proxyPort
+	}
+	if *proxyUpstream != "" {
+		cfg.ProxyUpstream = *proxyUpstream
+	}
+	if *proxyKeyPrefix != "" {
+		cfg.ProxyKeyPrefix = *proxyKeyPrefix
+	}
+	if *proxyMaxBodySize != 0 {
+		cfg.ProxyMaxBodySize = *proxyMaxBodySize
+	}
+	if *proxyTimeout != 0 {
+		cfg.ProxyTimeout = *proxyTimeout
+	}
+	if *tracingExporter != "" {
+		cfg.TracingExporter = *tracingExporter
+	}
+	if *tracingEndpoint != "" {
+		cfg.TracingEndpoint = *tracingEndpoint
+	}
+	if *tracingInsecure {
+		cfg.TracingInsecure = *tracingInsecure
+	}
+	if *tracingSampleRatio != 0 {
+		cfg.TracingSampleRatio = *tracingSampleRatio
+	}
+	if *authAPIKeys != "" {
+		cfg.AuthAPIKeys = strings.Split(*authAPIKeys, ",")
+	}
+	if *authAPIKeysFile != "" {
+		cfg.AuthAPIKeysFile = *authAPIKeysFile
+	}
+	if *authJWTHS256KeyFile != "" {
+		cfg.AuthJWTHS256KeyFile = *authJWTHS256KeyFile
+	}
+	if *authJWTRS256KeyFile != "" {
+		cfg.AuthJWTRS256KeyFile = *authJWTRS256KeyFile
+	}
+	if *authJWKSFile != "" {
+		cfg.AuthJWKSFile = *authJWKSFile
+	}
+	if *authJWTIssuer != "" {
+		cfg.AuthJWTIssuer = *authJWTIssuer
+	}
+	if *authJWTAudience != "" {
+		cfg.AuthJWTAudience = *authJWTAudience
+	}
+	if *allowUnauthenticatedListeners {
+		cfg.AllowUnauthenticatedListeners = *allowUnauthenticatedListeners
+	}
+	if *authzPolicyFile != "" {
+		cfg.AuthzPolicyFile = *authzPolicyFile
+	}
+	if *rateLimitRead != 0 {
+		cfg.RateLimitRead = *rateLimitRead
+	}
+	if *rateLimitReadBurst != 0 {
+		cfg.RateLimitReadBurst = *rateLimitReadBurst
+	}
+	if *rateLimitWrite != 0 {
+		cfg.RateLimitWrite = *rateLimitWrite
+	}
+	if *rateLimitWriteBurst != 0 {
+		cfg.RateLimitWriteBurst = *rateLimitWriteBurst
+	}
+	if *quotaMaxEntries != 0 {
+		cfg.QuotaMaxEntries = *quotaMaxEntries
+	}
+	if *quotaMaxBytes != 0 {
+		cfg.QuotaMaxBytes = *quotaMaxBytes
+	}
+	if *quotaTenants != "" {
+		cfg.QuotaTenants = strings.Split(*quotaTenants, ",")
+	}
+	if *httpCacheControl != "" {
+		cfg.HTTPCacheControl = *httpCacheControl
+	}
+	if *httpCacheRules != "" {
+		cfg.HTTPCacheRules = strings.Split(*httpCacheRules, ",")
+	}
+	if *auditLogFile != "" {
+		cfg.AuditLogFile = *auditLogFile
+	}
+	if *auditLogMaxSize != 0 {
+		cfg.AuditLogMaxSize = *auditLogMaxSize
+	}
+	if *auditLogMaxFiles != 0 {
+		cfg.AuditLogMaxFiles = *auditLogMaxFiles
+	}
 
 	return &cfg, nil
 }
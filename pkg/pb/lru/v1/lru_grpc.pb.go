@@ -0,0 +1,340 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: lru/v1/lru.proto
+
+package lruv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	LRUService_Get_FullMethodName      = "/lru.v1.LRUService/Get"
+	LRUService_Put_FullMethodName      = "/lru.v1.LRUService/Put"
+	LRUService_Evict_FullMethodName    = "/lru.v1.LRUService/Evict"
+	LRUService_EvictAll_FullMethodName = "/lru.v1.LRUService/EvictAll"
+	LRUService_GetAll_FullMethodName   = "/lru.v1.LRUService/GetAll"
+	LRUService_Watch_FullMethodName    = "/lru.v1.LRUService/Watch"
+)
+
+// LRUServiceClient is the client API for LRUService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// LRUService provides access to the LRU cache.
+// A missing or expired key is reported with the NOT_FOUND status code.
+type LRUServiceClient interface {
+	// Get returns a node/item by key.
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error)
+	// Put inserts or updates a node/item.
+	Put(ctx context.Context, in *PutRequest, opts ...grpc.CallOption) (*PutResponse, error)
+	// Evict deletes a node/item by key and returns its value.
+	Evict(ctx context.Context, in *EvictRequest, opts ...grpc.CallOption) (*EvictResponse, error)
+	// EvictAll flushes the cache.
+	EvictAll(ctx context.Context, in *EvictAllRequest, opts ...grpc.CallOption) (*EvictAllResponse, error)
+	// GetAll streams every live node/item.
+	GetAll(ctx context.Context, in *GetAllRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[GetAllResponse], error)
+	// Watch streams change events of keys matching the request.
+	// A subscriber that does not keep up is disconnected with RESOURCE_EXHAUSTED
+	// and may resume with the ID of the last event it has received.
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WatchResponse], error)
+}
+
+type lRUServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewLRUServiceClient(cc grpc.ClientConnInterface) LRUServiceClient {
+	return &lRUServiceClient{cc}
+}
+
+func (c *lRUServiceClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetResponse)
+	err := c.cc.Invoke(ctx, LRUService_Get_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *lRUServiceClient) Put(ctx context.Context, in *PutRequest, opts ...grpc.CallOption) (*PutResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PutResponse)
+	err := c.cc.Invoke(ctx, LRUService_Put_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *lRUServiceClient) Evict(ctx context.Context, in *EvictRequest, opts ...grpc.CallOption) (*EvictResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(EvictResponse)
+	err := c.cc.Invoke(ctx, LRUService_Evict_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *lRUServiceClient) EvictAll(ctx context.Context, in *EvictAllRequest, opts ...grpc.CallOption) (*EvictAllResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(EvictAllResponse)
+	err := c.cc.Invoke(ctx, LRUService_EvictAll_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *lRUServiceClient) GetAll(ctx context.Context, in *GetAllRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[GetAllResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &LRUService_ServiceDesc.Streams[0], LRUService_GetAll_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[GetAllRequest, GetAllResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type LRUService_GetAllClient = grpc.ServerStreamingClient[GetAllResponse]
+
+func (c *lRUServiceClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WatchResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &LRUService_ServiceDesc.Streams[1], LRUService_Watch_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchRequest, WatchResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type LRUService_WatchClient = grpc.ServerStreamingClient[WatchResponse]
+
+// LRUServiceServer is the server API for LRUService service.
+// All implementations must embed UnimplementedLRUServiceServer
+// for forward compatibility.
+//
+// LRUService provides access to the LRU cache.
+// A missing or expired key is reported with the NOT_FOUND status code.
+type LRUServiceServer interface {
+	// Get returns a node/item by key.
+	Get(context.Context, *GetRequest) (*GetResponse, error)
+	// Put inserts or updates a node/item.
+	Put(context.Context, *PutRequest) (*PutResponse, error)
+	// Evict deletes a node/item by key and returns its value.
+	Evict(context.Context, *EvictRequest) (*EvictResponse, error)
+	// EvictAll flushes the cache.
+	EvictAll(context.Context, *EvictAllRequest) (*EvictAllResponse, error)
+	// GetAll streams every live node/item.
+	GetAll(*GetAllRequest, grpc.ServerStreamingServer[GetAllResponse]) error
+	// Watch streams change events of keys matching the request.
+	// A subscriber that does not keep up is disconnected with RESOURCE_EXHAUSTED
+	// and may resume with the ID of the last event it has received.
+	Watch(*WatchRequest, grpc.ServerStreamingServer[WatchResponse]) error
+	mustEmbedUnimplementedLRUServiceServer()
+}
+
+// UnimplementedLRUServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedLRUServiceServer struct{}
+
+func (UnimplementedLRUServiceServer) Get(context.Context, *GetRequest) (*GetResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedLRUServiceServer) Put(context.Context, *PutRequest) (*PutResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Put not implemented")
+}
+func (UnimplementedLRUServiceServer) Evict(context.Context, *EvictRequest) (*EvictResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Evict not implemented")
+}
+func (UnimplementedLRUServiceServer) EvictAll(context.Context, *EvictAllRequest) (*EvictAllResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method EvictAll not implemented")
+}
+func (UnimplementedLRUServiceServer) GetAll(*GetAllRequest, grpc.ServerStreamingServer[GetAllResponse]) error {
+	return status.Error(codes.Unimplemented, "method GetAll not implemented")
+}
+func (UnimplementedLRUServiceServer) Watch(*WatchRequest, grpc.ServerStreamingServer[WatchResponse]) error {
+	return status.Error(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedLRUServiceServer) mustEmbedUnimplementedLRUServiceServer() {}
+func (UnimplementedLRUServiceServer) testEmbeddedByValue()                    {}
+
+// UnsafeLRUServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to LRUServiceServer will
+// result in compilation errors.
+type UnsafeLRUServiceServer interface {
+	mustEmbedUnimplementedLRUServiceServer()
+}
+
+func RegisterLRUServiceServer(s grpc.ServiceRegistrar, srv LRUServiceServer) {
+	// If the following call panics, it indicates UnimplementedLRUServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&LRUService_ServiceDesc, srv)
+}
+
+func _LRUService_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LRUServiceServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LRUService_Get_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LRUServiceServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LRUService_Put_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PutRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LRUServiceServer).Put(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LRUService_Put_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LRUServiceServer).Put(ctx, req.(*PutRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LRUService_Evict_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EvictRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LRUServiceServer).Evict(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LRUService_Evict_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LRUServiceServer).Evict(ctx, req.(*EvictRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LRUService_EvictAll_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EvictAllRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LRUServiceServer).EvictAll(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LRUService_EvictAll_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LRUServiceServer).EvictAll(ctx, req.(*EvictAllRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LRUService_GetAll_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetAllRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LRUServiceServer).GetAll(m, &grpc.GenericServerStream[GetAllRequest, GetAllResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type LRUService_GetAllServer = grpc.ServerStreamingServer[GetAllResponse]
+
+func _LRUService_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LRUServiceServer).Watch(m, &grpc.GenericServerStream[WatchRequest, WatchResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type LRUService_WatchServer = grpc.ServerStreamingServer[WatchResponse]
+
+// LRUService_ServiceDesc is the grpc.ServiceDesc for LRUService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var LRUService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "lru.v1.LRUService",
+	HandlerType: (*LRUServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Get",
+			Handler:    _LRUService_Get_Handler,
+		},
+		{
+			MethodName: "Put",
+			Handler:    _LRUService_Put_Handler,
+		},
+		{
+			MethodName: "Evict",
+			Handler:    _LRUService_Evict_Handler,
+		},
+		{
+			MethodName: "EvictAll",
+			Handler:    _LRUService_EvictAll_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "GetAll",
+			Handler:       _LRUService_GetAll_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Watch",
+			Handler:       _LRUService_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "lru/v1/lru.proto",
+}